@@ -4,17 +4,30 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
-	JWT      JWTConfig
-	Email    EmailConfig
-	OTP      OTPConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	Redis     RedisConfig
+	JWT       JWTConfig
+	Email     EmailConfig
+	OTP       OTPConfig
+	Cart      CartConfig
+	Order     OrderConfig
+	Payment   PaymentConfig
+	Currency  CurrencyConfig
+	Shipping  ShippingConfig
+	Return    ReturnConfig
+	Search    SearchConfig
+	TwoFactor TwoFactorConfig
+	AuthLimit AuthLimitConfig
+	RateLimit RateLimitConfig
+	OIDC      OIDCConfig
+	RBAC      RBACConfig
 }
 
 type ServerConfig struct {
@@ -39,8 +52,11 @@ type RedisConfig struct {
 }
 
 type JWTConfig struct {
-	Secret      string
-	ExpireHours int
+	Keys                string // Comma-separated kid=path.pem pairs of signing and verification keys
+	SigningKeyID        string
+	Issuer              string
+	AccessExpireMinutes int
+	RefreshExpireHours  int
 }
 
 type EmailConfig struct {
@@ -56,6 +72,91 @@ type OTPConfig struct {
 	Length        int
 }
 
+type CartConfig struct {
+	ExpireHours         int
+	CheckoutLockSeconds int
+}
+
+type OrderConfig struct {
+	PaymentWindowMinutes int
+	ExpiryCheckSeconds   int
+}
+
+type PaymentConfig struct {
+	Provider           string
+	WebhookSecret      string
+	RefundRetrySeconds int
+}
+
+type CurrencyConfig struct {
+	Base string // ISO 4217 code of catalogue prices; shoppers can also use every currency with an exchange rate
+}
+
+type ShippingConfig struct {
+	Carriers        string // Comma-separated carrier integrations shipments can be sent with
+	WeightAttribute string // ExtraInfo attribute holding the product weight in kg, for weight-based rates
+}
+
+type ReturnConfig struct {
+	WindowDays int
+}
+
+type SearchConfig struct {
+	PriceBuckets          []float64 // Ascending bucket boundaries of the price facet
+	LogRetentionDays      int       // Raw search_logs older than this are aggregated and pruned
+	RetentionCheckHours   int
+	ConversionWindowHours int // An order within this time after a search counts as its conversion
+}
+
+type TwoFactorConfig struct {
+	Issuer                 string // Account issuer shown by authenticator apps
+	ChallengeExpireMinutes int    // Lifetime of the challenge token returned by login
+	MaxAttempts            int    // Wrong codes allowed per challenge
+	EnrollExpireMinutes    int    // Time to confirm a new secret before it is discarded
+}
+
+// AuthLimitConfig limits password and OTP guessing
+type AuthLimitConfig struct {
+	MaxFailures           int // Failed attempts per email within the window before it is locked out
+	MaxIPFailures         int // Failed attempts per client IP within the window before it is locked out
+	FailureWindowMinutes  int
+	LockoutSeconds        int // First lockout; every further lockout doubles it
+	MaxLockoutSeconds     int
+	OTPMaxAttempts        int // Wrong guesses after which an OTP is invalidated
+	ResendCooldownSeconds int // Minimum time between two OTP emails to the same address
+}
+
+// RateLimitConfig sets the request quotas per route group within a sliding window
+type RateLimitConfig struct {
+	Enabled       bool
+	WindowSeconds int
+	Public        int            // Per client IP on public routes
+	Authenticated int            // Per user on routes that require login
+	Seller        int            // Per user on seller routes
+	SuperAdmin    int            // Per user on super admin routes
+	RoleLimits    map[string]int // Higher quotas of roles on any authenticated route, e.g. "seller=600,super_admin=1200"
+}
+
+// OIDCConfig lists the OpenID Connect providers users can sign in with
+type OIDCConfig struct {
+	Providers          []OIDCProviderConfig
+	RedirectBaseURL    string // Public URL of /api/v1/auth/oidc; callbacks are <base>/<provider>/callback
+	StateExpireMinutes int    // Time to finish signing in at the provider
+}
+
+type OIDCProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+// RBACConfig tunes permission checks
+type RBACConfig struct {
+	PermissionCacheSeconds int // How long a user's permissions are cached in Redis; role changes invalidate the cache
+}
+
 func Load() *Config {
 	// Load .env file
 	if err := godotenv.Load(); err != nil {
@@ -82,8 +183,11 @@ func Load() *Config {
 			DB:       getEnvAsInt("REDIS_DB", 0),
 		},
 		JWT: JWTConfig{
-			Secret:      getEnv("JWT_SECRET", "your-super-secret-jwt-key"),
-			ExpireHours: getEnvAsInt("JWT_EXPIRE_HOURS", 24),
+			Keys:                getEnv("JWT_KEYS", ""),
+			SigningKeyID:        getEnv("JWT_SIGNING_KEY_ID", ""),
+			Issuer:              getEnv("JWT_ISSUER", "go-shop"),
+			AccessExpireMinutes: getEnvAsInt("JWT_ACCESS_EXPIRE_MINUTES", 15),
+			RefreshExpireHours:  getEnvAsInt("JWT_REFRESH_EXPIRE_HOURS", 720),
 		},
 		Email: EmailConfig{
 			SMTPHost:     getEnv("SMTP_HOST", "smtp.gmail.com"),
@@ -96,7 +200,95 @@ func Load() *Config {
 			ExpireMinutes: getEnvAsInt("OTP_EXPIRE_MINUTES", 60),
 			Length:        getEnvAsInt("OTP_LENGTH", 6),
 		},
+		Cart: CartConfig{
+			ExpireHours:         getEnvAsInt("CART_EXPIRE_HOURS", 720),
+			CheckoutLockSeconds: getEnvAsInt("CART_CHECKOUT_LOCK_SECONDS", 60),
+		},
+		Order: OrderConfig{
+			PaymentWindowMinutes: getEnvAsInt("ORDER_PAYMENT_WINDOW_MINUTES", 30),
+			ExpiryCheckSeconds:   getEnvAsInt("ORDER_EXPIRY_CHECK_SECONDS", 60),
+		},
+		Payment: PaymentConfig{
+			Provider:           getEnv("PAYMENT_PROVIDER", "sandbox"),
+			WebhookSecret:      getEnv("PAYMENT_WEBHOOK_SECRET", ""),
+			RefundRetrySeconds: getEnvAsInt("PAYMENT_REFUND_RETRY_SECONDS", 300),
+		},
+		Currency: CurrencyConfig{
+			Base: strings.ToUpper(getEnv("BASE_CURRENCY", getEnv("PAYMENT_CURRENCY", "USD"))),
+		},
+		Shipping: ShippingConfig{
+			Carriers:        getEnv("SHIPPING_CARRIERS", ""),
+			WeightAttribute: getEnv("SHIPPING_WEIGHT_ATTRIBUTE", "weight"),
+		},
+		Return: ReturnConfig{
+			WindowDays: getEnvAsInt("RETURN_WINDOW_DAYS", 14),
+		},
+		Search: SearchConfig{
+			PriceBuckets:          getEnvAsFloats("SEARCH_PRICE_BUCKETS", []float64{10, 25, 50, 100, 250, 500, 1000}),
+			LogRetentionDays:      getEnvAsInt("SEARCH_LOG_RETENTION_DAYS", 90),
+			RetentionCheckHours:   getEnvAsInt("SEARCH_RETENTION_CHECK_HOURS", 24),
+			ConversionWindowHours: getEnvAsInt("SEARCH_CONVERSION_WINDOW_HOURS", 24),
+		},
+		TwoFactor: TwoFactorConfig{
+			Issuer:                 getEnv("TWO_FACTOR_ISSUER", "Go Shop"),
+			ChallengeExpireMinutes: getEnvAsInt("TWO_FACTOR_CHALLENGE_EXPIRE_MINUTES", 5),
+			MaxAttempts:            getEnvAsInt("TWO_FACTOR_MAX_ATTEMPTS", 5),
+			EnrollExpireMinutes:    getEnvAsInt("TWO_FACTOR_ENROLL_EXPIRE_MINUTES", 15),
+		},
+		AuthLimit: AuthLimitConfig{
+			MaxFailures:           getEnvAsInt("AUTH_MAX_FAILURES", 5),
+			MaxIPFailures:         getEnvAsInt("AUTH_MAX_IP_FAILURES", 20),
+			FailureWindowMinutes:  getEnvAsInt("AUTH_FAILURE_WINDOW_MINUTES", 15),
+			LockoutSeconds:        getEnvAsInt("AUTH_LOCKOUT_SECONDS", 60),
+			MaxLockoutSeconds:     getEnvAsInt("AUTH_MAX_LOCKOUT_SECONDS", 3600),
+			OTPMaxAttempts:        getEnvAsInt("OTP_MAX_ATTEMPTS", 5),
+			ResendCooldownSeconds: getEnvAsInt("OTP_RESEND_COOLDOWN_SECONDS", 60),
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:       getEnvAsBool("RATE_LIMIT_ENABLED", true),
+			WindowSeconds: getEnvAsInt("RATE_LIMIT_WINDOW_SECONDS", 60),
+			Public:        getEnvAsInt("RATE_LIMIT_PUBLIC", 60),
+			Authenticated: getEnvAsInt("RATE_LIMIT_AUTHENTICATED", 120),
+			Seller:        getEnvAsInt("RATE_LIMIT_SELLER", 300),
+			SuperAdmin:    getEnvAsInt("RATE_LIMIT_SUPER_ADMIN", 600),
+			RoleLimits:    getEnvAsIntMap("RATE_LIMIT_ROLES", map[string]int{"seller": 240, "super_admin": 480}),
+		},
+		OIDC: OIDCConfig{
+			Providers:          loadOIDCProviders(),
+			RedirectBaseURL:    strings.TrimSuffix(getEnv("OIDC_REDIRECT_BASE_URL", "http://localhost:8080/api/v1/auth/oidc"), "/"),
+			StateExpireMinutes: getEnvAsInt("OIDC_STATE_EXPIRE_MINUTES", 10),
+		},
+		RBAC: RBACConfig{
+			PermissionCacheSeconds: getEnvAsInt("PERMISSION_CACHE_SECONDS", 300),
+		},
+	}
+}
+
+// loadOIDCProviders reads the providers named in OIDC_PROVIDERS ("google,keycloak") from
+// OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID, OIDC_<NAME>_CLIENT_SECRET and OIDC_<NAME>_SCOPES
+func loadOIDCProviders() []OIDCProviderConfig {
+	var providers []OIDCProviderConfig
+	for _, name := range strings.Split(getEnv("OIDC_PROVIDERS", ""), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		provider := OIDCProviderConfig{
+			Name:         name,
+			Issuer:       getEnv(prefix+"ISSUER", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			Scopes:       strings.Fields(getEnv(prefix+"SCOPES", "openid email profile")),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" {
+			log.Printf("OIDC provider %s needs %sISSUER and %sCLIENT_ID, skipping", name, prefix, prefix)
+			continue
+		}
+		providers = append(providers, provider)
 	}
+	return providers
 }
 
 func getEnv(key, defaultValue string) string {
@@ -122,3 +314,53 @@ func getEnvAsInt(key string, defaultValue int) int {
 	// Если не удалось конвертировать - используем значение по умолчанию
 	return defaultValue
 }
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	if boolValue, err := strconv.ParseBool(value); err == nil {
+		return boolValue
+	}
+	return defaultValue
+}
+
+// getEnvAsIntMap parses a comma-separated list of name=number pairs
+func getEnvAsIntMap(key string, defaultValue map[string]int) map[string]int {
+	value, exists := os.LookupEnv(key)
+	if !exists || strings.TrimSpace(value) == "" {
+		return defaultValue
+	}
+
+	result := make(map[string]int)
+	for _, part := range strings.Split(value, ",") {
+		name, number, ok := strings.Cut(strings.TrimSpace(part), "=")
+		intValue, err := strconv.Atoi(strings.TrimSpace(number))
+		if !ok || name == "" || err != nil {
+			log.Printf("Invalid %s, using default", key)
+			return defaultValue
+		}
+		result[strings.TrimSpace(name)] = intValue
+	}
+	return result
+}
+
+// getEnvAsFloats parses a comma-separated list of ascending numbers
+func getEnvAsFloats(key string, defaultValue []float64) []float64 {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	var result []float64
+	for _, part := range strings.Split(value, ",") {
+		number, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || (len(result) > 0 && number <= result[len(result)-1]) {
+			log.Printf("Invalid %s, using default", key)
+			return defaultValue
+		}
+		result = append(result, number)
+	}
+	return result
+}
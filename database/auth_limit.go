@@ -0,0 +1,136 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// lockoutHistory is how long past lockouts are remembered to grow the next one
+const lockoutHistory = 24 * time.Hour
+
+// AuthLimit configures when repeated failures lock out an email or client IP
+type AuthLimit struct {
+	MaxFailures int
+	Window      time.Duration // Failures older than this are forgotten
+	Lockout     time.Duration // First lockout, doubled on every further one
+	MaxLockout  time.Duration
+}
+
+func authFailuresKey(scope, id string) string {
+	return fmt.Sprintf("auth_failures:%s:%s", scope, id)
+}
+
+func authLockoutKey(scope, id string) string {
+	return fmt.Sprintf("auth_lockout:%s:%s", scope, id)
+}
+
+func authLockoutCountKey(scope, id string) string {
+	return fmt.Sprintf("auth_lockouts:%s:%s", scope, id)
+}
+
+// recordFailureScript counts a failure; on reaching the limit the counter is reset and a lockout
+// of base * 2^(previous lockouts) seconds (capped) starts. Returns the lockout in seconds, or 0.
+var recordFailureScript = redis.NewScript(`
+local failures = redis.call('INCR', KEYS[1])
+if failures == 1 then
+	redis.call('EXPIRE', KEYS[1], ARGV[2])
+end
+if failures < tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('DEL', KEYS[1])
+local lockouts = redis.call('INCR', KEYS[3])
+redis.call('EXPIRE', KEYS[3], ARGV[5])
+local duration = tonumber(ARGV[3]) * 2 ^ (lockouts - 1)
+if duration > tonumber(ARGV[4]) then
+	duration = tonumber(ARGV[4])
+end
+duration = math.floor(duration)
+redis.call('SET', KEYS[2], 1, 'EX', duration)
+return duration
+`)
+
+// RecordAuthFailure counts a failed attempt of an email or IP and returns the lockout it started, if any
+func RecordAuthFailure(ctx context.Context, scope, id string, limit AuthLimit) (time.Duration, error) {
+	if limit.MaxFailures <= 0 {
+		return 0, nil
+	}
+
+	keys := []string{authFailuresKey(scope, id), authLockoutKey(scope, id), authLockoutCountKey(scope, id)}
+	seconds, err := recordFailureScript.Run(ctx, RedisClient, keys,
+		limit.MaxFailures,
+		max(int(limit.Window.Seconds()), 1),
+		max(int(limit.Lockout.Seconds()), 1),
+		max(int(limit.MaxLockout.Seconds()), 1),
+		int(lockoutHistory.Seconds()),
+	).Int64()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// GetAuthLockout returns the remaining lockout of an email or IP, or 0 if it is not locked out
+func GetAuthLockout(ctx context.Context, scope, id string) (time.Duration, error) {
+	ttl, err := RedisClient.PTTL(ctx, authLockoutKey(scope, id)).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// ClearAuthFailures forgets the failures and past lockouts of an email after a successful attempt
+func ClearAuthFailures(ctx context.Context, scope, id string) error {
+	return RedisClient.Del(ctx, authFailuresKey(scope, id), authLockoutCountKey(scope, id)).Err()
+}
+
+func otpAttemptsKey(purpose, email string) string {
+	return fmt.Sprintf("otp_attempts:%s:%s", purpose, email)
+}
+
+// IncrOTPAttempts counts a wrong guess of an OTP and returns the number of wrong guesses so far
+func IncrOTPAttempts(ctx context.Context, purpose, email string, expiration time.Duration) (int, error) {
+	key := otpAttemptsKey(purpose, email)
+	pipe := RedisClient.TxPipeline()
+	attempts := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, expiration)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return int(attempts.Val()), nil
+}
+
+// DeleteOTPAttempts resets the wrong guesses of an OTP, when it is used or replaced
+func DeleteOTPAttempts(ctx context.Context, purpose, email string) error {
+	return RedisClient.Del(ctx, otpAttemptsKey(purpose, email)).Err()
+}
+
+// StartOTPCooldown blocks sending another OTP to an email for the cooldown. If a cooldown is already
+// running nothing is changed and its remaining time is returned.
+func StartOTPCooldown(ctx context.Context, purpose, email string, cooldown time.Duration) (time.Duration, error) {
+	if cooldown <= 0 {
+		return 0, nil
+	}
+
+	key := fmt.Sprintf("otp_cooldown:%s:%s", purpose, email)
+	started, err := RedisClient.SetNX(ctx, key, 1, cooldown).Result()
+	if err != nil || started {
+		return 0, err
+	}
+
+	ttl, err := RedisClient.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl <= 0 {
+		// Expired in between
+		return 0, nil
+	}
+	return ttl, nil
+}
@@ -36,25 +36,70 @@ func ConnectDB(cfg *config.Config) {
 	log.Println("Database connected successfully")
 }
 
-func Migrate() {
+func Migrate(cfg *config.Config) {
+	baseCurrency := models.NormalizeCurrency(cfg.Currency.Base)
+	if baseCurrency == "" {
+		log.Fatalf("Invalid base currency: %q", cfg.Currency.Base)
+	}
+
+	// Amounts are exact minor units; convert databases that still hold floats
+	converted := migrateMoney(baseCurrency)
+
+	// Orders placed before promotions existed have no subtotal yet
+	backfillSubtotals := DB.Migrator().HasTable(&models.Order{}) && !DB.Migrator().HasColumn(&models.Order{}, "Subtotal")
+
 	err := DB.AutoMigrate(
 		&models.User{},
+		&models.Permission{},
 		&models.Role{},
 		&models.UserRole{},
 		&models.Category{},
 		&models.Product{},
+		&models.ProductVariant{},
 		&models.Order{},
 		&models.OrderItem{},
+		&models.OrderStatusHistory{},
+		&models.Shipment{},
+		&models.StockReservation{},
+		&models.Payment{},
+		&models.Refund{},
+		&models.OrderReturn{},
+		&models.ReturnItem{},
 		&models.Favorite{},
 		&models.SearchLog{},
+		&models.SearchQueryStat{},
+		&models.RecoveryCode{},
+		&models.UserIdentity{},
+		&models.Promotion{},
+		&models.OrderDiscount{},
+		&models.ExchangeRate{},
+		&models.ProductPrice{},
+		&models.TaxRate{},
+		&models.OrderTax{},
+		&models.Address{},
+		&models.ShippingMethod{},
 	)
 
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
 
+	// Full-text search index over products
+	migrateProductSearch()
+
+	// Orders placed before promotions existed were charged their subtotal
+	if backfillSubtotals {
+		if err := DB.Exec("UPDATE orders SET subtotal = total_amount").Error; err != nil {
+			log.Fatal("Failed to migrate order subtotals:", err)
+		}
+	}
+	if converted["promotions"] {
+		migrateFixedPromotions()
+	}
+
 	// Создаем базовые роли, если их нет
 	createDefaultRoles()
+	createDefaultPermissions()
 
 	log.Println("Database migration completed")
 }
@@ -78,6 +123,58 @@ func createDefaultRoles() {
 	}
 }
 
+// defaultPermissions are the permissions the routes check and the built-in roles granted each of them
+var defaultPermissions = []struct {
+	name        string
+	description string
+	roles       []string
+}{
+	{models.PermissionRolesRead, "List roles and the users holding them", []string{models.ROLE_SUPER_ADMIN}},
+	{models.PermissionRolesAssign, "Assign and remove roles of users", []string{models.ROLE_SUPER_ADMIN}},
+	{models.PermissionRolesManage, "Create roles and edit their permissions", []string{models.ROLE_SUPER_ADMIN}},
+	{models.PermissionCategoriesWrite, "Create, update and delete categories", []string{models.ROLE_SUPER_ADMIN}},
+	{models.PermissionProductsWrite, "Create and update own products", []string{models.ROLE_SUPER_ADMIN, models.ROLE_SELLER}},
+	{models.PermissionProductsManage, "Manage and delete the products of every seller", []string{models.ROLE_SUPER_ADMIN}},
+	{models.PermissionOrdersShip, "See and ship orders containing own products", []string{models.ROLE_SUPER_ADMIN, models.ROLE_SELLER}},
+	{models.PermissionOrdersManage, "Confirm, ship, deliver and cancel every order, handle returns", []string{models.ROLE_SUPER_ADMIN}},
+	{models.PermissionAnalyticsRead, "View search analytics", []string{models.ROLE_SUPER_ADMIN}},
+	{models.PermissionPromotionsManage, "Create, update and delete coupons and promotions", []string{models.ROLE_SUPER_ADMIN}},
+	{models.PermissionCurrenciesManage, "Set exchange rates of the currencies shoppers can use", []string{models.ROLE_SUPER_ADMIN}},
+	{models.PermissionTaxesManage, "Create, update and delete tax rates", []string{models.ROLE_SUPER_ADMIN}},
+	{models.PermissionShippingManage, "Create, update and delete shipping methods", []string{models.ROLE_SUPER_ADMIN}},
+}
+
+// createDefaultPermissions creates missing permissions and grants them to the built-in roles.
+// Existing permissions are left alone, so grants edited through the API are kept.
+func createDefaultPermissions() {
+	for _, def := range defaultPermissions {
+		var permission models.Permission
+		err := DB.Where("name = ?", def.name).First(&permission).Error
+		if err == nil {
+			continue
+		}
+		if err != gorm.ErrRecordNotFound {
+			log.Printf("Failed to check permission %s: %v", def.name, err)
+			continue
+		}
+
+		permission = models.Permission{Name: def.name, Description: def.description}
+		if err := DB.Create(&permission).Error; err != nil {
+			log.Printf("Failed to create permission %s: %v", def.name, err)
+			continue
+		}
+		log.Printf("Created default permission: %s", def.name)
+
+		var roles []models.Role
+		DB.Where("name IN ?", def.roles).Find(&roles)
+		for _, role := range roles {
+			if err := DB.Model(&role).Association("Permissions").Append(&permission); err != nil {
+				log.Printf("Failed to grant permission %s to role %s: %v", def.name, role.Name, err)
+			}
+		}
+	}
+}
+
 func GetDB() *gorm.DB {
 	return DB
 }
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"fmt"
+	"log"
+
+	"go-shop/models"
+)
+
+// moneyColumns used to hold float8 amounts in currency units; they hold exact minor units (cents) now
+var moneyColumns = []struct {
+	table  string
+	column string
+}{
+	{"products", "price"},
+	{"product_variants", "price"},
+	{"orders", "subtotal"},
+	{"orders", "discount"},
+	{"orders", "total_amount"},
+	{"orders", "refunded"},
+	{"order_items", "price_at_moment"},
+	{"order_items", "discount"},
+	{"order_discounts", "amount"},
+	{"promotions", "min_subtotal"},
+	{"payments", "amount"},
+	{"payments", "refunded_amount"},
+	{"refunds", "amount"},
+	{"order_returns", "refund_amount"},
+	{"return_items", "amount"},
+}
+
+// migrateMoney converts float amounts of existing databases to minor units before AutoMigrate
+// sees the columns, which would otherwise cast them to integers without scaling. Orders placed
+// before multi-currency pricing are in the base currency. It returns the tables it converted.
+func migrateMoney(baseCurrency string) map[string]bool {
+	converted := make(map[string]bool)
+	for _, money := range moneyColumns {
+		var dataType string
+		if err := DB.Raw(`SELECT data_type FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?`,
+			money.table, money.column).Scan(&dataType).Error; err != nil {
+			log.Fatal("Failed to inspect money columns:", err)
+		}
+		if dataType != "double precision" && dataType != "real" && dataType != "numeric" {
+			continue
+		}
+
+		// Defaults are dropped with the type change; AutoMigrate puts them back
+		statement := fmt.Sprintf(`ALTER TABLE %[1]s ALTER COLUMN %[2]s DROP DEFAULT,
+			ALTER COLUMN %[2]s TYPE bigint USING round(%[2]s * %[3]d)`, money.table, money.column, models.MoneyScale)
+		if err := DB.Exec(statement).Error; err != nil {
+			log.Fatalf("Failed to migrate %s.%s to minor units: %v", money.table, money.column, err)
+		}
+		log.Printf("Migrated %s.%s to minor units", money.table, money.column)
+		converted[money.table] = true
+	}
+
+	if DB.Migrator().HasTable(&models.Order{}) && !DB.Migrator().HasColumn(&models.Order{}, "Currency") {
+		// The code is three letters (checked by Migrate), so it is safe to inline
+		if err := DB.Exec(fmt.Sprintf("ALTER TABLE orders ADD COLUMN currency varchar(3) NOT NULL DEFAULT '%s'", baseCurrency)).Error; err != nil {
+			log.Fatal("Failed to add order currency:", err)
+		}
+		if err := DB.Exec("ALTER TABLE orders ALTER COLUMN currency DROP DEFAULT").Error; err != nil {
+			log.Fatal("Failed to add order currency:", err)
+		}
+	}
+	return converted
+}
+
+// migrateFixedPromotions moves the amount of fixed promotions, kept in value before amounts were money, to amount.
+// It runs once, when migrateMoney converts the promotions table.
+func migrateFixedPromotions() {
+	if err := DB.Exec("UPDATE promotions SET amount = round(value * ?), value = 0 WHERE type = ? AND amount = 0 AND value > 0",
+		models.MoneyScale, models.PromotionTypeFixed).Error; err != nil {
+		log.Fatal("Failed to migrate fixed promotions:", err)
+	}
+}
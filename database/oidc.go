@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrOIDCStateInvalid is returned when a sign-in callback carries an unknown, used or expired state
+var ErrOIDCStateInvalid = errors.New("invalid or expired sign-in state")
+
+// OIDCState is a sign-in started at an OpenID Connect provider, stored under its state parameter
+type OIDCState struct {
+	Provider     string `json:"provider"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"` // PKCE verifier of the authorization request
+	CartToken    string `json:"cart_token"`    // Guest cart merged once the login completes
+}
+
+func oidcStateKey(state string) string {
+	return fmt.Sprintf("oidc_state:%s", state)
+}
+
+// SetOIDCState stores a pending sign-in until the provider redirects back
+func SetOIDCState(ctx context.Context, state string, data *OIDCState, expiration time.Duration) error {
+	value, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return RedisClient.Set(ctx, oidcStateKey(state), value, expiration).Err()
+}
+
+// TakeOIDCState returns and deletes a pending sign-in, so each state is used once
+func TakeOIDCState(ctx context.Context, state string) (*OIDCState, error) {
+	key := oidcStateKey(state)
+	pipe := RedisClient.TxPipeline()
+	get := pipe.Get(ctx, key)
+	pipe.Del(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrOIDCStateInvalid
+		}
+		return nil, err
+	}
+
+	var data OIDCState
+	if err := json.Unmarshal([]byte(get.Val()), &data); err != nil {
+		return nil, ErrOIDCStateInvalid
+	}
+	return &data, nil
+}
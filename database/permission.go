@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// permissionVersionKey is bumped on every role or permission change; cached permissions are
+// keyed by it, so a change makes every cached entry stale at once
+const permissionVersionKey = "permissions_version"
+
+// UserAccess is what a user may do: their roles and the permissions these grant
+type UserAccess struct {
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+// Has reports whether one of the user's roles grants the permission
+func (a *UserAccess) Has(permission string) bool {
+	return slices.Contains(a.Permissions, permission)
+}
+
+func userAccessKey(version int64, userID uint) string {
+	return fmt.Sprintf("user_access:%d:%d", version, userID)
+}
+
+// GetUserAccess returns the roles and permissions of a user, cached in Redis for ttl.
+// If Redis is unavailable they are read from the database.
+func GetUserAccess(ctx context.Context, userID uint, ttl time.Duration) (*UserAccess, error) {
+	version, err := RedisClient.Get(ctx, permissionVersionKey).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		log.Printf("Failed to get permissions version: %v", err)
+		return LoadUserAccess(userID)
+	}
+
+	key := userAccessKey(version, userID)
+	if cached, err := RedisClient.Get(ctx, key).Bytes(); err == nil {
+		var access UserAccess
+		if err := json.Unmarshal(cached, &access); err == nil {
+			return &access, nil
+		}
+	}
+
+	access, err := LoadUserAccess(userID)
+	if err != nil {
+		return nil, err
+	}
+	if value, err := json.Marshal(access); err == nil {
+		if err := RedisClient.Set(ctx, key, value, ttl).Err(); err != nil {
+			log.Printf("Failed to cache permissions of user %d: %v", userID, err)
+		}
+	}
+	return access, nil
+}
+
+// LoadUserAccess reads the roles and permissions of a user from the database
+func LoadUserAccess(userID uint) (*UserAccess, error) {
+	var rows []struct {
+		Role       string
+		Permission *string
+	}
+	err := DB.Table("user_roles").
+		Select("roles.name AS role, permissions.name AS permission").
+		Joins("JOIN roles ON roles.id = user_roles.role_id AND roles.deleted_at IS NULL").
+		Joins("LEFT JOIN role_permissions ON role_permissions.role_id = roles.id").
+		Joins("LEFT JOIN permissions ON permissions.id = role_permissions.permission_id").
+		Where("user_roles.user_id = ? AND user_roles.deleted_at IS NULL", userID).
+		Order("roles.name, permissions.name").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	access := &UserAccess{Roles: []string{}, Permissions: []string{}}
+	for _, row := range rows {
+		if !slices.Contains(access.Roles, row.Role) {
+			access.Roles = append(access.Roles, row.Role)
+		}
+		if row.Permission != nil && !access.Has(*row.Permission) {
+			access.Permissions = append(access.Permissions, *row.Permission)
+		}
+	}
+	slices.Sort(access.Permissions)
+	return access, nil
+}
+
+// InvalidatePermissions drops all cached permissions after roles or their permissions changed
+func InvalidatePermissions(ctx context.Context) error {
+	return RedisClient.Incr(ctx, permissionVersionKey).Err()
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// rateLimitScript keeps the timestamps (ms) of the requests within the window in a sorted set
+// and admits a request while fewer than the limit are recorded.
+// Returns {allowed, remaining, ms until the oldest request leaves the window}.
+var rateLimitScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', KEYS[1], now, ARGV[4])
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', KEYS[1], window)
+local reset = window
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+if #oldest > 0 then
+	reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, limit - count, reset}
+`)
+
+// rateLimitSequence makes the members of requests within the same millisecond unique
+var rateLimitSequence atomic.Uint64
+
+// RateLimitResult is the outcome of counting a request against a quota
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	Reset     time.Duration // Until the next request slot frees up
+}
+
+// TakeRateLimit counts a request against a sliding-window quota of limit requests per window
+func TakeRateLimit(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error) {
+	now := time.Now()
+	member := fmt.Sprintf("%d-%d", now.UnixNano(), rateLimitSequence.Add(1))
+	keys := []string{fmt.Sprintf("rate_limit:%s", key)}
+
+	values, err := rateLimitScript.Run(ctx, RedisClient, keys, now.UnixMilli(), window.Milliseconds(), limit, member).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 3 {
+		return nil, fmt.Errorf("unexpected rate limit result %v", values)
+	}
+
+	return &RateLimitResult{
+		Allowed:   values[0] == 1,
+		Remaining: int(values[1]),
+		Reset:     time.Duration(values[2]) * time.Millisecond,
+	}, nil
+}
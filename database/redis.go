@@ -3,8 +3,11 @@ package database
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	"go-shop/config"
@@ -101,3 +104,178 @@ func CheckPendingUserExists(ctx context.Context, email string) bool {
 	_, err := RedisClient.Get(ctx, key).Result()
 	return err == nil
 }
+
+// mergeCartScript adds every line of the source cart into the destination cart and removes the source
+var mergeCartScript = redis.NewScript(`
+local items = redis.call('HGETALL', KEYS[1])
+for i = 1, #items, 2 do
+	redis.call('HINCRBY', KEYS[2], items[i], items[i + 1])
+end
+redis.call('DEL', KEYS[1])
+if #items > 0 and tonumber(ARGV[1]) > 0 then
+	redis.call('EXPIRE', KEYS[2], ARGV[1])
+end
+return #items / 2
+`)
+
+// lockCartScript moves a cart aside for checkout so concurrent requests cannot modify or reuse it
+var lockCartScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+if redis.call('EXISTS', KEYS[2]) == 1 then
+	return -1
+end
+redis.call('RENAME', KEYS[1], KEYS[2])
+redis.call('EXPIRE', KEYS[2], ARGV[1])
+return 1
+`)
+
+// ErrCartEmpty is returned when a cart does not exist or has no items
+var ErrCartEmpty = errors.New("cart is empty")
+
+// ErrCartLocked is returned when a checkout is already in progress for a cart
+var ErrCartLocked = errors.New("checkout already in progress")
+
+// CartLine identifies a cart line: a product, or one of its variants
+type CartLine struct {
+	ProductID uint
+	VariantID uint // 0 for products sold without variants
+}
+
+// field is the hash field of the line: "<product>" or "<product>:<variant>"
+func (l CartLine) field() string {
+	if l.VariantID == 0 {
+		return strconv.FormatUint(uint64(l.ProductID), 10)
+	}
+	return fmt.Sprintf("%d:%d", l.ProductID, l.VariantID)
+}
+
+func parseCartLine(field string) (CartLine, bool) {
+	productPart, variantPart, hasVariant := strings.Cut(field, ":")
+
+	productID, err := strconv.ParseUint(productPart, 10, 32)
+	if err != nil {
+		return CartLine{}, false
+	}
+	line := CartLine{ProductID: uint(productID)}
+
+	if hasVariant {
+		variantID, err := strconv.ParseUint(variantPart, 10, 32)
+		if err != nil || variantID == 0 {
+			return CartLine{}, false
+		}
+		line.VariantID = uint(variantID)
+	}
+	return line, true
+}
+
+func cartKey(owner string) string {
+	return fmt.Sprintf("cart:%s", owner)
+}
+
+func cartCheckoutKey(owner string) string {
+	return fmt.Sprintf("cart_checkout:%s", owner)
+}
+
+// SetCartItem sets the quantity of a cart line and refreshes cart expiration
+func SetCartItem(ctx context.Context, owner string, line CartLine, quantity int, expiration time.Duration) error {
+	key := cartKey(owner)
+	pipe := RedisClient.TxPipeline()
+	pipe.HSet(ctx, key, line.field(), quantity)
+	pipe.Expire(ctx, key, expiration)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// addCartItemScript adds to the quantity of a cart line in one step, so concurrent adds are not
+// lost, and caps the result at the available stock
+var addCartItemScript = redis.NewScript(`
+local quantity = redis.call('HINCRBY', KEYS[1], ARGV[1], ARGV[2])
+local limit = tonumber(ARGV[3])
+if quantity > limit then
+	quantity = limit
+	redis.call('HSET', KEYS[1], ARGV[1], quantity)
+end
+redis.call('EXPIRE', KEYS[1], ARGV[4])
+return quantity
+`)
+
+// AddCartItem adds quantity to a cart line, up to limit, refreshes cart expiration and returns the
+// new quantity of the line
+func AddCartItem(ctx context.Context, owner string, line CartLine, quantity, limit int, expiration time.Duration) (int, error) {
+	keys := []string{cartKey(owner)}
+	return addCartItemScript.Run(ctx, RedisClient, keys, line.field(), quantity, limit, int(expiration.Seconds())).Int()
+}
+
+// RemoveCartItem removes a line from the cart
+func RemoveCartItem(ctx context.Context, owner string, line CartLine) error {
+	key := cartKey(owner)
+	return RedisClient.HDel(ctx, key, line.field()).Err()
+}
+
+// GetCart retrieves all cart lines with their quantities
+func GetCart(ctx context.Context, owner string) (map[CartLine]int, error) {
+	return getCartHash(ctx, cartKey(owner))
+}
+
+// DeleteCart removes the whole cart from Redis
+func DeleteCart(ctx context.Context, owner string) error {
+	key := cartKey(owner)
+	return RedisClient.Del(ctx, key).Err()
+}
+
+// MergeCart moves all lines of one cart into another, summing quantities of matching lines
+func MergeCart(ctx context.Context, fromOwner, toOwner string, expiration time.Duration) error {
+	keys := []string{cartKey(fromOwner), cartKey(toOwner)}
+	return mergeCartScript.Run(ctx, RedisClient, keys, int(expiration.Seconds())).Err()
+}
+
+// LockCart moves the cart to its checkout key and returns its lines
+func LockCart(ctx context.Context, owner string, expiration time.Duration) (map[CartLine]int, error) {
+	keys := []string{cartKey(owner), cartCheckoutKey(owner)}
+	result, err := lockCartScript.Run(ctx, RedisClient, keys, int(expiration.Seconds())).Int()
+	if err != nil {
+		return nil, err
+	}
+	switch result {
+	case 0:
+		return nil, ErrCartEmpty
+	case -1:
+		return nil, ErrCartLocked
+	}
+	return getCartHash(ctx, cartCheckoutKey(owner))
+}
+
+// UnlockCart returns a locked cart back to the owner after a failed checkout
+func UnlockCart(ctx context.Context, owner string, expiration time.Duration) error {
+	keys := []string{cartCheckoutKey(owner), cartKey(owner)}
+	return mergeCartScript.Run(ctx, RedisClient, keys, int(expiration.Seconds())).Err()
+}
+
+// DeleteLockedCart removes a locked cart after a successful checkout
+func DeleteLockedCart(ctx context.Context, owner string) error {
+	key := cartCheckoutKey(owner)
+	return RedisClient.Del(ctx, key).Err()
+}
+
+func getCartHash(ctx context.Context, key string) (map[CartLine]int, error) {
+	values, err := RedisClient.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	items := make(map[CartLine]int, len(values))
+	for field, value := range values {
+		line, ok := parseCartLine(field)
+		if !ok {
+			continue
+		}
+		quantity, err := strconv.Atoi(value)
+		if err != nil || quantity <= 0 {
+			continue
+		}
+		items[line] = quantity
+	}
+	return items, nil
+}
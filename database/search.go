@@ -0,0 +1,71 @@
+package database
+
+import (
+	"log"
+)
+
+// ProductSearchConfig is the text search configuration of products.search_vector.
+// "simple" does not stem, so titles in any language and prefix queries match as typed.
+const ProductSearchConfig = "simple"
+
+// productSearchMigrations maintain products.search_vector: title (A), model and category name (B), description (C).
+// A trigger is used instead of a generated column because the category name lives in another table.
+var productSearchMigrations = []string{
+	`ALTER TABLE products ADD COLUMN IF NOT EXISTS search_vector tsvector`,
+
+	`CREATE OR REPLACE FUNCTION products_search_vector(p_title text, p_model text, p_description text, p_category_id bigint)
+RETURNS tsvector AS $$
+	SELECT setweight(to_tsvector('simple', coalesce(p_title, '')), 'A') ||
+		setweight(to_tsvector('simple', coalesce(p_model, '')), 'B') ||
+		setweight(to_tsvector('simple', coalesce((SELECT name FROM categories WHERE id = p_category_id), '')), 'B') ||
+		setweight(to_tsvector('simple', coalesce(p_description, '')), 'C')
+$$ LANGUAGE sql STABLE`,
+
+	`CREATE OR REPLACE FUNCTION products_search_vector_update() RETURNS trigger AS $$
+BEGIN
+	NEW.search_vector := products_search_vector(NEW.title, NEW.model, NEW.description, NEW.category_id);
+	RETURN NEW;
+END
+$$ LANGUAGE plpgsql`,
+
+	`DROP TRIGGER IF EXISTS products_search_vector_trigger ON products`,
+	`CREATE TRIGGER products_search_vector_trigger
+BEFORE INSERT OR UPDATE OF title, model, description, category_id ON products
+FOR EACH ROW EXECUTE FUNCTION products_search_vector_update()`,
+
+	// Renaming a category re-indexes its products
+	`CREATE OR REPLACE FUNCTION categories_search_vector_update() RETURNS trigger AS $$
+BEGIN
+	UPDATE products SET search_vector = products_search_vector(title, model, description, category_id)
+	WHERE category_id = NEW.id;
+	RETURN NULL;
+END
+$$ LANGUAGE plpgsql`,
+
+	`DROP TRIGGER IF EXISTS categories_search_vector_trigger ON categories`,
+	`CREATE TRIGGER categories_search_vector_trigger
+AFTER UPDATE OF name ON categories
+FOR EACH ROW WHEN (OLD.name IS DISTINCT FROM NEW.name)
+EXECUTE FUNCTION categories_search_vector_update()`,
+
+	// Backfill products created before the column existed
+	`UPDATE products SET search_vector = products_search_vector(title, model, description, category_id)
+WHERE search_vector IS NULL`,
+
+	`CREATE INDEX IF NOT EXISTS idx_products_search_vector ON products USING GIN (search_vector)`,
+
+	// Trigram indexes for autocomplete and "did you mean" suggestions
+	`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+	`CREATE INDEX IF NOT EXISTS idx_products_title_trgm ON products USING GIN (lower(title) gin_trgm_ops)`,
+	`CREATE INDEX IF NOT EXISTS idx_categories_name_trgm ON categories USING GIN (lower(name) gin_trgm_ops)`,
+	`CREATE INDEX IF NOT EXISTS idx_search_logs_query_trgm ON search_logs USING GIN (lower(btrim(query)) gin_trgm_ops)`,
+}
+
+// migrateProductSearch sets up full-text search over products
+func migrateProductSearch() {
+	for _, statement := range productSearchMigrations {
+		if err := DB.Exec(statement).Error; err != nil {
+			log.Fatal("Failed to migrate product search:", err)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrRefreshTokenInvalid is returned when a refresh session does not exist or has expired
+var ErrRefreshTokenInvalid = errors.New("invalid or expired refresh token")
+
+// ErrRefreshTokenReused is returned when an already rotated refresh token is presented again;
+// the session is revoked because the token was probably stolen
+var ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+
+// rotateRefreshScript replaces the token hash of a refresh session if the presented hash is current.
+// Returns the user ID, -1 for an unknown session or -2 (after deleting the session) for a reused token.
+var rotateRefreshScript = redis.NewScript(`
+local current = redis.call('HGET', KEYS[1], 'token_hash')
+if not current then
+	return -1
+end
+if current ~= ARGV[1] then
+	redis.call('DEL', KEYS[1])
+	return -2
+end
+redis.call('HSET', KEYS[1], 'token_hash', ARGV[2], 'last_seen_at', ARGV[4])
+redis.call('EXPIRE', KEYS[1], ARGV[3])
+return tonumber(redis.call('HGET', KEYS[1], 'user_id'))
+`)
+
+func refreshSessionKey(sessionID string) string {
+	return fmt.Sprintf("refresh_session:%s", sessionID)
+}
+
+func userRefreshSessionsKey(userID uint) string {
+	return fmt.Sprintf("user_refresh_sessions:%d", userID)
+}
+
+// RefreshSession is a login session of a user on one device
+type RefreshSession struct {
+	ID         string
+	UserID     uint
+	UserAgent  string
+	IP         string // Address the session was started from
+	LastIP     string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+}
+
+// CreateRefreshSession stores a new refresh session holding the hash of its current refresh token
+func CreateRefreshSession(ctx context.Context, session *RefreshSession, tokenHash string, expiration time.Duration) error {
+	key := refreshSessionKey(session.ID)
+	pipe := RedisClient.TxPipeline()
+	pipe.HSet(ctx, key,
+		"user_id", session.UserID,
+		"token_hash", tokenHash,
+		"user_agent", session.UserAgent,
+		"ip", session.IP,
+		"last_ip", session.LastIP,
+		"created_at", session.CreatedAt.Unix(),
+		"last_seen_at", session.LastSeenAt.Unix(),
+	)
+	pipe.Expire(ctx, key, expiration)
+	pipe.SAdd(ctx, userRefreshSessionsKey(session.UserID), session.ID)
+	pipe.Expire(ctx, userRefreshSessionsKey(session.UserID), expiration)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// touchSessionScript records activity on a session; returns 0 if the session no longer exists
+var touchSessionScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'last_seen_at', ARGV[1], 'last_ip', ARGV[2])
+return 1
+`)
+
+// TouchRefreshSession updates the last activity of a session and reports whether it is still active
+func TouchRefreshSession(ctx context.Context, sessionID, ip string) (bool, error) {
+	keys := []string{refreshSessionKey(sessionID)}
+	result, err := touchSessionScript.Run(ctx, RedisClient, keys, time.Now().Unix(), ip).Int()
+	return result == 1, err
+}
+
+// GetUserRefreshSessions returns the active sessions of a user, dropping expired ones from the index
+func GetUserRefreshSessions(ctx context.Context, userID uint) ([]RefreshSession, error) {
+	setKey := userRefreshSessionsKey(userID)
+	sessionIDs, err := RedisClient.SMembers(ctx, setKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := RedisClient.Pipeline()
+	commands := make([]*redis.MapStringStringCmd, len(sessionIDs))
+	for i, sessionID := range sessionIDs {
+		commands[i] = pipe.HGetAll(ctx, refreshSessionKey(sessionID))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	var sessions []RefreshSession
+	var expired []interface{}
+	for i, command := range commands {
+		values := command.Val()
+		if len(values) == 0 {
+			expired = append(expired, sessionIDs[i])
+			continue
+		}
+		sessions = append(sessions, RefreshSession{
+			ID:         sessionIDs[i],
+			UserID:     userID,
+			UserAgent:  values["user_agent"],
+			IP:         values["ip"],
+			LastIP:     values["last_ip"],
+			CreatedAt:  unixField(values["created_at"]),
+			LastSeenAt: unixField(values["last_seen_at"]),
+		})
+	}
+
+	if len(expired) > 0 {
+		RedisClient.SRem(ctx, setKey, expired...)
+	}
+	return sessions, nil
+}
+
+func unixField(value string) time.Time {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
+
+// RotateRefreshSession swaps the current refresh token of a session for a new one and returns the session's user
+func RotateRefreshSession(ctx context.Context, sessionID, oldHash, newHash string, expiration time.Duration) (uint, error) {
+	keys := []string{refreshSessionKey(sessionID)}
+	result, err := rotateRefreshScript.Run(ctx, RedisClient, keys, oldHash, newHash, int(expiration.Seconds()), time.Now().Unix()).Int64()
+	if err != nil {
+		return 0, err
+	}
+	switch result {
+	case -1:
+		return 0, ErrRefreshTokenInvalid
+	case -2:
+		return 0, ErrRefreshTokenReused
+	}
+	return uint(result), nil
+}
+
+// deleteSessionScript deletes a session only if it belongs to the given user
+var deleteSessionScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'user_id') ~= ARGV[1] then
+	return 0
+end
+redis.call('DEL', KEYS[1])
+redis.call('SREM', KEYS[2], ARGV[2])
+return 1
+`)
+
+// DeleteRefreshSession revokes a single session of a user; returns false if the user has no such session
+func DeleteRefreshSession(ctx context.Context, userID uint, sessionID string) (bool, error) {
+	keys := []string{refreshSessionKey(sessionID), userRefreshSessionsKey(userID)}
+	result, err := deleteSessionScript.Run(ctx, RedisClient, keys, strconv.FormatUint(uint64(userID), 10), sessionID).Int()
+	return result == 1, err
+}
+
+// DeleteUserRefreshSessions revokes every refresh session of a user
+func DeleteUserRefreshSessions(ctx context.Context, userID uint) error {
+	sessionIDs, err := RedisClient.SMembers(ctx, userRefreshSessionsKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+
+	keys := []string{userRefreshSessionsKey(userID)}
+	for _, sessionID := range sessionIDs {
+		keys = append(keys, refreshSessionKey(sessionID))
+	}
+	return RedisClient.Del(ctx, keys...).Err()
+}
+
+// DenyToken puts an access token ID on the denylist until the token expires
+func DenyToken(ctx context.Context, tokenID string, expiration time.Duration) error {
+	if expiration <= 0 {
+		return nil
+	}
+	key := fmt.Sprintf("token_denylist:%s", tokenID)
+	return RedisClient.Set(ctx, key, 1, expiration).Err()
+}
+
+// IsTokenDenied reports whether an access token ID is on the denylist
+func IsTokenDenied(ctx context.Context, tokenID string) (bool, error) {
+	key := fmt.Sprintf("token_denylist:%s", tokenID)
+	count, err := RedisClient.Exists(ctx, key).Result()
+	return count > 0, err
+}
+
+// SetTokensRevokedAt invalidates every access token of a user issued before the given time, to the
+// millisecond; the marker only has to outlive the longest-lived access token
+func SetTokensRevokedAt(ctx context.Context, userID uint, revokedAt time.Time, expiration time.Duration) error {
+	key := fmt.Sprintf("tokens_revoked_at:%d", userID)
+	return RedisClient.Set(ctx, key, revokedAt.UnixMilli(), expiration).Err()
+}
+
+// GetTokensRevokedAt returns the time set by SetTokensRevokedAt, or the zero time if none is active
+func GetTokensRevokedAt(ctx context.Context, userID uint) (time.Time, error) {
+	key := fmt.Sprintf("tokens_revoked_at:%d", userID)
+	value, err := RedisClient.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	millis, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, nil
+	}
+	return time.UnixMilli(millis), nil
+}
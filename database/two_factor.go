@@ -0,0 +1,118 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrTwoFactorChallengeInvalid is returned when a login challenge does not exist, has expired or ran out of attempts
+var ErrTwoFactorChallengeInvalid = errors.New("invalid or expired two-factor challenge")
+
+func twoFactorChallengeKey(tokenHash string) string {
+	return fmt.Sprintf("two_factor_challenge:%s", tokenHash)
+}
+
+func pendingTOTPSecretKey(userID uint) string {
+	return fmt.Sprintf("totp_pending:%d", userID)
+}
+
+// TwoFactorChallenge is a login that passed the password check and waits for a second factor
+type TwoFactorChallenge struct {
+	UserID    uint
+	CartToken string // Guest cart merged once the login completes
+	UserAgent string
+	IP        string
+}
+
+// CreateTwoFactorChallenge stores a login challenge under the hash of its token
+func CreateTwoFactorChallenge(ctx context.Context, tokenHash string, challenge *TwoFactorChallenge, expiration time.Duration) error {
+	key := twoFactorChallengeKey(tokenHash)
+	pipe := RedisClient.TxPipeline()
+	pipe.HSet(ctx, key,
+		"user_id", challenge.UserID,
+		"cart_token", challenge.CartToken,
+		"user_agent", challenge.UserAgent,
+		"ip", challenge.IP,
+		"attempts", 0,
+	)
+	pipe.Expire(ctx, key, expiration)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetTwoFactorChallenge returns a login challenge
+func GetTwoFactorChallenge(ctx context.Context, tokenHash string) (*TwoFactorChallenge, error) {
+	values, err := RedisClient.HGetAll(ctx, twoFactorChallengeKey(tokenHash)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, ErrTwoFactorChallengeInvalid
+	}
+
+	userID, err := strconv.ParseUint(values["user_id"], 10, 64)
+	if err != nil {
+		return nil, ErrTwoFactorChallengeInvalid
+	}
+	return &TwoFactorChallenge{
+		UserID:    uint(userID),
+		CartToken: values["cart_token"],
+		UserAgent: values["user_agent"],
+		IP:        values["ip"],
+	}, nil
+}
+
+// failChallengeScript counts a wrong code; the challenge is deleted once the attempts are used up.
+// Returns the attempts left, or -1 if the challenge no longer exists.
+var failChallengeScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return -1
+end
+local attempts = redis.call('HINCRBY', KEYS[1], 'attempts', 1)
+local left = tonumber(ARGV[1]) - attempts
+if left <= 0 then
+	redis.call('DEL', KEYS[1])
+	return 0
+end
+return left
+`)
+
+// FailTwoFactorChallenge records a wrong code and returns how many attempts are left
+func FailTwoFactorChallenge(ctx context.Context, tokenHash string, maxAttempts int) (int, error) {
+	keys := []string{twoFactorChallengeKey(tokenHash)}
+	left, err := failChallengeScript.Run(ctx, RedisClient, keys, maxAttempts).Int()
+	if err != nil {
+		return 0, err
+	}
+	if left < 0 {
+		return 0, ErrTwoFactorChallengeInvalid
+	}
+	return left, nil
+}
+
+// ConsumeTwoFactorChallenge deletes a challenge; returns false if it was already used,
+// so a challenge completes at most one login
+func ConsumeTwoFactorChallenge(ctx context.Context, tokenHash string) (bool, error) {
+	deleted, err := RedisClient.Del(ctx, twoFactorChallengeKey(tokenHash)).Result()
+	return deleted > 0, err
+}
+
+// SetPendingTOTPSecret keeps a new TOTP secret until the user confirms it with a code
+func SetPendingTOTPSecret(ctx context.Context, userID uint, secret string, expiration time.Duration) error {
+	return RedisClient.Set(ctx, pendingTOTPSecretKey(userID), secret, expiration).Err()
+}
+
+// GetPendingTOTPSecret returns the unconfirmed TOTP secret of a user
+func GetPendingTOTPSecret(ctx context.Context, userID uint) (string, error) {
+	return RedisClient.Get(ctx, pendingTOTPSecretKey(userID)).Result()
+}
+
+// DeletePendingTOTPSecret discards the unconfirmed TOTP secret of a user
+func DeletePendingTOTPSecret(ctx context.Context, userID uint) error {
+	return RedisClient.Del(ctx, pendingTOTPSecretKey(userID)).Err()
+}
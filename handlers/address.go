@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"go-shop/models"
+	"go-shop/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AddressHandler struct {
+	addressService *services.AddressService
+}
+
+func NewAddressHandler(addressService *services.AddressService) *AddressHandler {
+	return &AddressHandler{
+		addressService: addressService,
+	}
+}
+
+// GetAddresses godoc
+// @Summary Get address book
+// @Description Get the authenticated user's addresses, the default address first
+// @Tags user
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /user/addresses [get]
+func (ah *AddressHandler) GetAddresses(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "User not authenticated",
+		})
+		return
+	}
+
+	addresses, err := ah.addressService.GetAddresses(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to get addresses",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Addresses retrieved successfully",
+		Data:    addresses,
+	})
+}
+
+// GetAddress godoc
+// @Summary Get an address
+// @Description Get an address of the authenticated user's address book
+// @Tags user
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Address ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /user/addresses/{id} [get]
+func (ah *AddressHandler) GetAddress(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "User not authenticated",
+		})
+		return
+	}
+
+	addressID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid address ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	address, err := ah.addressService.GetAddress(userID.(uint), uint(addressID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Address not found",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Address retrieved successfully",
+		Data:    address,
+	})
+}
+
+// CreateAddress godoc
+// @Summary Add an address
+// @Description Add an address to the authenticated user's address book; the first address becomes the default
+// @Tags user
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.AddressRequest true "Address data"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /user/addresses [post]
+func (ah *AddressHandler) CreateAddress(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "User not authenticated",
+		})
+		return
+	}
+
+	var req models.AddressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	address, err := ah.addressService.CreateAddress(userID.(uint), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to create address",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: "Address created successfully",
+		Data:    address,
+	})
+}
+
+// UpdateAddress godoc
+// @Summary Update an address
+// @Description Replace an address of the authenticated user's address book; orders already placed keep their address
+// @Tags user
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Address ID"
+// @Param request body models.AddressRequest true "Address data"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /user/addresses/{id} [put]
+func (ah *AddressHandler) UpdateAddress(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "User not authenticated",
+		})
+		return
+	}
+
+	addressID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid address ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var req models.AddressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	address, err := ah.addressService.UpdateAddress(userID.(uint), uint(addressID), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to update address",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Address updated successfully",
+		Data:    address,
+	})
+}
+
+// DeleteAddress godoc
+// @Summary Delete an address
+// @Description Delete an address of the authenticated user's address book; the newest remaining address becomes the default in place of a deleted default
+// @Tags user
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Address ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /user/addresses/{id} [delete]
+func (ah *AddressHandler) DeleteAddress(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "User not authenticated",
+		})
+		return
+	}
+
+	addressID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid address ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := ah.addressService.DeleteAddress(userID.(uint), uint(addressID)); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to delete address",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Address deleted successfully",
+	})
+}
@@ -206,7 +206,7 @@ func (ah *AdminHandler) CreateProduct(c *gin.Context) {
 		return
 	}
 
-	product, err := ah.productService.CreateProduct(&req)
+	product, err := ah.productService.CreateProduct(&req, sellerScope(c, models.PermissionProductsManage))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "Failed to create product",
@@ -223,7 +223,7 @@ func (ah *AdminHandler) CreateProduct(c *gin.Context) {
 
 // GetProducts godoc
 // @Summary Get all products
-// @Description Get all products with optional filtering (Admin only)
+// @Description Get all products with optional filtering (Admin only); sellers only see their own products
 // @Tags admin
 // @Accept json
 // @Produce json
@@ -258,7 +258,7 @@ func (ah *AdminHandler) GetProducts(c *gin.Context) {
 		}
 	}
 
-	products, err := ah.productService.GetProducts(categoryID, limit, offset)
+	products, err := ah.productService.GetProducts(categoryID, sellerScope(c, models.PermissionProductsManage), "", limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "Failed to get products",
@@ -275,7 +275,7 @@ func (ah *AdminHandler) GetProducts(c *gin.Context) {
 
 // UpdateProduct godoc
 // @Summary Update product
-// @Description Update a product (Admin only)
+// @Description Update a product (Admin only); sellers can only update their own products
 // @Tags admin
 // @Accept json
 // @Produce json
@@ -308,7 +308,7 @@ func (ah *AdminHandler) UpdateProduct(c *gin.Context) {
 		return
 	}
 
-	product, err := ah.productService.UpdateProduct(uint(productID), &req)
+	product, err := ah.productService.UpdateProduct(uint(productID), sellerScope(c, models.PermissionProductsManage), &req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "Failed to update product",
@@ -362,11 +362,256 @@ func (ah *AdminHandler) DeleteProduct(c *gin.Context) {
 	})
 }
 
+// CreateProductVariant godoc
+// @Summary Create product variant
+// @Description Add a variant (SKU with options, price override and stock) to a product (Admin/Seller only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param request body models.ProductVariantCreateRequest true "Variant data"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /admin/products/{id}/variants [post]
+func (ah *AdminHandler) CreateProductVariant(c *gin.Context) {
+	productIDStr := c.Param("id")
+	productID, err := strconv.ParseUint(productIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid product ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var req models.ProductVariantCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	variant, err := ah.productService.CreateVariant(uint(productID), sellerScope(c, models.PermissionProductsManage), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to create product variant",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: "Product variant created successfully",
+		Data:    variant,
+	})
+}
+
+// UpdateProductVariant godoc
+// @Summary Update product variant
+// @Description Update a product variant (Admin/Seller only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param variant_id path int true "Variant ID"
+// @Param request body models.ProductVariantUpdateRequest true "Variant update data"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /admin/products/{id}/variants/{variant_id} [put]
+func (ah *AdminHandler) UpdateProductVariant(c *gin.Context) {
+	productIDStr := c.Param("id")
+	productID, err := strconv.ParseUint(productIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid product ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	variantIDStr := c.Param("variant_id")
+	variantID, err := strconv.ParseUint(variantIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid variant ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var req models.ProductVariantUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	variant, err := ah.productService.UpdateVariant(uint(productID), uint(variantID), sellerScope(c, models.PermissionProductsManage), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to update product variant",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Product variant updated successfully",
+		Data:    variant,
+	})
+}
+
+// DeleteProductVariant godoc
+// @Summary Delete product variant
+// @Description Delete a product variant that was never ordered (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param variant_id path int true "Variant ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /admin/products/{id}/variants/{variant_id} [delete]
+func (ah *AdminHandler) DeleteProductVariant(c *gin.Context) {
+	productIDStr := c.Param("id")
+	productID, err := strconv.ParseUint(productIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid product ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	variantIDStr := c.Param("variant_id")
+	variantID, err := strconv.ParseUint(variantIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid variant ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := ah.productService.DeleteVariant(uint(productID), uint(variantID)); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to delete product variant",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Product variant deleted successfully",
+	})
+}
+
+// GetProductPrices godoc
+// @Summary Get product price list
+// @Description Get the list prices of a product and its variants in other currencies (Admin/Seller only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /admin/products/{id}/prices [get]
+func (ah *AdminHandler) GetProductPrices(c *gin.Context) {
+	productIDStr := c.Param("id")
+	productID, err := strconv.ParseUint(productIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid product ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	prices, err := ah.productService.GetProductPrices(uint(productID), sellerScope(c, models.PermissionProductsManage))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to get product prices",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Product prices retrieved successfully",
+		Data:    prices,
+	})
+}
+
+// SetProductPrices godoc
+// @Summary Set product price list
+// @Description Replace the list prices of a product and its variants in currencies with an exchange rate; other prices are converted from the base price (Admin/Seller only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param request body models.ProductPricesRequest true "Price list"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /admin/products/{id}/prices [put]
+func (ah *AdminHandler) SetProductPrices(c *gin.Context) {
+	productIDStr := c.Param("id")
+	productID, err := strconv.ParseUint(productIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid product ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var req models.ProductPricesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	prices, err := ah.productService.SetProductPrices(uint(productID), sellerScope(c, models.PermissionProductsManage), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to set product prices",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Product prices updated successfully",
+		Data:    prices,
+	})
+}
+
 // Order Management
 
 // GetAllOrders godoc
 // @Summary Get all orders
-// @Description Get all orders (Admin only)
+// @Description Get all orders (Admin only); sellers only see orders with their products and only their items
 // @Tags admin
 // @Accept json
 // @Produce json
@@ -391,7 +636,12 @@ func (ah *AdminHandler) GetAllOrders(c *gin.Context) {
 		offset = 0
 	}
 
-	orders, err := ah.orderService.GetAllOrders(limit, offset)
+	var orders []models.OrderResponse
+	if sellerID := sellerScope(c, models.PermissionOrdersManage); sellerID != nil {
+		orders, err = ah.orderService.GetSellerOrders(*sellerID, limit, offset)
+	} else {
+		orders, err = ah.orderService.GetAllOrders(limit, offset)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "Failed to get orders",
@@ -440,7 +690,7 @@ func (ah *AdminHandler) ConfirmOrder(c *gin.Context) {
 		return
 	}
 
-	order, err := ah.orderService.ConfirmOrder(uint(orderID))
+	order, err := ah.orderService.ConfirmOrder(uint(orderID), orderActor(c, currentUserID.(uint)))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "Failed to confirm order",
@@ -451,7 +701,7 @@ func (ah *AdminHandler) ConfirmOrder(c *gin.Context) {
 
 	// Log sensitive operation
 	middleware.LogSensitiveOperation("ORDER_CONFIRMED", currentUserID.(uint),
-		"Order ID: "+orderIDStr+", Total Amount: "+strconv.FormatFloat(order.TotalAmount, 'f', 2, 64))
+		"Order ID: "+orderIDStr+", Total Amount: "+order.TotalAmount.String()+" "+order.Currency)
 
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Message: "Order confirmed successfully",
@@ -461,12 +711,13 @@ func (ah *AdminHandler) ConfirmOrder(c *gin.Context) {
 
 // ShipOrder godoc
 // @Summary Ship order
-// @Description Mark order as shipped (Admin/Seller only)
+// @Description Mark order as shipped with a carrier and tracking number, issued by the carrier when none is given (Admin/Seller only); sellers can only ship orders with their products
 // @Tags admin
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Order ID"
+// @Param request body models.ShipmentShipRequest false "Carrier and tracking number"
 // @Success 200 {object} models.SuccessResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
@@ -493,7 +744,18 @@ func (ah *AdminHandler) ShipOrder(c *gin.Context) {
 		return
 	}
 
-	order, err := ah.orderService.ShipOrder(uint(orderID))
+	var req models.ShipmentShipRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid request data",
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+
+	order, err := ah.orderService.ShipOrder(uint(orderID), orderActor(c, currentUserID.(uint)), &req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "Failed to ship order",
@@ -504,7 +766,7 @@ func (ah *AdminHandler) ShipOrder(c *gin.Context) {
 
 	// Log sensitive operation
 	middleware.LogSensitiveOperation("ORDER_SHIPPED", currentUserID.(uint),
-		"Order ID: "+orderIDStr+", Total Amount: "+strconv.FormatFloat(order.TotalAmount, 'f', 2, 64))
+		"Order ID: "+orderIDStr+", Total Amount: "+order.TotalAmount.String()+" "+order.Currency)
 
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Message: "Order shipped successfully",
@@ -546,7 +808,7 @@ func (ah *AdminHandler) DeliverOrder(c *gin.Context) {
 		return
 	}
 
-	order, err := ah.orderService.DeliverOrder(uint(orderID))
+	order, err := ah.orderService.DeliverOrder(uint(orderID), orderActor(c, currentUserID.(uint)))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "Failed to deliver order",
@@ -557,7 +819,7 @@ func (ah *AdminHandler) DeliverOrder(c *gin.Context) {
 
 	// Log sensitive operation
 	middleware.LogSensitiveOperation("ORDER_DELIVERED", currentUserID.(uint),
-		"Order ID: "+orderIDStr+", Total Amount: "+strconv.FormatFloat(order.TotalAmount, 'f', 2, 64))
+		"Order ID: "+orderIDStr+", Total Amount: "+order.TotalAmount.String()+" "+order.Currency)
 
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Message: "Order delivered successfully",
@@ -599,7 +861,7 @@ func (ah *AdminHandler) CancelOrder(c *gin.Context) {
 		return
 	}
 
-	order, err := ah.orderService.CancelOrder(uint(orderID))
+	order, err := ah.orderService.CancelOrder(uint(orderID), orderActor(c, currentUserID.(uint)))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "Failed to cancel order",
@@ -610,10 +872,76 @@ func (ah *AdminHandler) CancelOrder(c *gin.Context) {
 
 	// Log sensitive operation
 	middleware.LogSensitiveOperation("ORDER_CANCELLED", currentUserID.(uint),
-		"Order ID: "+orderIDStr+", Total Amount: "+strconv.FormatFloat(order.TotalAmount, 'f', 2, 64))
+		"Order ID: "+orderIDStr+", Total Amount: "+order.TotalAmount.String()+" "+order.Currency)
 
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Message: "Order cancelled successfully",
 		Data:    order,
 	})
 }
+
+// GetOrderHistory godoc
+// @Summary Get order status history
+// @Description Get the status transitions of any order (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /super-admin/orders/{id}/history [get]
+func (ah *AdminHandler) GetOrderHistory(c *gin.Context) {
+	orderIDStr := c.Param("id")
+	orderID, err := strconv.ParseUint(orderIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid order ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	history, err := ah.orderService.GetOrderHistory(uint(orderID), nil)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Failed to get order history",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Order history retrieved successfully",
+		Data:    history,
+	})
+}
+
+// sellerScope returns the caller's user ID when they may only act on their own products and orders,
+// nil when they have the permission to manage those of every seller
+func sellerScope(c *gin.Context, managePermission string) *uint {
+	userID, exists := c.Get("user_id")
+	if !exists || middleware.HasPermission(c, managePermission) {
+		return nil
+	}
+
+	id := userID.(uint)
+	return &id
+}
+
+// orderActor identifies the caller of an order operation by their permissions: managing all orders
+// acts as super admin in the order state machine, shipping own orders as seller
+func orderActor(c *gin.Context, userID uint) services.OrderActor {
+	actor := services.OrderActor{UserID: &userID, Role: models.ROLE_USER}
+
+	switch {
+	case middleware.HasPermission(c, models.PermissionOrdersManage):
+		actor.Role = models.ROLE_SUPER_ADMIN
+	case middleware.HasPermission(c, models.PermissionOrdersShip):
+		actor.Role = models.ROLE_SELLER
+	}
+	return actor
+}
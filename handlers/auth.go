@@ -1,10 +1,14 @@
 package handlers
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
 	"go-shop/models"
 	"go-shop/services"
+	"go-shop/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,6 +23,21 @@ func NewAuthHandler(authService *services.AuthService) *AuthHandler {
 	}
 }
 
+// respondTooManyAttempts answers 429 with a Retry-After header if err is a lockout or resend cooldown
+func respondTooManyAttempts(c *gin.Context, err error) bool {
+	var limitErr *services.TooManyAttemptsError
+	if !errors.As(err, &limitErr) {
+		return false
+	}
+
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(limitErr.RetryAfter.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, models.ErrorResponse{
+		Error:   "Too many attempts",
+		Message: err.Error(),
+	})
+	return true
+}
+
 // Register godoc
 // @Summary Register a new user
 // @Description Register a new user with email and password
@@ -29,6 +48,7 @@ func NewAuthHandler(authService *services.AuthService) *AuthHandler {
 // @Success 201 {object} models.SuccessResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
 // @Router /auth/register [post]
 func (ah *AuthHandler) Register(c *gin.Context) {
 	var req models.UserCreateRequest
@@ -41,6 +61,9 @@ func (ah *AuthHandler) Register(c *gin.Context) {
 	}
 
 	response, err := ah.authService.Register(&req)
+	if respondTooManyAttempts(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "Registration failed",
@@ -65,6 +88,7 @@ func (ah *AuthHandler) Register(c *gin.Context) {
 // @Success 200 {object} models.SuccessResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
 // @Router /auth/verify-otp [post]
 func (ah *AuthHandler) VerifyOTP(c *gin.Context) {
 	var req models.OTPVerifyRequest
@@ -76,7 +100,10 @@ func (ah *AuthHandler) VerifyOTP(c *gin.Context) {
 		return
 	}
 
-	response, err := ah.authService.VerifyOTP(&req)
+	response, err := ah.authService.VerifyOTP(&req, c.ClientIP())
+	if respondTooManyAttempts(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "OTP verification failed",
@@ -93,7 +120,7 @@ func (ah *AuthHandler) VerifyOTP(c *gin.Context) {
 
 // Login godoc
 // @Summary User login
-// @Description Login user with email and password
+// @Description Login user with email and password. Accounts with two-factor authentication get a challenge token for /auth/2fa/verify instead of tokens.
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -101,6 +128,7 @@ func (ah *AuthHandler) VerifyOTP(c *gin.Context) {
 // @Success 200 {object} models.SuccessResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
 // @Router /auth/login [post]
 func (ah *AuthHandler) Login(c *gin.Context) {
 	var req models.UserLoginRequest
@@ -112,7 +140,15 @@ func (ah *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	response, err := ah.authService.Login(&req)
+	client := models.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+
+	response, err := ah.authService.Login(&req, client)
+	if respondTooManyAttempts(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
 			Error:   "Login failed",
@@ -121,6 +157,14 @@ func (ah *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	if response.TwoFactorRequired {
+		c.JSON(http.StatusOK, models.SuccessResponse{
+			Message: "Two-factor authentication required",
+			Data:    response,
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Message: "Login successful",
 		Data:    response,
@@ -137,6 +181,7 @@ func (ah *AuthHandler) Login(c *gin.Context) {
 // @Success 200 {object} models.SuccessResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
 // @Router /auth/request-password-reset [post]
 func (ah *AuthHandler) RequestPasswordReset(c *gin.Context) {
 	var req models.PasswordResetRequest
@@ -149,6 +194,9 @@ func (ah *AuthHandler) RequestPasswordReset(c *gin.Context) {
 	}
 
 	err := ah.authService.RequestPasswordReset(&req)
+	if respondTooManyAttempts(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "Password reset request failed",
@@ -172,6 +220,7 @@ func (ah *AuthHandler) RequestPasswordReset(c *gin.Context) {
 // @Success 200 {object} models.SuccessResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
 // @Router /auth/reset-password [post]
 func (ah *AuthHandler) ResetPassword(c *gin.Context) {
 	var req models.PasswordResetConfirmRequest
@@ -183,7 +232,10 @@ func (ah *AuthHandler) ResetPassword(c *gin.Context) {
 		return
 	}
 
-	err := ah.authService.ResetPassword(&req)
+	err := ah.authService.ResetPassword(&req, c.ClientIP())
+	if respondTooManyAttempts(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "Password reset failed",
@@ -196,3 +248,154 @@ func (ah *AuthHandler) ResetPassword(c *gin.Context) {
 		Message: "Password reset successfully",
 	})
 }
+
+// Refresh godoc
+// @Summary Refresh access token
+// @Description Exchange a refresh token for a new access and refresh token; the old refresh token stops working
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.RefreshTokenRequest true "Refresh token"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /auth/refresh [post]
+func (ah *AuthHandler) Refresh(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	response, err := ah.authService.Refresh(&req)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "Token refresh failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Token refreshed successfully",
+		Data:    response,
+	})
+}
+
+// Logout godoc
+// @Summary User logout
+// @Description End the current session: revoke its refresh token and the access token used for this request
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /auth/logout [post]
+func (ah *AuthHandler) Logout(c *gin.Context) {
+	claims, exists := c.Get("token_claims")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "User not authenticated",
+		})
+		return
+	}
+
+	if err := ah.authService.Logout(claims.(*utils.Claims)); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Logout failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Logged out successfully",
+	})
+}
+
+// GetSessions godoc
+// @Summary Get active sessions
+// @Description List the devices the user is logged in on
+// @Tags user
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /user/sessions [get]
+func (ah *AuthHandler) GetSessions(c *gin.Context) {
+	claims, exists := c.Get("token_claims")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "User not authenticated",
+		})
+		return
+	}
+	tokenClaims := claims.(*utils.Claims)
+
+	sessions, err := ah.authService.GetSessions(tokenClaims.UserID, tokenClaims.SessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to get sessions",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Sessions retrieved successfully",
+		Data:    sessions,
+	})
+}
+
+// RevokeSession godoc
+// @Summary Revoke session
+// @Description Log out one of the user's devices
+// @Tags user
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Session ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /user/sessions/{id} [delete]
+func (ah *AuthHandler) RevokeSession(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "User not authenticated",
+		})
+		return
+	}
+
+	if err := ah.authService.RevokeSession(userID.(uint), c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Failed to revoke session",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Session revoked successfully",
+	})
+}
+
+// JWKS godoc
+// @Summary JSON Web Key Set
+// @Description Public keys that verify access tokens, including retired keys whose tokens may still be valid
+// @Tags auth
+// @Produce json
+// @Success 200 {object} utils.JWKSet
+// @Router /.well-known/jwks.json [get]
+func (ah *AuthHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, utils.PublicJWKS())
+}
@@ -0,0 +1,399 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"go-shop/models"
+	"go-shop/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CartHandler struct {
+	cartService *services.CartService
+}
+
+func NewCartHandler(cartService *services.CartService) *CartHandler {
+	return &CartHandler{
+		cartService: cartService,
+	}
+}
+
+// cartOwner resolves the cart from the guest token path parameter or the authenticated user;
+// the cart is priced in the currency query parameter
+func (ch *CartHandler) cartOwner(c *gin.Context) (services.CartOwner, bool) {
+	currency := c.Query("currency")
+	if token := c.Param("token"); token != "" {
+		return services.CartOwner{Token: token, Currency: currency}, true
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "User not authenticated",
+		})
+		return services.CartOwner{}, false
+	}
+	return services.CartOwner{UserID: userID.(uint), Currency: currency}, true
+}
+
+// cartVariantID parses the optional variant_id query parameter of a cart line
+func (ch *CartHandler) cartVariantID(c *gin.Context) (*uint, bool) {
+	variantIDStr := c.Query("variant_id")
+	if variantIDStr == "" {
+		return nil, true
+	}
+
+	variantID, err := strconv.ParseUint(variantIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid variant ID",
+			Message: err.Error(),
+		})
+		return nil, false
+	}
+
+	id := uint(variantID)
+	return &id, true
+}
+
+// CreateGuestCart godoc
+// @Summary Create guest cart
+// @Description Issue a cart token for an anonymous shopper
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Success 201 {object} models.SuccessResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /cart/guest [post]
+func (ch *CartHandler) CreateGuestCart(c *gin.Context) {
+	cart, err := ch.cartService.CreateGuestCart()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to create cart",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: "Cart created successfully",
+		Data:    cart,
+	})
+}
+
+// GetCart godoc
+// @Summary Get cart
+// @Description Get cart contents with live prices and stock
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param currency query string false "Currency of the prices, default the base currency"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /cart [get]
+// @Router /cart/guest/{token} [get]
+func (ch *CartHandler) GetCart(c *gin.Context) {
+	owner, ok := ch.cartOwner(c)
+	if !ok {
+		return
+	}
+
+	cart, err := ch.cartService.GetCart(owner)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to get cart",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Cart retrieved successfully",
+		Data:    cart,
+	})
+}
+
+// AddItem godoc
+// @Summary Add item to cart
+// @Description Add a product to the cart or increase its quantity
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CartItemRequest true "Cart item data"
+// @Param currency query string false "Currency of the prices, default the base currency"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /cart/items [post]
+// @Router /cart/guest/{token}/items [post]
+func (ch *CartHandler) AddItem(c *gin.Context) {
+	owner, ok := ch.cartOwner(c)
+	if !ok {
+		return
+	}
+
+	var req models.CartItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	cart, err := ch.cartService.AddItem(owner, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to add item to cart",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Item added to cart successfully",
+		Data:    cart,
+	})
+}
+
+// UpdateItem godoc
+// @Summary Update cart item
+// @Description Set the quantity of a cart line (0 removes it)
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param product_id path int true "Product ID"
+// @Param variant_id query int false "Variant ID of the line"
+// @Param request body models.CartItemUpdateRequest true "Cart item update"
+// @Param currency query string false "Currency of the prices, default the base currency"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /cart/items/{product_id} [put]
+// @Router /cart/guest/{token}/items/{product_id} [put]
+func (ch *CartHandler) UpdateItem(c *gin.Context) {
+	owner, ok := ch.cartOwner(c)
+	if !ok {
+		return
+	}
+
+	productIDStr := c.Param("product_id")
+	productID, err := strconv.ParseUint(productIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid product ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	variantID, ok := ch.cartVariantID(c)
+	if !ok {
+		return
+	}
+
+	var req models.CartItemUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	cart, err := ch.cartService.UpdateItem(owner, uint(productID), variantID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to update cart item",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Cart item updated successfully",
+		Data:    cart,
+	})
+}
+
+// RemoveItem godoc
+// @Summary Remove cart item
+// @Description Remove a product from the cart
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param product_id path int true "Product ID"
+// @Param variant_id query int false "Variant ID of the line"
+// @Param currency query string false "Currency of the prices, default the base currency"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /cart/items/{product_id} [delete]
+// @Router /cart/guest/{token}/items/{product_id} [delete]
+func (ch *CartHandler) RemoveItem(c *gin.Context) {
+	owner, ok := ch.cartOwner(c)
+	if !ok {
+		return
+	}
+
+	productIDStr := c.Param("product_id")
+	productID, err := strconv.ParseUint(productIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid product ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	variantID, ok := ch.cartVariantID(c)
+	if !ok {
+		return
+	}
+
+	cart, err := ch.cartService.RemoveItem(owner, uint(productID), variantID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to remove cart item",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Cart item removed successfully",
+		Data:    cart,
+	})
+}
+
+// ClearCart godoc
+// @Summary Clear cart
+// @Description Remove all items from the cart
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /cart [delete]
+// @Router /cart/guest/{token} [delete]
+func (ch *CartHandler) ClearCart(c *gin.Context) {
+	owner, ok := ch.cartOwner(c)
+	if !ok {
+		return
+	}
+
+	if err := ch.cartService.ClearCart(owner); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to clear cart",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Cart cleared successfully",
+	})
+}
+
+// MergeCart godoc
+// @Summary Merge guest cart
+// @Description Merge an anonymous cart into the authenticated user's cart
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CartMergeRequest true "Guest cart token"
+// @Param currency query string false "Currency of the prices, default the base currency"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /cart/merge [post]
+func (ch *CartHandler) MergeCart(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "User not authenticated",
+		})
+		return
+	}
+
+	var req models.CartMergeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	cart, err := ch.cartService.MergeGuestCart(userID.(uint), c.Query("currency"), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to merge cart",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Cart merged successfully",
+		Data:    cart,
+	})
+}
+
+// Checkout godoc
+// @Summary Checkout cart
+// @Description Create an order from the cart and clear it, applying promotions and an optional coupon
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CheckoutRequest false "Coupon code, currency and shipping address"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /cart/checkout [post]
+func (ch *CartHandler) Checkout(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "User not authenticated",
+		})
+		return
+	}
+
+	// The body is optional
+	var req models.CheckoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	order, err := ch.cartService.Checkout(userID.(uint), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to checkout cart",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: "Order created successfully",
+		Data:    order,
+	})
+}
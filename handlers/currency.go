@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"net/http"
+
+	"go-shop/models"
+	"go-shop/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CurrencyHandler struct {
+	currencyService *services.CurrencyService
+}
+
+func NewCurrencyHandler(currencyService *services.CurrencyService) *CurrencyHandler {
+	return &CurrencyHandler{
+		currencyService: currencyService,
+	}
+}
+
+// GetCurrencies godoc
+// @Summary Get currencies
+// @Description Get the base currency and every currency prices can be shown and paid in
+// @Tags currencies
+// @Accept json
+// @Produce json
+// @Success 200 {object} models.SuccessResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /currencies [get]
+func (ch *CurrencyHandler) GetCurrencies(c *gin.Context) {
+	currencies, err := ch.currencyService.GetCurrencies()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to get currencies",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Currencies retrieved successfully",
+		Data:    currencies,
+	})
+}
+
+// GetExchangeRates godoc
+// @Summary Get exchange rates
+// @Description Get the exchange rates from the base currency (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /super-admin/currencies [get]
+func (ch *CurrencyHandler) GetExchangeRates(c *gin.Context) {
+	rates, err := ch.currencyService.GetExchangeRates()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to get exchange rates",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Exchange rates retrieved successfully",
+		Data:    rates,
+	})
+}
+
+// SetExchangeRate godoc
+// @Summary Set exchange rate
+// @Description Create or update the exchange rate of a currency, making it available to shoppers (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param currency path string true "ISO 4217 currency code"
+// @Param request body models.ExchangeRateRequest true "Units of the currency per unit of the base currency"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /super-admin/currencies/{currency} [put]
+func (ch *CurrencyHandler) SetExchangeRate(c *gin.Context) {
+	var req models.ExchangeRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	rate, err := ch.currencyService.SetExchangeRate(c.Param("currency"), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to set exchange rate",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Exchange rate updated successfully",
+		Data:    rate,
+	})
+}
+
+// DeleteExchangeRate godoc
+// @Summary Delete exchange rate
+// @Description Remove the exchange rate of a currency so shoppers can no longer use it; placed orders are unaffected (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param currency path string true "ISO 4217 currency code"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /super-admin/currencies/{currency} [delete]
+func (ch *CurrencyHandler) DeleteExchangeRate(c *gin.Context) {
+	if err := ch.currencyService.DeleteExchangeRate(c.Param("currency")); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to delete exchange rate",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Exchange rate deleted successfully",
+	})
+}
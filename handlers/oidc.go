@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"go-shop/models"
+	"go-shop/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetOIDCProviders godoc
+// @Summary List sign-in providers
+// @Description Names of the OpenID Connect providers users can sign in with
+// @Tags auth
+// @Produce json
+// @Success 200 {object} models.SuccessResponse
+// @Router /auth/oidc/providers [get]
+func (ah *AuthHandler) GetOIDCProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Sign-in providers retrieved successfully",
+		Data:    ah.authService.OIDCProviders(),
+	})
+}
+
+// StartOIDCLogin godoc
+// @Summary Start sign-in with a provider
+// @Description Get the provider URL to send the user to. Keep the returned state and check it against the one the provider redirects back with.
+// @Tags auth
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param cart_token query string false "Guest cart to merge after sign-in"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 502 {object} models.ErrorResponse
+// @Router /auth/oidc/{provider}/login [get]
+func (ah *AuthHandler) StartOIDCLogin(c *gin.Context) {
+	var req models.OIDCLoginRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	response, err := ah.authService.StartOIDCLogin(c.Param("provider"), &req)
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, services.ErrUnknownOIDCProvider) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, models.ErrorResponse{
+			Error:   "Failed to start sign-in",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Redirect the user to the authorization URL",
+		Data:    response,
+	})
+}
+
+// OIDCCallback godoc
+// @Summary Complete sign-in with a provider
+// @Description Called with the code and state the provider redirected back with. Returns the same tokens (or 2FA challenge) as /auth/login; new users are created activated, existing users are linked by verified email.
+// @Tags auth
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param code query string false "Authorization code"
+// @Param state query string true "State from /auth/oidc/{provider}/login"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /auth/oidc/{provider}/callback [get]
+func (ah *AuthHandler) OIDCCallback(c *gin.Context) {
+	var req models.OIDCCallbackRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	client := models.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+
+	response, err := ah.authService.CompleteOIDCLogin(c.Param("provider"), &req, client)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "Sign-in failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if response.TwoFactorRequired {
+		c.JSON(http.StatusOK, models.SuccessResponse{
+			Message: "Two-factor authentication required",
+			Data:    response,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Login successful",
+		Data:    response,
+	})
+}
@@ -11,12 +11,14 @@ import (
 )
 
 type OrderHandler struct {
-	orderService *services.OrderService
+	orderService   *services.OrderService
+	paymentService *services.PaymentService
 }
 
-func NewOrderHandler(orderService *services.OrderService) *OrderHandler {
+func NewOrderHandler(orderService *services.OrderService, paymentService *services.PaymentService) *OrderHandler {
 	return &OrderHandler{
-		orderService: orderService,
+		orderService:   orderService,
+		paymentService: paymentService,
 	}
 }
 
@@ -188,7 +190,7 @@ func (oh *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 		return
 	}
 
-	order, err := oh.orderService.UpdateOrderStatus(uint(orderID), userID.(uint), &req)
+	order, err := oh.orderService.UpdateOrderStatus(uint(orderID), buyerActor(userID.(uint)), &req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "Failed to update order",
@@ -205,7 +207,7 @@ func (oh *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 
 // CancelOrder godoc
 // @Summary Cancel order
-// @Description Cancel a user's own order
+// @Description Cancel a user's own order while it is pending or paid
 // @Tags orders
 // @Accept json
 // @Produce json
@@ -217,7 +219,7 @@ func (oh *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 // @Failure 404 {object} models.ErrorResponse
 // @Router /orders/{id}/cancel [post]
 func (oh *OrderHandler) CancelOrder(c *gin.Context) {
-	_, exists := c.Get("user_id")
+	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
 			Error: "User not authenticated",
@@ -235,7 +237,7 @@ func (oh *OrderHandler) CancelOrder(c *gin.Context) {
 		return
 	}
 
-	order, err := oh.orderService.CancelOrder(uint(orderID))
+	order, err := oh.orderService.CancelOrder(uint(orderID), buyerActor(userID.(uint)))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "Failed to cancel order",
@@ -252,7 +254,7 @@ func (oh *OrderHandler) CancelOrder(c *gin.Context) {
 
 // PayOrder godoc
 // @Summary Pay order
-// @Description Mark order as paid
+// @Description Start payment of a pending order with the configured payment provider. The order becomes paid once the provider confirms the charge via webhook.
 // @Tags orders
 // @Accept json
 // @Produce json
@@ -282,7 +284,7 @@ func (oh *OrderHandler) PayOrder(c *gin.Context) {
 		return
 	}
 
-	order, err := oh.orderService.PayOrder(uint(orderID), userID.(uint))
+	payment, err := oh.paymentService.CreatePayment(uint(orderID), userID.(uint))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "Failed to pay order",
@@ -292,7 +294,106 @@ func (oh *OrderHandler) PayOrder(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, models.SuccessResponse{
-		Message: "Order paid successfully",
-		Data:    order,
+		Message: "Payment created successfully",
+		Data:    payment,
+	})
+}
+
+// GetOrderPayments godoc
+// @Summary Get order payments
+// @Description Get all payment attempts of the user's order
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /orders/{id}/payments [get]
+func (oh *OrderHandler) GetOrderPayments(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "User not authenticated",
+		})
+		return
+	}
+
+	orderIDStr := c.Param("id")
+	orderID, err := strconv.ParseUint(orderIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid order ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	payments, err := oh.paymentService.GetOrderPayments(uint(orderID), userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to get payments",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Payments retrieved successfully",
+		Data:    payments,
 	})
 }
+
+// GetOrderHistory godoc
+// @Summary Get order status history
+// @Description Get the status transitions of a user's own order
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /orders/{id}/history [get]
+func (oh *OrderHandler) GetOrderHistory(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "User not authenticated",
+		})
+		return
+	}
+
+	orderIDStr := c.Param("id")
+	orderID, err := strconv.ParseUint(orderIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid order ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	buyerID := userID.(uint)
+	history, err := oh.orderService.GetOrderHistory(uint(orderID), &buyerID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Failed to get order history",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Order history retrieved successfully",
+		Data:    history,
+	})
+}
+
+// buyerActor is the actor of order operations made through the buyer's own order endpoints
+func buyerActor(userID uint) services.OrderActor {
+	return services.OrderActor{UserID: &userID, Role: models.ROLE_USER}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go-shop/config"
+	"go-shop/database"
+	"go-shop/models"
+	"go-shop/services"
+	"go-shop/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// The test needs Postgres at TEST_DATABASE_DSN and is skipped when it is not set
+func requireDatabase(t *testing.T, cfg *config.Config) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("failed to connect to the test database: %v", err)
+	}
+	database.DB = db
+	database.Migrate(cfg)
+}
+
+func createUser(t *testing.T, token string) *models.User {
+	t.Helper()
+	user := models.User{Email: "test-" + token + "@example.com", FirstName: "Test", LastName: "User", IsActive: true}
+	if err := database.DB.Create(&user).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	return &user
+}
+
+func TestGetOrderShowsTracking(t *testing.T) {
+	cfg := &config.Config{
+		Payment:  config.PaymentConfig{Provider: services.SandboxProviderName, WebhookSecret: "test-webhook-secret"},
+		Currency: config.CurrencyConfig{Base: "USD"},
+		Shipping: config.ShippingConfig{Carriers: services.FakeCarrierName},
+	}
+	requireDatabase(t, cfg)
+
+	token, err := utils.GenerateRandomToken(6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buyer := createUser(t, token)
+	admin := createUser(t, token+"-admin")
+
+	order := models.Order{
+		UserID:          buyer.ID,
+		OrderNumber:     "TEST-" + token,
+		Status:          models.OrderStatusConfirmed,
+		Currency:        "USD",
+		Subtotal:        2500,
+		TotalAmount:     2500,
+		ShippingCarrier: services.FakeCarrierName,
+	}
+	if err := database.DB.Create(&order).Error; err != nil {
+		t.Fatalf("failed to create order: %v", err)
+	}
+	if err := database.DB.Create(&models.Shipment{OrderID: order.ID, Status: models.ShipmentStatusConfirmed}).Error; err != nil {
+		t.Fatalf("failed to create shipment: %v", err)
+	}
+
+	paymentService := services.NewPaymentService(cfg, services.NewSandboxPaymentProvider(cfg.Payment.WebhookSecret))
+	orderService := services.NewOrderService(cfg, paymentService, services.NewCarriers(cfg))
+	actor := services.OrderActor{UserID: &admin.ID, Role: models.ROLE_SUPER_ADMIN}
+	if _, err := orderService.ShipOrder(order.ID, actor, &models.ShipmentShipRequest{}); err != nil {
+		t.Fatalf("failed to ship order: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/orders/:id", func(c *gin.Context) {
+		c.Set("user_id", buyer.ID)
+	}, NewOrderHandler(orderService, paymentService).GetOrderByID)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/orders/"+strconv.FormatUint(uint64(order.ID), 10), nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	var response struct {
+		Data models.OrderResponse `json:"data"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Data.Status != models.OrderStatusShipped {
+		t.Fatalf("expected a shipped order, got %s", response.Data.Status)
+	}
+	if len(response.Data.Shipments) != 1 {
+		t.Fatalf("expected one shipment, got %d", len(response.Data.Shipments))
+	}
+	shipment := response.Data.Shipments[0]
+	if shipment.Status != models.ShipmentStatusShipped || shipment.Carrier != services.FakeCarrierName || shipment.ShippedAt == nil {
+		t.Fatalf("expected a shipment shipped with the fake carrier, got %+v", shipment)
+	}
+	if !strings.HasPrefix(shipment.TrackingNumber, "FAKE") || len(shipment.TrackingNumber) <= len("FAKE") {
+		t.Fatalf("expected a fake carrier tracking number, got %q", shipment.TrackingNumber)
+	}
+}
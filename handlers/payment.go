@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"go-shop/models"
+	"go-shop/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PaymentSignatureHeader carries the provider signature of a webhook payload
+const PaymentSignatureHeader = "X-Payment-Signature"
+
+type PaymentHandler struct {
+	paymentService *services.PaymentService
+}
+
+func NewPaymentHandler(paymentService *services.PaymentService) *PaymentHandler {
+	return &PaymentHandler{
+		paymentService: paymentService,
+	}
+}
+
+// Webhook godoc
+// @Summary Payment provider webhook
+// @Description Receive a signed payment notification from the payment provider
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param X-Payment-Signature header string true "Payload signature"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /payments/webhook [post]
+func (ph *PaymentHandler) Webhook(c *gin.Context) {
+	payload, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := ph.paymentService.HandleWebhook(payload, c.GetHeader(PaymentSignatureHeader)); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrInvalidWebhookSignature) {
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, models.ErrorResponse{
+			Error:   "Failed to process webhook",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Webhook processed successfully",
+	})
+}
+
+// SimulateSandboxPayment godoc
+// @Summary Simulate sandbox payment
+// @Description Complete a sandbox payment with the given outcome (sandbox provider outside release mode, Admin only)
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param reference path string true "Provider reference"
+// @Param request body models.SandboxPaymentRequest true "Payment outcome"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /super-admin/payments/sandbox/{reference} [post]
+func (ph *PaymentHandler) SimulateSandboxPayment(c *gin.Context) {
+	var req models.SandboxPaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	payment, err := ph.paymentService.SimulateSandboxPayment(c.Param("reference"), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to process sandbox payment",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Sandbox payment processed successfully",
+		Data:    payment,
+	})
+}
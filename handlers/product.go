@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"regexp"
 	"strconv"
+	"strings"
 
 	"go-shop/models"
 	"go-shop/services"
@@ -27,9 +31,11 @@ func NewProductHandler(productService *services.ProductService) *ProductHandler
 // @Accept json
 // @Produce json
 // @Param category_id query int false "Filter by category ID"
+// @Param currency query string false "Currency of the prices, default the base currency"
 // @Param limit query int false "Limit results" default(20)
 // @Param offset query int false "Offset results" default(0)
 // @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /products [get]
 func (ph *ProductHandler) GetProducts(c *gin.Context) {
@@ -55,9 +61,13 @@ func (ph *ProductHandler) GetProducts(c *gin.Context) {
 		}
 	}
 
-	products, err := ph.productService.GetProducts(categoryID, limit, offset)
+	products, err := ph.productService.GetProducts(categoryID, nil, c.Query("currency"), limit, offset)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrUnsupportedCurrency) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, models.ErrorResponse{
 			Error:   "Failed to get products",
 			Message: err.Error(),
 		})
@@ -77,6 +87,7 @@ func (ph *ProductHandler) GetProducts(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param id path int true "Product ID"
+// @Param currency query string false "Currency of the prices, default the base currency"
 // @Success 200 {object} models.SuccessResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
@@ -92,8 +103,15 @@ func (ph *ProductHandler) GetProductByID(c *gin.Context) {
 		return
 	}
 
-	product, err := ph.productService.GetProductByID(uint(productID))
+	product, err := ph.productService.GetProductByID(uint(productID), c.Query("currency"))
 	if err != nil {
+		if errors.Is(err, services.ErrUnsupportedCurrency) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid currency",
+				Message: err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusNotFound, models.ErrorResponse{
 			Error:   "Product not found",
 			Message: err.Error(),
@@ -109,15 +127,18 @@ func (ph *ProductHandler) GetProductByID(c *gin.Context) {
 
 // SearchProducts godoc
 // @Summary Search products
-// @Description Search products with filters and sorting
+// @Description Full-text search over title, description, model and category name with filters and sorting
 // @Tags products
 // @Accept json
 // @Produce json
-// @Param title query string false "Search by title"
+// @Param q query string false "Search text (prefix matching on every word)"
+// @Param title query string false "Deprecated alias of q"
 // @Param category_id query int false "Filter by category ID"
-// @Param min_price query number false "Minimum price"
-// @Param max_price query number false "Maximum price"
-// @Param sort_by query string false "Sort by: price_asc, price_desc, popularity_asc, popularity_desc, created_at_asc, created_at_desc"
+// @Param min_price query number false "Minimum price in the base currency"
+// @Param max_price query number false "Maximum price in the base currency"
+// @Param currency query string false "Currency of the returned prices, default the base currency"
+// @Param sort_by query string false "Sort by: relevance (default with q), price_asc, price_desc, popularity_asc, popularity_desc, created_at_asc, created_at_desc"
+// @Param attr.{name} query string false "Filter by ExtraInfo attribute, repeatable (e.g. attr.color=red&attr.color=blue)"
 // @Param limit query int false "Limit results" default(20)
 // @Param offset query int false "Offset results" default(0)
 // @Success 200 {object} models.SuccessResponse
@@ -133,6 +154,16 @@ func (ph *ProductHandler) SearchProducts(c *gin.Context) {
 		return
 	}
 
+	attributes, err := parseAttributeFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid attribute filter",
+			Message: err.Error(),
+		})
+		return
+	}
+	req.Attributes = attributes
+
 	// Get user ID for logging (optional)
 	var userID *uint
 	if uid, exists := c.Get("user_id"); exists {
@@ -142,6 +173,19 @@ func (ph *ProductHandler) SearchProducts(c *gin.Context) {
 
 	// Search products
 	products, total, err := ph.productService.SearchProducts(&req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrUnsupportedCurrency) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, models.ErrorResponse{
+			Error:   "Failed to search products",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	facets, err := ph.productService.SearchFacets(&req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "Failed to search products",
@@ -151,27 +195,104 @@ func (ph *ProductHandler) SearchProducts(c *gin.Context) {
 	}
 
 	// Log search query
-	if userID != nil || req.Title != "" {
+	if userID != nil || req.Query != "" {
 		filters := models.JSONB{
 			"category_id": req.CategoryID,
 			"min_price":   req.MinPrice,
 			"max_price":   req.MaxPrice,
 			"sort_by":     req.SortBy,
 		}
-		ph.productService.LogSearch(userID, req.Title, filters, len(products))
+		if len(req.Attributes) > 0 {
+			filters["attributes"] = req.Attributes
+		}
+		ph.productService.LogSearch(userID, req.Query, filters, len(products))
 	}
 
 	// Prepare response
 	response := gin.H{
 		"products": products,
+		"facets":   facets,
 		"total":    total,
 		"limit":    req.Limit,
 		"offset":   req.Offset,
 		"has_more": int64(req.Offset+req.Limit) < total,
 	}
 
+	// Suggest corrections when the query found nothing; a failure here does not fail the search
+	if total == 0 && req.Query != "" {
+		if suggestions, err := ph.productService.DidYouMean(req.Query); err == nil && len(suggestions) > 0 {
+			response["did_you_mean"] = suggestions
+		}
+	}
+
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Message: "Products found successfully",
 		Data:    response,
 	})
 }
+
+// SuggestProducts godoc
+// @Summary Search autocomplete
+// @Description Product title and category completions of a partially typed query, ranked by order count
+// @Tags products
+// @Accept json
+// @Produce json
+// @Param q query string true "Typed text"
+// @Param limit query int false "Limit per kind" default(8)
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Router /products/suggest [get]
+func (ph *ProductHandler) SuggestProducts(c *gin.Context) {
+	var req models.SearchSuggestRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request parameters",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	suggestions, err := ph.productService.Suggest(&req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to get suggestions",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Suggestions retrieved successfully",
+		Data:    suggestions,
+	})
+}
+
+// maxAttributeFilters bounds the number of distinct attr.<name> filters per search
+const maxAttributeFilters = 10
+
+// attributeName restricts ExtraInfo keys usable in filters
+var attributeName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// parseAttributeFilters collects attr.<name>=<value> query parameters
+func parseAttributeFilters(c *gin.Context) (map[string][]string, error) {
+	attributes := make(map[string][]string)
+	for key, values := range c.Request.URL.Query() {
+		name, ok := strings.CutPrefix(key, "attr.")
+		if !ok {
+			continue
+		}
+		if !attributeName.MatchString(name) {
+			return nil, fmt.Errorf("invalid attribute name %q", name)
+		}
+		for _, value := range values {
+			if value != "" {
+				attributes[name] = append(attributes[name], value)
+			}
+		}
+	}
+
+	if len(attributes) > maxAttributeFilters {
+		return nil, fmt.Errorf("at most %d attribute filters are allowed", maxAttributeFilters)
+	}
+	return attributes, nil
+}
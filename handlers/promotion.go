@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"go-shop/models"
+	"go-shop/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PromotionHandler struct {
+	promotionService *services.PromotionService
+}
+
+func NewPromotionHandler(promotionService *services.PromotionService) *PromotionHandler {
+	return &PromotionHandler{
+		promotionService: promotionService,
+	}
+}
+
+// CreatePromotion godoc
+// @Summary Create a promotion
+// @Description Create a coupon, or an automatic promotion when no code is given (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.PromotionRequest true "Promotion data"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /super-admin/promotions [post]
+func (ph *PromotionHandler) CreatePromotion(c *gin.Context) {
+	var req models.PromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	promotion, err := ph.promotionService.CreatePromotion(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to create promotion",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: "Promotion created successfully",
+		Data:    promotion,
+	})
+}
+
+// GetPromotions godoc
+// @Summary Get promotions
+// @Description Get coupons and automatic promotions with their usage, newest first (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Limit results" default(20)
+// @Param offset query int false "Offset results" default(0)
+// @Success 200 {object} models.SuccessResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /super-admin/promotions [get]
+func (ph *PromotionHandler) GetPromotions(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil {
+		limit = 20
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		offset = 0
+	}
+
+	promotions, err := ph.promotionService.GetPromotions(limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to get promotions",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Promotions retrieved successfully",
+		Data:    promotions,
+	})
+}
+
+// GetPromotionByID godoc
+// @Summary Get a promotion
+// @Description Get a promotion with its usage (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Promotion ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /super-admin/promotions/{id} [get]
+func (ph *PromotionHandler) GetPromotionByID(c *gin.Context) {
+	promotionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid promotion ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	promotion, err := ph.promotionService.GetPromotionByID(uint(promotionID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Promotion not found",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Promotion retrieved successfully",
+		Data:    promotion,
+	})
+}
+
+// UpdatePromotion godoc
+// @Summary Update a promotion
+// @Description Replace all settings of a promotion; orders already placed keep their discounts (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Promotion ID"
+// @Param request body models.PromotionRequest true "Promotion data"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /super-admin/promotions/{id} [put]
+func (ph *PromotionHandler) UpdatePromotion(c *gin.Context) {
+	promotionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid promotion ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var req models.PromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	promotion, err := ph.promotionService.UpdatePromotion(uint(promotionID), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to update promotion",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Promotion updated successfully",
+		Data:    promotion,
+	})
+}
+
+// DeletePromotion godoc
+// @Summary Delete a promotion
+// @Description Delete a promotion; orders already placed keep their discounts (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Promotion ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /super-admin/promotions/{id} [delete]
+func (ph *PromotionHandler) DeletePromotion(c *gin.Context) {
+	promotionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid promotion ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := ph.promotionService.DeletePromotion(uint(promotionID)); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to delete promotion",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Promotion deleted successfully",
+	})
+}
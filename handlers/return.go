@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"go-shop/middleware"
+	"go-shop/models"
+	"go-shop/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReturnHandler struct {
+	returnService *services.ReturnService
+}
+
+func NewReturnHandler(returnService *services.ReturnService) *ReturnHandler {
+	return &ReturnHandler{
+		returnService: returnService,
+	}
+}
+
+// CreateReturn godoc
+// @Summary Request a return
+// @Description Request a return of delivered order items within the return window
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Param request body models.ReturnCreateRequest true "Return request data"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /orders/{id}/returns [post]
+func (rh *ReturnHandler) CreateReturn(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "User not authenticated",
+		})
+		return
+	}
+
+	orderIDStr := c.Param("id")
+	orderID, err := strconv.ParseUint(orderIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid order ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var req models.ReturnCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	orderReturn, err := rh.returnService.CreateReturn(uint(orderID), userID.(uint), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to request return",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: "Return requested successfully",
+		Data:    orderReturn,
+	})
+}
+
+// GetOrderReturns godoc
+// @Summary Get order returns
+// @Description Get return requests of the user's order
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /orders/{id}/returns [get]
+func (rh *ReturnHandler) GetOrderReturns(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "User not authenticated",
+		})
+		return
+	}
+
+	orderIDStr := c.Param("id")
+	orderID, err := strconv.ParseUint(orderIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid order ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	returns, err := rh.returnService.GetOrderReturns(uint(orderID), userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to get returns",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Returns retrieved successfully",
+		Data:    returns,
+	})
+}
+
+// GetReturns godoc
+// @Summary Get return requests
+// @Description Get return requests for review (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Filter by status: requested, approved, rejected"
+// @Param limit query int false "Limit results" default(20)
+// @Param offset query int false "Offset results" default(0)
+// @Success 200 {object} models.SuccessResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /super-admin/orders/returns [get]
+func (rh *ReturnHandler) GetReturns(c *gin.Context) {
+	limitStr := c.DefaultQuery("limit", "20")
+	offsetStr := c.DefaultQuery("offset", "0")
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil {
+		limit = 20
+	}
+
+	offset, err := strconv.Atoi(offsetStr)
+	if err != nil {
+		offset = 0
+	}
+
+	returns, err := rh.returnService.GetReturns(c.Query("status"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to get returns",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Returns retrieved successfully",
+		Data:    returns,
+	})
+}
+
+// ApproveReturn godoc
+// @Summary Approve return
+// @Description Approve a return, restock the items and refund the buyer (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param return_id path int true "Return ID"
+// @Param request body models.ReturnApproveRequest true "Approval data"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /super-admin/orders/returns/{return_id}/approve [post]
+func (rh *ReturnHandler) ApproveReturn(c *gin.Context) {
+	returnIDStr := c.Param("return_id")
+	returnID, err := strconv.ParseUint(returnIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid return ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var req models.ReturnApproveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// Get current user ID for logging
+	currentUserID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "User not authenticated",
+		})
+		return
+	}
+
+	orderReturn, err := rh.returnService.ApproveReturn(uint(returnID), currentUserID.(uint), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to approve return",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// Log sensitive operation
+	middleware.LogSensitiveOperation("RETURN_APPROVED", currentUserID.(uint),
+		"Return ID: "+returnIDStr+", Refund Amount: "+orderReturn.RefundAmount.String())
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Return approved successfully",
+		Data:    orderReturn,
+	})
+}
+
+// RejectReturn godoc
+// @Summary Reject return
+// @Description Reject a return request (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param return_id path int true "Return ID"
+// @Param request body models.ReturnRejectRequest true "Rejection data"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /super-admin/orders/returns/{return_id}/reject [post]
+func (rh *ReturnHandler) RejectReturn(c *gin.Context) {
+	returnIDStr := c.Param("return_id")
+	returnID, err := strconv.ParseUint(returnIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid return ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var req models.ReturnRejectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	currentUserID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "User not authenticated",
+		})
+		return
+	}
+
+	orderReturn, err := rh.returnService.RejectReturn(uint(returnID), currentUserID.(uint), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to reject return",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Return rejected successfully",
+		Data:    orderReturn,
+	})
+}
@@ -152,13 +152,7 @@ func (rh *RoleHandler) GetAllRoles(c *gin.Context) {
 	// Конвертируем в response format
 	var roleResponses []models.RoleResponse
 	for _, role := range roles {
-		roleResponses = append(roleResponses, models.RoleResponse{
-			ID:          role.ID,
-			Name:        role.Name,
-			Description: role.Description,
-			CreatedAt:   role.CreatedAt,
-			UpdatedAt:   role.UpdatedAt,
-		})
+		roleResponses = append(roleResponses, roleResponse(role))
 	}
 
 	c.JSON(http.StatusOK, models.SuccessResponse{
@@ -196,17 +190,78 @@ func (rh *RoleHandler) CreateRole(c *gin.Context) {
 		return
 	}
 
-	roleResponse := models.RoleResponse{
-		ID:          role.ID,
-		Name:        role.Name,
-		Description: role.Description,
-		CreatedAt:   role.CreatedAt,
-		UpdatedAt:   role.UpdatedAt,
-	}
-
 	c.JSON(http.StatusCreated, models.SuccessResponse{
 		Message: "Role created successfully",
-		Data:    roleResponse,
+		Data:    roleResponse(*role),
+	})
+}
+
+// GetAllPermissions возвращает все права, которые можно выдать ролям
+func (rh *RoleHandler) GetAllPermissions(c *gin.Context) {
+	permissions, err := rh.roleService.GetAllPermissions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to get permissions",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var permissionResponses []models.PermissionResponse
+	for _, permission := range permissions {
+		permissionResponses = append(permissionResponses, models.PermissionResponse{
+			ID:          permission.ID,
+			Name:        permission.Name,
+			Description: permission.Description,
+		})
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Permissions retrieved successfully",
+		Data:    permissionResponses,
+	})
+}
+
+// SetRolePermissions заменяет права роли
+func (rh *RoleHandler) SetRolePermissions(c *gin.Context) {
+	roleID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid role ID",
+		})
+		return
+	}
+
+	var req models.RolePermissionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// Получаем ID текущего пользователя из контекста
+	currentUserID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "User not authenticated",
+		})
+		return
+	}
+
+	role, err := rh.roleService.SetRolePermissions(uint(roleID), req.Permissions, currentUserID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to update role permissions",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Role permissions updated successfully",
+		Data:    roleResponse(*role),
 	})
 }
 
@@ -230,10 +285,20 @@ func (rh *RoleHandler) GetUserRole(c *gin.Context) {
 		return
 	}
 
+	permissions, err := rh.roleService.GetUserPermissions(uint(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to get user permissions",
+			Message: err.Error(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Message: "User role retrieved successfully",
-		Data: map[string]string{
-			"role": role,
+		Data: map[string]interface{}{
+			"role":        role,
+			"permissions": permissions,
 		},
 	})
 }
@@ -279,3 +344,20 @@ func (rh *RoleHandler) GetAllUsersWithRoles(c *gin.Context) {
 		Data:    userResponses,
 	})
 }
+
+// roleResponse converts a role and its loaded permissions into the response format
+func roleResponse(role models.Role) models.RoleResponse {
+	permissions := make([]string, 0, len(role.Permissions))
+	for _, permission := range role.Permissions {
+		permissions = append(permissions, permission.Name)
+	}
+
+	return models.RoleResponse{
+		ID:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: permissions,
+		CreatedAt:   role.CreatedAt,
+		UpdatedAt:   role.UpdatedAt,
+	}
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"net/http"
+
+	"go-shop/models"
+	"go-shop/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SearchAnalyticsHandler struct {
+	searchAnalyticsService *services.SearchAnalyticsService
+}
+
+func NewSearchAnalyticsHandler(searchAnalyticsService *services.SearchAnalyticsService) *SearchAnalyticsHandler {
+	return &SearchAnalyticsHandler{
+		searchAnalyticsService: searchAnalyticsService,
+	}
+}
+
+// GetTopQueries godoc
+// @Summary Top search queries
+// @Description Most frequent search queries of the period (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param from query string false "Start date (YYYY-MM-DD), default 30 days ago"
+// @Param to query string false "End date (YYYY-MM-DD), default today"
+// @Param limit query int false "Limit results" default(20)
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /super-admin/search/top-queries [get]
+func (sah *SearchAnalyticsHandler) GetTopQueries(c *gin.Context) {
+	var req models.SearchAnalyticsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request parameters",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	report, err := sah.searchAnalyticsService.GetTopQueries(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to get top queries",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Top queries retrieved successfully",
+		Data:    report,
+	})
+}
+
+// GetZeroResultQueries godoc
+// @Summary Zero-result search queries
+// @Description Search queries that returned no products, most frequent first (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param from query string false "Start date (YYYY-MM-DD), default 30 days ago"
+// @Param to query string false "End date (YYYY-MM-DD), default today"
+// @Param limit query int false "Limit results" default(20)
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /super-admin/search/zero-results [get]
+func (sah *SearchAnalyticsHandler) GetZeroResultQueries(c *gin.Context) {
+	var req models.SearchAnalyticsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request parameters",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	report, err := sah.searchAnalyticsService.GetZeroResultQueries(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to get zero-result queries",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Zero-result queries retrieved successfully",
+		Data:    report,
+	})
+}
+
+// GetConversion godoc
+// @Summary Search conversion
+// @Description Share of signed-in users' searches followed by an order of the same user within SEARCH_CONVERSION_WINDOW_HOURS (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param from query string false "Start date (YYYY-MM-DD), default 30 days ago"
+// @Param to query string false "End date (YYYY-MM-DD), default today"
+// @Param limit query int false "Limit results" default(20)
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /super-admin/search/conversion [get]
+func (sah *SearchAnalyticsHandler) GetConversion(c *gin.Context) {
+	var req models.SearchAnalyticsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request parameters",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	report, err := sah.searchAnalyticsService.GetConversion(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to get search conversion",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Search conversion retrieved successfully",
+		Data:    report,
+	})
+}
+
+// GetTrends godoc
+// @Summary Search trends
+// @Description Search volume per day or week (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param from query string false "Start date (YYYY-MM-DD), default 30 days ago"
+// @Param to query string false "End date (YYYY-MM-DD), default today"
+// @Param interval query string false "Period: day, week" default(day)
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /super-admin/search/trends [get]
+func (sah *SearchAnalyticsHandler) GetTrends(c *gin.Context) {
+	var req models.SearchAnalyticsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request parameters",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	report, err := sah.searchAnalyticsService.GetTrends(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to get search trends",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Search trends retrieved successfully",
+		Data:    report,
+	})
+}
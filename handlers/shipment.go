@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"go-shop/middleware"
+	"go-shop/models"
+	"go-shop/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ShipmentHandler struct {
+	orderService *services.OrderService
+}
+
+func NewShipmentHandler(orderService *services.OrderService) *ShipmentHandler {
+	return &ShipmentHandler{
+		orderService: orderService,
+	}
+}
+
+// GetShipments godoc
+// @Summary Get shipments
+// @Description Get shipments to fulfil (Admin/Seller only); sellers only see their own shipments
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Filter by status: pending, confirmed, shipped, delivered, cancelled"
+// @Param limit query int false "Limit results" default(20)
+// @Param offset query int false "Offset results" default(0)
+// @Success 200 {object} models.SuccessResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /seller/shipments [get]
+// @Router /super-admin/shipments [get]
+func (sh *ShipmentHandler) GetShipments(c *gin.Context) {
+	limitStr := c.DefaultQuery("limit", "20")
+	offsetStr := c.DefaultQuery("offset", "0")
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil {
+		limit = 20
+	}
+
+	offset, err := strconv.Atoi(offsetStr)
+	if err != nil {
+		offset = 0
+	}
+
+	shipments, err := sh.orderService.GetShipments(sellerScope(c, models.PermissionOrdersManage), c.Query("status"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to get shipments",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Shipments retrieved successfully",
+		Data:    shipments,
+	})
+}
+
+// ConfirmShipment godoc
+// @Summary Confirm shipment
+// @Description Confirm one shipment of a paid order and update stock (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Shipment ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /super-admin/shipments/{id}/confirm [post]
+func (sh *ShipmentHandler) ConfirmShipment(c *gin.Context) {
+	shipmentIDStr := c.Param("id")
+	shipmentID, err := strconv.ParseUint(shipmentIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid shipment ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	currentUserID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "User not authenticated",
+		})
+		return
+	}
+
+	shipment, err := sh.orderService.ConfirmShipment(uint(shipmentID), orderActor(c, currentUserID.(uint)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to confirm shipment",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// Log sensitive operation
+	middleware.LogSensitiveOperation("SHIPMENT_CONFIRMED", currentUserID.(uint),
+		"Shipment ID: "+shipmentIDStr+", Order ID: "+strconv.FormatUint(uint64(shipment.OrderID), 10))
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Shipment confirmed successfully",
+		Data:    shipment,
+	})
+}
+
+// ShipShipment godoc
+// @Summary Ship shipment
+// @Description Mark one shipment as shipped with a carrier and tracking number, issued by the carrier when none is given (Admin/Seller only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Shipment ID"
+// @Param request body models.ShipmentShipRequest false "Carrier and tracking number"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /seller/shipments/{id}/ship [post]
+// @Router /super-admin/shipments/{id}/ship [post]
+func (sh *ShipmentHandler) ShipShipment(c *gin.Context) {
+	shipmentIDStr := c.Param("id")
+	shipmentID, err := strconv.ParseUint(shipmentIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid shipment ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	currentUserID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "User not authenticated",
+		})
+		return
+	}
+
+	var req models.ShipmentShipRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid request data",
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+
+	shipment, err := sh.orderService.ShipShipment(uint(shipmentID), orderActor(c, currentUserID.(uint)), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to ship shipment",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// Log sensitive operation
+	middleware.LogSensitiveOperation("SHIPMENT_SHIPPED", currentUserID.(uint),
+		"Shipment ID: "+shipmentIDStr+", Order ID: "+strconv.FormatUint(uint64(shipment.OrderID), 10))
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Shipment shipped successfully",
+		Data:    shipment,
+	})
+}
+
+// DeliverShipment godoc
+// @Summary Deliver shipment
+// @Description Mark one shipment as delivered (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Shipment ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /super-admin/shipments/{id}/deliver [post]
+func (sh *ShipmentHandler) DeliverShipment(c *gin.Context) {
+	shipmentIDStr := c.Param("id")
+	shipmentID, err := strconv.ParseUint(shipmentIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid shipment ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	currentUserID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "User not authenticated",
+		})
+		return
+	}
+
+	shipment, err := sh.orderService.DeliverShipment(uint(shipmentID), orderActor(c, currentUserID.(uint)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to deliver shipment",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// Log sensitive operation
+	middleware.LogSensitiveOperation("SHIPMENT_DELIVERED", currentUserID.(uint),
+		"Shipment ID: "+shipmentIDStr+", Order ID: "+strconv.FormatUint(uint64(shipment.OrderID), 10))
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Shipment delivered successfully",
+		Data:    shipment,
+	})
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"go-shop/models"
+	"go-shop/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ShippingHandler struct {
+	shippingService *services.ShippingService
+}
+
+func NewShippingHandler(shippingService *services.ShippingService) *ShippingHandler {
+	return &ShippingHandler{
+		shippingService: shippingService,
+	}
+}
+
+// GetShippingMethods godoc
+// @Summary Get shipping methods
+// @Description Get the shipping methods shoppers can choose, with their rates in the requested currency
+// @Tags shipping
+// @Accept json
+// @Produce json
+// @Param currency query string false "Currency of the rates (defaults to the base currency)"
+// @Param country query string false "Only methods delivering to this ISO 3166-1 alpha-2 country"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Router /shipping-methods [get]
+func (sh *ShippingHandler) GetShippingMethods(c *gin.Context) {
+	methods, err := sh.shippingService.GetShippingMethods(c.Query("currency"), c.Query("country"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrUnsupportedCurrency) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, models.ErrorResponse{
+			Error:   "Failed to get shipping methods",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Shipping methods retrieved successfully",
+		Data:    methods,
+	})
+}
+
+// GetAllShippingMethods godoc
+// @Summary Get all shipping methods
+// @Description Get every shipping method, inactive ones included, in the base currency (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /super-admin/shipping-methods [get]
+func (sh *ShippingHandler) GetAllShippingMethods(c *gin.Context) {
+	methods, err := sh.shippingService.GetAllShippingMethods()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to get shipping methods",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Shipping methods retrieved successfully",
+		Data:    methods,
+	})
+}
+
+// GetShippingMethodByID godoc
+// @Summary Get a shipping method
+// @Description Get a shipping method in the base currency (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Shipping method ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /super-admin/shipping-methods/{id} [get]
+func (sh *ShippingHandler) GetShippingMethodByID(c *gin.Context) {
+	methodID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid shipping method ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	method, err := sh.shippingService.GetShippingMethodByID(uint(methodID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Shipping method not found",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Shipping method retrieved successfully",
+		Data:    method,
+	})
+}
+
+// CreateShippingMethod godoc
+// @Summary Create a shipping method
+// @Description Create a flat or weight-based shipping method, optionally free over an amount, priced in the base currency (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.ShippingMethodRequest true "Shipping method data"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /super-admin/shipping-methods [post]
+func (sh *ShippingHandler) CreateShippingMethod(c *gin.Context) {
+	var req models.ShippingMethodRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	method, err := sh.shippingService.CreateShippingMethod(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to create shipping method",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: "Shipping method created successfully",
+		Data:    method,
+	})
+}
+
+// UpdateShippingMethod godoc
+// @Summary Update a shipping method
+// @Description Replace all settings of a shipping method; orders already placed keep their shipping cost (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Shipping method ID"
+// @Param request body models.ShippingMethodRequest true "Shipping method data"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /super-admin/shipping-methods/{id} [put]
+func (sh *ShippingHandler) UpdateShippingMethod(c *gin.Context) {
+	methodID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid shipping method ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var req models.ShippingMethodRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	method, err := sh.shippingService.UpdateShippingMethod(uint(methodID), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to update shipping method",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Shipping method updated successfully",
+		Data:    method,
+	})
+}
+
+// DeleteShippingMethod godoc
+// @Summary Delete a shipping method
+// @Description Delete a shipping method; orders already placed keep their shipping cost and carrier (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Shipping method ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /super-admin/shipping-methods/{id} [delete]
+func (sh *ShippingHandler) DeleteShippingMethod(c *gin.Context) {
+	methodID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid shipping method ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := sh.shippingService.DeleteShippingMethod(uint(methodID)); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to delete shipping method",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Shipping method deleted successfully",
+	})
+}
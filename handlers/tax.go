@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"go-shop/models"
+	"go-shop/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TaxHandler struct {
+	taxService *services.TaxService
+}
+
+func NewTaxHandler(taxService *services.TaxService) *TaxHandler {
+	return &TaxHandler{
+		taxService: taxService,
+	}
+}
+
+// CreateTaxRate godoc
+// @Summary Create a tax rate
+// @Description Create a tax rate for a country, optionally limited to a region and a product category (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.TaxRateRequest true "Tax rate data"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /super-admin/taxes [post]
+func (th *TaxHandler) CreateTaxRate(c *gin.Context) {
+	var req models.TaxRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	rate, err := th.taxService.CreateTaxRate(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to create tax rate",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: "Tax rate created successfully",
+		Data:    rate,
+	})
+}
+
+// GetTaxRates godoc
+// @Summary Get tax rates
+// @Description Get the tax rates, optionally of one country (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param country query string false "ISO 3166-1 alpha-2 country code"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /super-admin/taxes [get]
+func (th *TaxHandler) GetTaxRates(c *gin.Context) {
+	rates, err := th.taxService.GetTaxRates(c.Query("country"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to get tax rates",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Tax rates retrieved successfully",
+		Data:    rates,
+	})
+}
+
+// GetTaxRateByID godoc
+// @Summary Get a tax rate
+// @Description Get a tax rate (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Tax rate ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /super-admin/taxes/{id} [get]
+func (th *TaxHandler) GetTaxRateByID(c *gin.Context) {
+	rateID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid tax rate ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	rate, err := th.taxService.GetTaxRateByID(uint(rateID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Tax rate not found",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Tax rate retrieved successfully",
+		Data:    rate,
+	})
+}
+
+// UpdateTaxRate godoc
+// @Summary Update a tax rate
+// @Description Replace all settings of a tax rate; orders already placed keep their taxes (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Tax rate ID"
+// @Param request body models.TaxRateRequest true "Tax rate data"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /super-admin/taxes/{id} [put]
+func (th *TaxHandler) UpdateTaxRate(c *gin.Context) {
+	rateID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid tax rate ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var req models.TaxRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	rate, err := th.taxService.UpdateTaxRate(uint(rateID), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to update tax rate",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Tax rate updated successfully",
+		Data:    rate,
+	})
+}
+
+// DeleteTaxRate godoc
+// @Summary Delete a tax rate
+// @Description Delete a tax rate; orders already placed keep their taxes (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Tax rate ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /super-admin/taxes/{id} [delete]
+func (th *TaxHandler) DeleteTaxRate(c *gin.Context) {
+	rateID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid tax rate ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := th.taxService.DeleteTaxRate(uint(rateID)); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to delete tax rate",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Tax rate deleted successfully",
+	})
+}
@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"net/http"
+
+	"go-shop/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EnrollTwoFactorChallenge godoc
+// @Summary Enroll in 2FA during login
+// @Description Create a TOTP secret for an account whose role requires two-factor authentication but has not enrolled yet. Confirm it by sending a code to /auth/2fa/verify.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.TwoFactorChallengeRequest true "Login challenge"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /auth/2fa/enroll [post]
+func (ah *AuthHandler) EnrollTwoFactorChallenge(c *gin.Context) {
+	var req models.TwoFactorChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	response, err := ah.authService.EnrollTwoFactorChallenge(&req)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "Two-factor enrollment failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Add the secret to your authenticator app and verify a code to finish logging in",
+		Data:    response,
+	})
+}
+
+// VerifyTwoFactor godoc
+// @Summary Verify 2FA code
+// @Description Complete a login with a TOTP code or a recovery code and get the session tokens
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.TwoFactorVerifyRequest true "Challenge token and code"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
+// @Router /auth/2fa/verify [post]
+func (ah *AuthHandler) VerifyTwoFactor(c *gin.Context) {
+	var req models.TwoFactorVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if req.Code == "" && req.RecoveryCode == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: "code or recovery_code is required",
+		})
+		return
+	}
+
+	response, err := ah.authService.VerifyTwoFactor(&req)
+	if respondTooManyAttempts(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "Two-factor verification failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Login successful",
+		Data:    response,
+	})
+}
+
+// EnrollTwoFactor godoc
+// @Summary Start 2FA enrollment
+// @Description Create a TOTP secret and otpauth:// URI for the current user; 2FA is enabled once a code is confirmed
+// @Tags user
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /user/2fa/enroll [post]
+func (ah *AuthHandler) EnrollTwoFactor(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "User not authenticated",
+		})
+		return
+	}
+
+	response, err := ah.authService.EnrollTwoFactor(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Two-factor enrollment failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Add the secret to your authenticator app and confirm a code",
+		Data:    response,
+	})
+}
+
+// ConfirmTwoFactor godoc
+// @Summary Confirm 2FA enrollment
+// @Description Enable two-factor authentication with a code from the authenticator app. The recovery codes are only shown once.
+// @Tags user
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.TwoFactorCodeRequest true "TOTP code"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /user/2fa/confirm [post]
+func (ah *AuthHandler) ConfirmTwoFactor(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "User not authenticated",
+		})
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	response, err := ah.authService.ConfirmTwoFactor(userID.(uint), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Two-factor confirmation failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Two-factor authentication enabled",
+		Data:    response,
+	})
+}
+
+// DisableTwoFactor godoc
+// @Summary Disable 2FA
+// @Description Turn off two-factor authentication with a TOTP or recovery code. Not allowed for users whose roles grant any permission (super admins, sellers, custom staff roles).
+// @Tags user
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.TwoFactorCodeRequest true "TOTP or recovery code"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /user/2fa/disable [post]
+func (ah *AuthHandler) DisableTwoFactor(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "User not authenticated",
+		})
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := ah.authService.DisableTwoFactor(userID.(uint), &req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to disable two-factor authentication",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate recovery codes
+// @Description Replace all recovery codes; the previous ones stop working
+// @Tags user
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.TwoFactorCodeRequest true "TOTP or recovery code"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /user/2fa/recovery-codes [post]
+func (ah *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "User not authenticated",
+		})
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request data",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	response, err := ah.authService.RegenerateRecoveryCodes(userID.(uint), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Failed to regenerate recovery codes",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Recovery codes regenerated",
+		Data:    response,
+	})
+}
@@ -6,6 +6,8 @@ import (
 	"go-shop/config"
 	"go-shop/database"
 	"go-shop/routes"
+	"go-shop/services"
+	"go-shop/utils"
 )
 
 func main() {
@@ -13,13 +15,28 @@ func main() {
 	// Load configuration
 	cfg := config.Load()
 
+	// Load JWT signing and verification keys
+	if err := utils.LoadJWTKeys(cfg); err != nil {
+		log.Fatal("Failed to load JWT keys:", err)
+	}
+
 	// Connect to database
 	database.ConnectDB(cfg)
-	database.Migrate()
+	database.Migrate(cfg)
 
 	// Connect to Redis
 	database.ConnectRedis(cfg)
 
+	// Expire unpaid orders and release their stock reservations
+	paymentService := services.NewPaymentService(cfg, services.NewPaymentProvider(cfg))
+	services.NewOrderService(cfg, paymentService, services.NewCarriers(cfg)).StartExpiryWorker()
+
+	// Retry refunds the payment provider has not accepted yet
+	paymentService.StartRefundWorker()
+
+	// Aggregate and prune old search logs
+	services.NewSearchAnalyticsService(cfg).StartRetentionWorker()
+
 	// Setup routes
 	router := routes.SetupRoutes(cfg)
 
@@ -5,7 +5,6 @@ import (
 	"strings"
 
 	"go-shop/config"
-	"go-shop/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -34,7 +33,7 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 
 		// Validate token
-		claims, err := utils.ValidateToken(tokenString, cfg)
+		claims, err := authenticate(tokenString, c.ClientIP(), cfg)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "Invalid token",
@@ -46,6 +45,22 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 		// Set user info in context
 		c.Set("user_id", claims.UserID)
 		c.Set("user_email", claims.Email)
+		c.Set("token_claims", claims)
+		c.Next()
+	}
+}
+
+// OptionalAuthMiddleware sets user info in context when a valid token is sent, but never rejects the request
+func OptionalAuthMiddleware(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := ExtractTokenFromHeader(c.GetHeader("Authorization"))
+		if tokenString != "" {
+			if claims, err := authenticate(tokenString, c.ClientIP(), cfg); err == nil {
+				c.Set("user_id", claims.UserID)
+				c.Set("user_email", claims.Email)
+				c.Set("token_claims", claims)
+			}
+		}
 		c.Next()
 	}
 }
@@ -1,9 +1,12 @@
 package middleware
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 
 	"go-shop/config"
 	"go-shop/database"
@@ -22,60 +25,33 @@ func ExtractTokenFromHeader(authHeader string) string {
 	return ""
 }
 
-// RoleMiddleware проверяет, имеет ли пользователь требуемую роль
-func RoleMiddleware(requiredRole string, cfg *config.Config) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
-				Error: "Authorization header required",
-			})
-			c.Abort()
-			return
-		}
+// RequirePermission lets a request through only when one of the user's roles grants the permission.
+// The request is authenticated unless a middleware before it already did. Permissions are looked up
+// through the Redis cache, so built-in and custom roles are honoured alike and edits apply at once.
+func RequirePermission(permission string, cfg *config.Config) gin.HandlerFunc {
+	cacheTTL := time.Duration(cfg.RBAC.PermissionCacheSeconds) * time.Second
 
-		tokenString := ExtractTokenFromHeader(authHeader)
-		if tokenString == "" {
-			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
-				Error: "Invalid authorization header format",
-			})
-			c.Abort()
+	return func(c *gin.Context) {
+		claims, ok := requestClaims(c, cfg)
+		if !ok {
 			return
 		}
 
-		claims, err := utils.ValidateToken(tokenString, cfg)
+		access, err := database.GetUserAccess(context.Background(), claims.UserID, cacheTTL)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
-				Error: "Invalid token",
-			})
-			c.Abort()
-			return
-		}
-
-		// Получаем пользователя с ролями из базы данных
-		var user models.User
-		if err := database.DB.Preload("Roles").First(&user, claims.UserID).Error; err != nil {
-			log.Printf("RoleMiddleware: Failed to get user %d: %v", claims.UserID, err)
-			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
-				Error: "User not found",
+			log.Printf("RequirePermission: Failed to get permissions of user %d: %v", claims.UserID, err)
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error: "Failed to check permissions",
 			})
 			c.Abort()
 			return
 		}
 
-		// Проверяем, имеет ли пользователь требуемую роль
-		hasRole := false
-		for _, role := range user.Roles {
-			if role.Name == requiredRole {
-				hasRole = true
-				break
-			}
-		}
-
-		if !hasRole {
-			log.Printf("RoleMiddleware: User %d does not have required role %s", claims.UserID, requiredRole)
+		if !access.Has(permission) {
+			log.Printf("RequirePermission: User %d does not have permission %s", claims.UserID, permission)
 			c.JSON(http.StatusForbidden, models.ErrorResponse{
-				Error: "Access denied: Insufficient permissions",
+				Error:   "Access denied: Insufficient permissions",
+				Message: "permission " + permission + " required",
 			})
 			c.Abort()
 			return
@@ -84,81 +60,54 @@ func RoleMiddleware(requiredRole string, cfg *config.Config) gin.HandlerFunc {
 		// Сохраняем информацию о пользователе в контексте
 		c.Set("user_id", claims.UserID)
 		c.Set("user_email", claims.Email)
-		c.Set("user_roles", user.Roles)
+		c.Set("token_claims", claims)
+		c.Set("user_roles", access.Roles)
+		c.Set("user_permissions", access.Permissions)
 		c.Next()
 	}
 }
 
-// SuperAdminMiddleware проверяет, является ли пользователь супер-админом
-func SuperAdminMiddleware(cfg *config.Config) gin.HandlerFunc {
-	return RoleMiddleware(models.ROLE_SUPER_ADMIN, cfg)
+// HasPermission reports whether the permissions loaded by RequirePermission include the permission
+func HasPermission(c *gin.Context, permission string) bool {
+	permissions, _ := c.Get("user_permissions")
+	granted, _ := permissions.([]string)
+	return slices.Contains(granted, permission)
 }
 
-// SellerMiddleware проверяет, является ли пользователь продавцом или супер-админом
-func SellerMiddleware(cfg *config.Config) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
-				Error: "Authorization header required",
-			})
-			c.Abort()
-			return
-		}
-
-		tokenString := ExtractTokenFromHeader(authHeader)
-		if tokenString == "" {
-			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
-				Error: "Invalid authorization header format",
-			})
-			c.Abort()
-			return
-		}
-
-		claims, err := utils.ValidateToken(tokenString, cfg)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
-				Error: "Invalid token",
-			})
-			c.Abort()
-			return
-		}
-
-		// Получаем пользователя с ролями из базы данных
-		var user models.User
-		if err := database.DB.Preload("Roles").First(&user, claims.UserID).Error; err != nil {
-			log.Printf("SellerMiddleware: Failed to get user %d: %v", claims.UserID, err)
-			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
-				Error: "User not found",
-			})
-			c.Abort()
-			return
-		}
+// requestClaims returns the claims of a request authenticated earlier in the chain, or authenticates
+// it with the bearer token. On failure the request is aborted with 401.
+func requestClaims(c *gin.Context, cfg *config.Config) (*utils.Claims, bool) {
+	if claims, exists := c.Get("token_claims"); exists {
+		return claims.(*utils.Claims), true
+	}
 
-		// Проверяем, имеет ли пользователь роль продавца или супер-админа
-		hasPermission := false
-		for _, role := range user.Roles {
-			if role.Name == models.ROLE_SELLER || role.Name == models.ROLE_SUPER_ADMIN {
-				hasPermission = true
-				break
-			}
-		}
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "Authorization header required",
+		})
+		c.Abort()
+		return nil, false
+	}
 
-		if !hasPermission {
-			log.Printf("SellerMiddleware: User %d does not have seller or super_admin role", claims.UserID)
-			c.JSON(http.StatusForbidden, models.ErrorResponse{
-				Error: "Access denied: Seller or Super Admin role required",
-			})
-			c.Abort()
-			return
-		}
+	tokenString := ExtractTokenFromHeader(authHeader)
+	if tokenString == "" {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "Invalid authorization header format",
+		})
+		c.Abort()
+		return nil, false
+	}
 
-		// Сохраняем информацию о пользователе в контексте
-		c.Set("user_id", claims.UserID)
-		c.Set("user_email", claims.Email)
-		c.Set("user_roles", user.Roles)
-		c.Next()
+	claims, err := authenticate(tokenString, c.ClientIP(), cfg)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "Invalid token",
+		})
+		c.Abort()
+		return nil, false
 	}
+	return claims, true
 }
 
 // LogSensitiveOperation логирует чувствительные операции
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go-shop/config"
+	"go-shop/database"
+	"go-shop/utils"
+)
+
+// authenticate validates an access token and rejects tokens revoked by logout, by revoking their
+// session or all sessions of the user (password reset, role change). Redis errors reject the token.
+// The session's last activity is updated with the client IP.
+func authenticate(tokenString, clientIP string, cfg *config.Config) (*utils.Claims, error) {
+	claims, err := utils.ValidateToken(tokenString, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	denied, err := database.IsTokenDenied(ctx, claims.ID)
+	if err != nil {
+		log.Printf("Failed to check token denylist: %v", err)
+		return nil, errors.New("failed to check token")
+	}
+	if denied {
+		return nil, errors.New("token revoked")
+	}
+
+	revokedAt, err := database.GetTokensRevokedAt(ctx, claims.UserID)
+	if err != nil {
+		log.Printf("Failed to check token revocation of user %d: %v", claims.UserID, err)
+		return nil, errors.New("failed to check token")
+	}
+	// Tokens issued in the same millisecond as the revocation, such as the one returned by the
+	// request that revoked, stay valid
+	if !revokedAt.IsZero() && time.UnixMilli(claims.IssuedAtMilli).Before(revokedAt) {
+		return nil, errors.New("token revoked")
+	}
+
+	active, err := database.TouchRefreshSession(ctx, claims.SessionID, clientIP)
+	if err != nil {
+		log.Printf("Failed to check session %s: %v", claims.SessionID, err)
+		return nil, errors.New("failed to check token")
+	}
+	if !active {
+		return nil, errors.New("session revoked")
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PostalAddress is where a parcel goes; it is shared by the address book and the address copied onto orders
+type PostalAddress struct {
+	FullName   string `json:"full_name" gorm:"not null;default:''"`
+	Phone      string `json:"phone,omitempty" gorm:"size:30;not null;default:''"`
+	Line1      string `json:"line1" gorm:"not null;default:''"`
+	Line2      string `json:"line2,omitempty" gorm:"not null;default:''"`
+	City       string `json:"city" gorm:"not null;default:''"`
+	Region     string `json:"region,omitempty" gorm:"size:100;not null;default:''"` // State or province
+	PostalCode string `json:"postal_code,omitempty" gorm:"size:20;not null;default:''"`
+	Country    string `json:"country" gorm:"size:2;not null;default:''"` // ISO 3166-1 alpha-2 code
+}
+
+// Address is an entry of a user's address book; the default address is used for orders that name none
+type Address struct {
+	ID     uint   `json:"id" gorm:"primaryKey"`
+	UserID uint   `json:"user_id" gorm:"not null;index"`
+	Label  string `json:"label" gorm:"size:50"` // e.g. Home, Work
+	PostalAddress
+	IsDefault bool           `json:"is_default" gorm:"not null;default:false"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+type AddressRequest struct {
+	Label      string `json:"label" binding:"max=50"`
+	FullName   string `json:"full_name" binding:"required,max=200"`
+	Phone      string `json:"phone" binding:"max=30"`
+	Line1      string `json:"line1" binding:"required,max=200"`
+	Line2      string `json:"line2" binding:"max=200"`
+	City       string `json:"city" binding:"required,max=100"`
+	Region     string `json:"region" binding:"max=100"`
+	PostalCode string `json:"postal_code" binding:"max=20"`
+	Country    string `json:"country" binding:"required,len=2"`
+	IsDefault  bool   `json:"is_default"` // Makes this the default address; the first address always is
+}
+
+type AddressResponse struct {
+	ID    uint   `json:"id"`
+	Label string `json:"label,omitempty"`
+	PostalAddress
+	IsDefault bool      `json:"is_default"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
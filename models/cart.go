@@ -0,0 +1,36 @@
+package models
+
+type CartItemRequest struct {
+	ProductID uint  `json:"product_id" binding:"required"`
+	VariantID *uint `json:"variant_id"` // Required for products with variants
+	Quantity  int   `json:"quantity" binding:"required,min=1"`
+}
+
+type CartItemUpdateRequest struct {
+	Quantity int `json:"quantity" binding:"min=0"` // 0 removes the line
+}
+
+type CartMergeRequest struct {
+	CartToken string `json:"cart_token" binding:"required"`
+}
+
+type CartItemResponse struct {
+	ProductID uint                    `json:"product_id"`
+	VariantID *uint                   `json:"variant_id,omitempty"`
+	Quantity  int                     `json:"quantity"`
+	Price     Money                   `json:"price"`
+	Subtotal  Money                   `json:"subtotal"`
+	Stock     int                     `json:"stock"`
+	Available bool                    `json:"available"`
+	Product   *ProductResponse        `json:"product,omitempty"`
+	Variant   *ProductVariantResponse `json:"variant,omitempty"`
+}
+
+type CartResponse struct {
+	CartToken     string             `json:"cart_token,omitempty"`
+	Items         []CartItemResponse `json:"items"`
+	TotalQuantity int                `json:"total_quantity"`
+	Currency      string             `json:"currency"`
+	TotalAmount   Money              `json:"total_amount"`
+	Available     bool               `json:"available"`
+}
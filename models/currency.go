@@ -0,0 +1,89 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// zeroDecimalCurrencies have no minor unit in use; converted prices are rounded to whole units
+var zeroDecimalCurrencies = map[string]bool{
+	"BIF": true, "CLP": true, "DJF": true, "GNF": true, "ISK": true, "JPY": true, "KMF": true, "KRW": true,
+	"PYG": true, "RWF": true, "UGX": true, "VND": true, "VUV": true, "XAF": true, "XOF": true, "XPF": true,
+}
+
+// NormalizeCurrency upper-cases an ISO 4217 code; it returns "" for anything that is not three letters
+func NormalizeCurrency(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 {
+		return ""
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return ""
+		}
+	}
+	return code
+}
+
+// RoundTo rounds an amount to the smallest unit in use in the currency
+func (m Money) RoundTo(currency string) Money {
+	if !zeroDecimalCurrencies[currency] {
+		return m
+	}
+	return m.Share(1, MoneyScale) * MoneyScale
+}
+
+// ExchangeRate converts prices from the base currency: one unit of the base currency is worth Rate units of Currency.
+// Shoppers can only use the base currency and currencies with a rate.
+type ExchangeRate struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Currency  string    `json:"currency" gorm:"size:3;uniqueIndex;not null"`
+	Rate      float64   `json:"rate" gorm:"type:numeric(18,8);not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ProductPrice is a list price of a product, or of one of its variants, in a currency other than the
+// base currency. It is used instead of converting the base price.
+type ProductPrice struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ProductID uint      `json:"product_id" gorm:"not null;uniqueIndex:idx_product_prices_product_variant_currency"`
+	VariantID uint      `json:"variant_id" gorm:"not null;default:0;uniqueIndex:idx_product_prices_product_variant_currency"` // 0 for the product itself
+	Currency  string    `json:"currency" gorm:"size:3;not null;uniqueIndex:idx_product_prices_product_variant_currency"`
+	Price     Money     `json:"price" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ExchangeRateRequest struct {
+	Rate float64 `json:"rate" binding:"required,gt=0"` // Units of the currency per unit of the base currency
+}
+
+type ExchangeRateResponse struct {
+	Currency  string    `json:"currency"`
+	Rate      float64   `json:"rate"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CurrenciesResponse lists the currencies shoppers can request prices and pay in
+type CurrenciesResponse struct {
+	Base       string   `json:"base"`
+	Currencies []string `json:"currencies"`
+}
+
+// ProductPricesRequest replaces the price list of a product; an empty list removes every list price
+type ProductPricesRequest struct {
+	Prices []ProductPriceRequest `json:"prices" binding:"dive"`
+}
+
+type ProductPriceRequest struct {
+	VariantID *uint  `json:"variant_id"` // Price of a variant; the product price applies to variants without an own base price
+	Currency  string `json:"currency" binding:"required,len=3"`
+	Price     Money  `json:"price" binding:"min=0"`
+}
+
+type ProductPriceResponse struct {
+	VariantID *uint  `json:"variant_id,omitempty"`
+	Currency  string `json:"currency"`
+	Price     Money  `json:"price"`
+}
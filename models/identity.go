@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+// UserIdentity links a user to their account at an OpenID Connect provider
+type UserIdentity struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	Provider  string    `json:"provider" gorm:"not null;uniqueIndex:idx_user_identities_provider_subject"`
+	Subject   string    `json:"subject" gorm:"not null;uniqueIndex:idx_user_identities_provider_subject"` // "sub" claim, stable per provider
+	Email     string    `json:"email"`                                                                    // Email at the time of linking
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+type OIDCLoginRequest struct {
+	CartToken string `form:"cart_token"` // Guest cart to merge into the user's cart
+}
+
+// OIDCLoginResponse is where to send the user to sign in at the provider. The client keeps the state
+// and checks it matches the one the provider returns before calling the callback.
+type OIDCLoginResponse struct {
+	AuthorizationURL string    `json:"authorization_url"`
+	State            string    `json:"state"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
+
+// OIDCCallbackRequest holds the parameters the provider redirects back with
+type OIDCCallbackRequest struct {
+	Code             string `form:"code"`
+	State            string `form:"state" binding:"required"`
+	Error            string `form:"error"`
+	ErrorDescription string `form:"error_description"`
+}
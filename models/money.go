@@ -0,0 +1,136 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Money is an exact amount in minor units (cents) of a currency. It is stored as BIGINT and written
+// to JSON as a decimal number with two fractional digits, e.g. 12.50; JSON input may be a number or
+// a string with at most two fractional digits.
+type Money int64
+
+// MoneyScale is the number of minor units in one unit of a currency
+const MoneyScale = 100
+
+var ErrInvalidMoney = errors.New("invalid money amount: use a decimal number with at most two fractional digits")
+
+// ParseMoney parses a decimal amount such as "12", "12.5" or "-0.05" without rounding
+func ParseMoney(text string) (Money, error) {
+	text = strings.TrimSpace(text)
+	sign := ""
+	if strings.HasPrefix(text, "-") {
+		sign, text = "-", text[1:]
+	}
+
+	whole, fraction, hasFraction := strings.Cut(text, ".")
+	if !isDigits(whole) || (hasFraction && !isDigits(fraction)) || len(fraction) > 2 {
+		return 0, ErrInvalidMoney
+	}
+	fraction += strings.Repeat("0", 2-len(fraction))
+
+	minor, err := strconv.ParseInt(sign+whole+fraction, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidMoney
+	}
+	return Money(minor), nil
+}
+
+// MoneyFromFloat converts an amount in currency units, rounded to cents
+func MoneyFromFloat(amount float64) Money {
+	return Money(math.Round(amount * MoneyScale))
+}
+
+func isDigits(text string) bool {
+	if text == "" {
+		return false
+	}
+	for _, r := range text {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats the amount as a decimal with two fractional digits
+func (m Money) String() string {
+	sign := ""
+	minor := int64(m)
+	if minor < 0 {
+		sign, minor = "-", -minor
+	}
+	cents := strconv.FormatInt(minor%MoneyScale, 10)
+	if len(cents) < 2 {
+		cents = "0" + cents
+	}
+	return sign + strconv.FormatInt(minor/MoneyScale, 10) + "." + cents
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+
+	amount, err := ParseMoney(text)
+	if err != nil {
+		return err
+	}
+	*m = amount
+	return nil
+}
+
+// Mul returns the amount times a quantity
+func (m Money) Mul(quantity int) Money {
+	return m * Money(quantity)
+}
+
+// MulRat returns the amount times r, rounded half away from zero to cents
+func (m Money) MulRat(r *big.Rat) Money {
+	product := new(big.Rat).Mul(new(big.Rat).SetInt64(int64(m)), r)
+	return Money(roundRat(product))
+}
+
+// Percent returns percent percent of the amount, rounded to cents
+func (m Money) Percent(percent float64) Money {
+	return m.MulRat(new(big.Rat).Quo(DecimalRat(percent), big.NewRat(100, 1)))
+}
+
+// Share returns part/whole of the amount, rounded to cents; used to split an amount proportionally
+func (m Money) Share(part, whole int64) Money {
+	if whole == 0 {
+		return 0
+	}
+	return m.MulRat(big.NewRat(part, whole))
+}
+
+// DecimalRat converts a float to the exact value of its shortest decimal representation,
+// so 0.1 becomes 1/10 rather than the nearest binary fraction
+func DecimalRat(value float64) *big.Rat {
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(value, 'f', -1, 64))
+	return r
+}
+
+// roundRat rounds half away from zero to an integer
+func roundRat(r *big.Rat) int64 {
+	num := new(big.Int).Abs(r.Num())
+	quo, rem := new(big.Int).QuoRem(num, r.Denom(), new(big.Int))
+	if rem.Mul(rem, big.NewInt(2)).Cmp(r.Denom()) >= 0 {
+		quo.Add(quo, big.NewInt(1))
+	}
+	if r.Sign() < 0 {
+		quo.Neg(quo)
+	}
+	return quo.Int64()
+}
@@ -15,67 +15,178 @@ const (
 	OrderStatusShipped   OrderStatus = "shipped"   // Отправлен
 	OrderStatusDelivered OrderStatus = "delivered" // Доставлен
 	OrderStatusCancelled OrderStatus = "cancelled" // Отменен
+
+	OrderStatusPartiallyRefunded OrderStatus = "partially_refunded" // Часть товаров возвращена, деньги частично возвращены
+	OrderStatusRefunded          OrderStatus = "refunded"           // Деньги возвращены полностью
 )
 
 type Order struct {
-	ID          uint           `json:"id" gorm:"primaryKey"`
-	UserID      uint           `json:"user_id" gorm:"not null"`
-	OrderNumber string         `json:"order_number" gorm:"uniqueIndex;not null"`
-	Status      OrderStatus    `json:"status" gorm:"default:'pending'"`
-	TotalAmount float64        `json:"total_amount" gorm:"not null"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+	ID               uint           `json:"id" gorm:"primaryKey"`
+	UserID           uint           `json:"user_id" gorm:"not null"`
+	OrderNumber      string         `json:"order_number" gorm:"uniqueIndex;not null"`
+	Status           OrderStatus    `json:"status" gorm:"default:'pending'"`
+	Currency         string         `json:"currency" gorm:"size:3;not null"`    // Of every amount of the order, its items and payments
+	Subtotal         Money          `json:"subtotal" gorm:"not null;default:0"` // Items at their prices
+	Discount         Money          `json:"discount" gorm:"not null;default:0"` // Sum of the promotions applied
+	Tax              Money          `json:"tax" gorm:"not null;default:0"`      // All tax of the order, included in the prices or added to them
+	TotalAmount      Money          `json:"total_amount" gorm:"not null"`       // Amount charged: subtotal - discount + tax not included in the prices + shipping cost
+	ShippingMethodID *uint          `json:"shipping_method_id"`
+	ShippingMethod   string         `json:"shipping_method" gorm:"not null;default:''"`          // Name of the method when ordering
+	ShippingCarrier  string         `json:"shipping_carrier" gorm:"size:50;not null;default:''"` // Carrier of the method when ordering
+	ShippingCost     Money          `json:"shipping_cost" gorm:"not null;default:0"`
+	ShippingAddress  PostalAddress  `json:"shipping_address" gorm:"embedded;embeddedPrefix:shipping_"` // Copied from the address book when ordering; taxes follow its country and region
+	ExpiresAt        *time.Time     `json:"expires_at,omitempty" gorm:"index"`                         // Pending orders not paid by this time are expired
+	DeliveredAt      *time.Time     `json:"delivered_at,omitempty"`
+	Refunded         Money          `json:"refunded" gorm:"not null;default:0"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Relations
-	User       User        `json:"user,omitempty" gorm:"foreignKey:UserID"`
-	OrderItems []OrderItem `json:"order_items,omitempty" gorm:"foreignKey:OrderID"`
+	User         User               `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	OrderItems   []OrderItem        `json:"order_items,omitempty" gorm:"foreignKey:OrderID"`
+	Reservations []StockReservation `json:"reservations,omitempty" gorm:"foreignKey:OrderID"`
+	Shipments    []Shipment         `json:"shipments,omitempty" gorm:"foreignKey:OrderID"`
+	Discounts    []OrderDiscount    `json:"discounts,omitempty" gorm:"foreignKey:OrderID"`
+	Taxes        []OrderTax         `json:"taxes,omitempty" gorm:"foreignKey:OrderID"`
 }
 
 type OrderCreateRequest struct {
-	Items []OrderItemRequest `json:"items" binding:"required,min=1"`
+	Items      []OrderItemRequest `json:"items" binding:"required,min=1"`
+	CouponCode string             `json:"coupon_code" binding:"max=50"`
+	Currency   string             `json:"currency" binding:"omitempty,len=3"` // Defaults to the base currency
+	AddressID  *uint              `json:"address_id"`                         // Shipping address from the address book; defaults to the default address
+
+	ShippingMethodID *uint `json:"shipping_method_id"` // Required once shipping methods are configured
 }
 
 type OrderUpdateRequest struct {
-	Status OrderStatus `json:"status" binding:"required,oneof=pending paid confirmed shipped delivered cancelled"`
+	Status OrderStatus `json:"status" binding:"required,oneof=pending paid confirmed shipped delivered cancelled partially_refunded refunded"`
+	Reason string      `json:"reason" binding:"max=500"`
 }
 
 type OrderResponse struct {
-	ID          uint                `json:"id"`
-	UserID      uint                `json:"user_id"`
-	OrderNumber string              `json:"order_number"`
-	Status      OrderStatus         `json:"status"`
-	TotalAmount float64             `json:"total_amount"`
-	CreatedAt   time.Time           `json:"created_at"`
-	UpdatedAt   time.Time           `json:"updated_at"`
-	OrderItems  []OrderItemResponse `json:"order_items,omitempty"`
+	ID              uint                    `json:"id"`
+	UserID          uint                    `json:"user_id"`
+	OrderNumber     string                  `json:"order_number"`
+	Status          OrderStatus             `json:"status"`
+	Currency        string                  `json:"currency"`
+	Subtotal        Money                   `json:"subtotal"`
+	Discount        Money                   `json:"discount"`
+	Discounts       []OrderDiscountResponse `json:"discounts,omitempty"`
+	Tax             Money                   `json:"tax"`
+	Taxes           []OrderTaxResponse      `json:"taxes,omitempty"`
+	ShippingMethod  string                  `json:"shipping_method,omitempty"`
+	ShippingCost    Money                   `json:"shipping_cost"`
+	TotalAmount     Money                   `json:"total_amount"`
+	ShippingAddress *PostalAddress          `json:"shipping_address,omitempty"`
+	ExpiresAt       *time.Time              `json:"expires_at,omitempty"`
+	DeliveredAt     *time.Time              `json:"delivered_at,omitempty"`
+	Refunded        Money                   `json:"refunded"`
+	CreatedAt       time.Time               `json:"created_at"`
+	UpdatedAt       time.Time               `json:"updated_at"`
+	OrderItems      []OrderItemResponse     `json:"order_items,omitempty"`
+	Shipments       []ShipmentResponse      `json:"shipments,omitempty"`
 }
 
 type OrderItem struct {
 	ID            uint           `json:"id" gorm:"primaryKey"`
 	OrderID       uint           `json:"order_id" gorm:"not null"`
+	ShipmentID    *uint          `json:"shipment_id" gorm:"index"`
 	ProductID     uint           `json:"product_id" gorm:"not null"`
+	VariantID     *uint          `json:"variant_id" gorm:"index"`
 	Quantity      int            `json:"quantity" gorm:"not null"`
-	PriceAtMoment float64        `json:"price_at_moment" gorm:"not null"`
+	PriceAtMoment Money          `json:"price_at_moment" gorm:"not null"`                      // In the order currency
+	Discount      Money          `json:"discount" gorm:"not null;default:0"`                   // Share of the order's discounts on this line, refunded less on return
+	TaxRate       float64        `json:"tax_rate" gorm:"type:numeric(7,4);not null;default:0"` // Percent
+	TaxInclusive  bool           `json:"tax_inclusive" gorm:"not null;default:false"`
+	Tax           Money          `json:"tax" gorm:"not null;default:0"` // Tax on the line after its discount
 	CreatedAt     time.Time      `json:"created_at"`
 	UpdatedAt     time.Time      `json:"updated_at"`
 	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Relations
-	Order   Order   `json:"order,omitempty" gorm:"foreignKey:OrderID"`
-	Product Product `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	Order   Order           `json:"order,omitempty" gorm:"foreignKey:OrderID"`
+	Product Product         `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	Variant *ProductVariant `json:"variant,omitempty" gorm:"foreignKey:VariantID"`
+}
+
+// Total is the amount charged for the line: after its discount, with the tax added unless the price includes it
+func (item *OrderItem) Total() Money {
+	total := item.PriceAtMoment.Mul(item.Quantity) - item.Discount
+	if !item.TaxInclusive {
+		total += item.Tax
+	}
+	return total
 }
 
 type OrderItemRequest struct {
-	ProductID uint `json:"product_id" binding:"required"`
-	Quantity  int  `json:"quantity" binding:"required,min=1"`
+	ProductID uint  `json:"product_id" binding:"required"`
+	VariantID *uint `json:"variant_id"` // Required for products with variants
+	Quantity  int   `json:"quantity" binding:"required,min=1"`
 }
 
 type OrderItemResponse struct {
 	ID            uint             `json:"id"`
 	OrderID       uint             `json:"order_id"`
+	ShipmentID    *uint            `json:"shipment_id,omitempty"`
 	ProductID     uint             `json:"product_id"`
+	VariantID     *uint            `json:"variant_id,omitempty"`
 	Quantity      int              `json:"quantity"`
-	PriceAtMoment float64          `json:"price_at_moment"`
+	PriceAtMoment Money            `json:"price_at_moment"`
+	Discount      Money            `json:"discount"`
+	TaxRate       float64          `json:"tax_rate"`
+	TaxInclusive  bool             `json:"tax_inclusive"`
+	Tax           Money            `json:"tax"`
 	Product       *ProductResponse `json:"product,omitempty"`
 }
+
+type ReservationStatus string
+
+const (
+	ReservationStatusActive   ReservationStatus = "active"   // Товар зарезервирован под заказ
+	ReservationStatusConsumed ReservationStatus = "consumed" // Списан со склада при подтверждении
+	ReservationStatusReleased ReservationStatus = "released" // Возвращен на склад
+)
+
+// StockReservation holds product quantity for an order until it is confirmed or released
+type StockReservation struct {
+	ID        uint              `json:"id" gorm:"primaryKey"`
+	OrderID   uint              `json:"order_id" gorm:"not null;index"`
+	ProductID uint              `json:"product_id" gorm:"not null;index"`
+	VariantID *uint             `json:"variant_id" gorm:"index"` // Stock is held on the variant when set
+	Quantity  int               `json:"quantity" gorm:"not null"`
+	Status    ReservationStatus `json:"status" gorm:"not null;default:'active';index"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+
+	// Relations
+	Product Product `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+}
+
+// OrderStatusHistory records every status transition of an order
+type OrderStatusHistory struct {
+	ID         uint        `json:"id" gorm:"primaryKey"`
+	OrderID    uint        `json:"order_id" gorm:"not null;index"`
+	FromStatus OrderStatus `json:"from_status"`
+	ToStatus   OrderStatus `json:"to_status" gorm:"not null"`
+	ActorID    *uint       `json:"actor_id" gorm:"index"` // nil for system transitions
+	ActorRole  string      `json:"actor_role" gorm:"not null"`
+	Reason     string      `json:"reason"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+func (OrderStatusHistory) TableName() string {
+	return "order_status_history"
+}
+
+type OrderStatusHistoryResponse struct {
+	ID         uint        `json:"id"`
+	OrderID    uint        `json:"order_id"`
+	FromStatus OrderStatus `json:"from_status,omitempty"`
+	ToStatus   OrderStatus `json:"to_status"`
+	ActorID    *uint       `json:"actor_id,omitempty"`
+	ActorRole  string      `json:"actor_role"`
+	Reason     string      `json:"reason,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
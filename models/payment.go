@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+)
+
+type PaymentStatus string
+
+const (
+	PaymentStatusPending    PaymentStatus = "pending"    // Платеж создан у провайдера, ожидает оплаты
+	PaymentStatusAuthorized PaymentStatus = "authorized" // Средства заблокированы, ожидают списания
+	PaymentStatusCaptured   PaymentStatus = "captured"   // Средства списаны
+	PaymentStatusFailed     PaymentStatus = "failed"     // Оплата не прошла
+	PaymentStatusRefunded   PaymentStatus = "refunded"   // Средства возвращены
+)
+
+type Payment struct {
+	ID             uint          `json:"id" gorm:"primaryKey"`
+	OrderID        uint          `json:"order_id" gorm:"not null;index"`
+	UserID         uint          `json:"user_id" gorm:"not null;index"`
+	Provider       string        `json:"provider" gorm:"not null"`
+	ProviderRef    string        `json:"provider_ref" gorm:"uniqueIndex;not null"`
+	ClientSecret   string        `json:"-"`
+	Amount         Money         `json:"amount" gorm:"not null"`
+	RefundedAmount Money         `json:"refunded_amount" gorm:"not null;default:0"`
+	Currency       string        `json:"currency" gorm:"not null"`
+	Status         PaymentStatus `json:"status" gorm:"not null;default:'pending'"`
+	FailureReason  string        `json:"failure_reason"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+
+	// Relations
+	Order Order `json:"order,omitempty" gorm:"foreignKey:OrderID"`
+}
+
+type PaymentResponse struct {
+	ID             uint          `json:"id"`
+	OrderID        uint          `json:"order_id"`
+	Provider       string        `json:"provider"`
+	ProviderRef    string        `json:"provider_ref"`
+	ClientSecret   string        `json:"client_secret,omitempty"`
+	Amount         Money         `json:"amount"`
+	RefundedAmount Money         `json:"refunded_amount"`
+	Currency       string        `json:"currency"`
+	Status         PaymentStatus `json:"status"`
+	FailureReason  string        `json:"failure_reason,omitempty"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+type SandboxPaymentRequest struct {
+	Outcome string `json:"outcome" binding:"required,oneof=succeeded authorized failed"`
+}
+
+type RefundStatus string
+
+const (
+	RefundStatusPending   RefundStatus = "pending"   // Возврат записан, ожидает отправки провайдеру
+	RefundStatusSucceeded RefundStatus = "succeeded" // Возврат проведен у провайдера
+	RefundStatusManual    RefundStatus = "manual"    // Нет онлайн-платежа, возврат проводится вручную
+)
+
+// Refund records money returned to the buyer for an order
+type Refund struct {
+	ID          uint         `json:"id" gorm:"primaryKey"`
+	OrderID     uint         `json:"order_id" gorm:"not null;index"`
+	PaymentID   *uint        `json:"payment_id" gorm:"index"`
+	ReturnID    *uint        `json:"return_id" gorm:"index"`
+	Amount      Money        `json:"amount" gorm:"not null"`
+	Status      RefundStatus `json:"status" gorm:"not null"`
+	Reason      string       `json:"reason"`
+	ProviderRef string       `json:"provider_ref"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
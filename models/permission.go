@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+)
+
+// Permissions checked by the routes; roles are granted them through role_permissions
+const (
+	PermissionRolesRead        = "roles.read"        // List roles and the users holding them
+	PermissionRolesAssign      = "roles.assign"      // Assign and remove roles of users
+	PermissionRolesManage      = "roles.manage"      // Create roles and edit their permissions
+	PermissionCategoriesWrite  = "categories.write"  // Create, update and delete categories
+	PermissionProductsWrite    = "products.write"    // Create and update own products
+	PermissionProductsManage   = "products.manage"   // Manage and delete the products of every seller
+	PermissionOrdersShip       = "orders.ship"       // See and ship orders containing own products
+	PermissionOrdersManage     = "orders.manage"     // Confirm, ship, deliver and cancel every order, handle returns
+	PermissionAnalyticsRead    = "analytics.read"    // Search analytics reports
+	PermissionPromotionsManage = "promotions.manage" // Create, update and delete coupons and promotions
+	PermissionCurrenciesManage = "currencies.manage" // Set exchange rates
+	PermissionTaxesManage      = "taxes.manage"      // Create, update and delete tax rates
+	PermissionShippingManage   = "shipping.manage"   // Create, update and delete shipping methods
+)
+
+// Permission is an operation roles can be allowed to perform
+type Permission struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Name        string    `json:"name" gorm:"uniqueIndex;not null"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// RolePermissionsRequest replaces the permissions of a role; an empty list removes all of them
+type RolePermissionsRequest struct {
+	Permissions []string `json:"permissions" binding:"required"`
+}
+
+type PermissionResponse struct {
+	ID          uint   `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
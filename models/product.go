@@ -61,29 +61,39 @@ func (s *StringArray) Scan(value interface{}) error {
 type Product struct {
 	ID          uint           `json:"id" gorm:"primaryKey"`
 	CategoryID  *uint          `json:"category_id" gorm:"index"`
+	SellerID    *uint          `json:"seller_id" gorm:"index"` // nil for products sold by the shop itself
 	Title       string         `json:"title" gorm:"not null"`
 	Description string         `json:"description"`
 	Images      StringArray    `json:"images" gorm:"type:jsonb"`
-	Price       float64        `json:"price" gorm:"not null"`
+	Price       Money          `json:"price" gorm:"not null"` // In the base currency
 	Model       string         `json:"model"`
 	ExtraInfo   JSONB          `json:"extra_info" gorm:"type:jsonb"`
 	Stock       int            `json:"stock" gorm:"not null;default:0"`
+	Reserved    int            `json:"reserved" gorm:"not null;default:0"` // Quantity held by unconfirmed orders
 	OrderCount  int            `json:"order_count" gorm:"not null;default:0"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Relations
-	Category   *Category   `json:"category,omitempty" gorm:"foreignKey:CategoryID"`
-	OrderItems []OrderItem `json:"order_items,omitempty" gorm:"foreignKey:ProductID"`
+	Category   *Category        `json:"category,omitempty" gorm:"foreignKey:CategoryID"`
+	Seller     *User            `json:"seller,omitempty" gorm:"foreignKey:SellerID"`
+	OrderItems []OrderItem      `json:"order_items,omitempty" gorm:"foreignKey:ProductID"`
+	Variants   []ProductVariant `json:"variants,omitempty" gorm:"foreignKey:ProductID"`
+}
+
+// AvailableStock returns stock that is not reserved by pending orders
+func (p *Product) AvailableStock() int {
+	return p.Stock - p.Reserved
 }
 
 type ProductCreateRequest struct {
 	CategoryID  uint     `json:"category_id" binding:"required"`
+	SellerID    *uint    `json:"seller_id"` // Super admin only; sellers always own what they create
 	Title       string   `json:"title" binding:"required,min=2,max=200"`
 	Description string   `json:"description" binding:"max=1000"`
 	Images      []string `json:"images"`
-	Price       float64  `json:"price" binding:"required,min=0"`
+	Price       Money    `json:"price" binding:"required,min=0"`
 	Model       string   `json:"model" binding:"max=100"`
 	ExtraInfo   JSONB    `json:"extra_info"`
 	Stock       int      `json:"stock" binding:"min=0"`
@@ -94,7 +104,7 @@ type ProductUpdateRequest struct {
 	Title       string   `json:"title" binding:"omitempty,min=2,max=200"`
 	Description string   `json:"description" binding:"omitempty,max=1000"`
 	Images      []string `json:"images"`
-	Price       *float64 `json:"price" binding:"omitempty,min=0"`
+	Price       *Money   `json:"price" binding:"omitempty,min=0"`
 	Model       string   `json:"model" binding:"omitempty,max=100"`
 	ExtraInfo   JSONB    `json:"extra_info"`
 	Stock       *int     `json:"stock" binding:"omitempty,min=0"`
@@ -103,10 +113,12 @@ type ProductUpdateRequest struct {
 type ProductResponse struct {
 	ID          uint              `json:"id"`
 	CategoryID  *uint             `json:"category_id"`
+	SellerID    *uint             `json:"seller_id,omitempty"`
 	Title       string            `json:"title"`
 	Description string            `json:"description"`
 	Images      []string          `json:"images"`
-	Price       float64           `json:"price"`
+	Price       Money             `json:"price"`
+	Currency    string            `json:"currency"`
 	Model       string            `json:"model"`
 	ExtraInfo   JSONB             `json:"extra_info"`
 	Stock       int               `json:"stock"`
@@ -114,17 +126,63 @@ type ProductResponse struct {
 	CreatedAt   time.Time         `json:"created_at"`
 	UpdatedAt   time.Time         `json:"updated_at"`
 	Category    *CategoryResponse `json:"category,omitempty"`
+
+	// Variant selection: every variant and the values offered for each option
+	Variants       []ProductVariantResponse `json:"variants,omitempty"`
+	VariantOptions map[string][]string      `json:"variant_options,omitempty"`
+
+	// Set on full-text search results
+	Highlight *ProductHighlight `json:"highlight,omitempty"`
+}
+
+// ProductHighlight holds search snippets with matched terms wrapped in <mark></mark>
+type ProductHighlight struct {
+	Title       string  `json:"title"`
+	Description string  `json:"description,omitempty"`
+	Rank        float64 `json:"rank"`
 }
 
 // Search request models
 type ProductSearchRequest struct {
-	Title      string   `form:"title"`
+	Query      string   `form:"q"`     // Full-text query over title, description, model and category name
+	Title      string   `form:"title"` // Deprecated alias of q
 	CategoryID *uint    `form:"category_id"`
 	MinPrice   *float64 `form:"min_price"`
 	MaxPrice   *float64 `form:"max_price"`
-	SortBy     string   `form:"sort_by" binding:"omitempty,oneof=price_asc price_desc popularity_asc popularity_desc created_at_asc created_at_desc"`
+	Currency   string   `form:"currency"` // Currency of the returned prices; filters and facets use the base currency
+	SortBy     string   `form:"sort_by" binding:"omitempty,oneof=relevance price_asc price_desc popularity_asc popularity_desc created_at_asc created_at_desc"`
 	Limit      int      `form:"limit" binding:"omitempty,min=1,max=100"`
 	Offset     int      `form:"offset" binding:"omitempty,min=0"`
+
+	// ExtraInfo filters from attr.<name>=<value> query parameters; values of one attribute are ORed
+	Attributes map[string][]string `form:"-"`
+}
+
+// SearchFacets holds result counts per filter value; each facet ignores its own filter
+// so the storefront can show alternatives to the current selection
+type SearchFacets struct {
+	Categories []CategoryFacet         `json:"categories"`
+	Prices     []PriceFacet            `json:"prices"`
+	Attributes map[string][]FacetValue `json:"attributes"`
+	Currency   string                  `json:"currency"` // Of the price buckets, always the base currency
+}
+
+type FacetValue struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+type CategoryFacet struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// PriceFacet counts products with Min <= price < Max; open ends are omitted
+type PriceFacet struct {
+	Min   *Money `json:"min,omitempty"`
+	Max   *Money `json:"max,omitempty"`
+	Count int64  `json:"count"`
 }
 
 // Search log model
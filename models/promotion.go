@@ -0,0 +1,109 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PromotionType string
+
+const (
+	PromotionTypePercent  PromotionType = "percent"     // Value percent off the eligible items
+	PromotionTypeFixed    PromotionType = "fixed"       // Amount off the eligible items, at most their amount
+	PromotionTypeBuyXGetY PromotionType = "buy_x_get_y" // For every BuyQuantity+GetQuantity eligible units the GetQuantity cheapest are Value percent off
+)
+
+// Promotion is a discount rule: a coupon when it has a code, otherwise applied automatically to every order it matches
+type Promotion struct {
+	ID           uint           `json:"id" gorm:"primaryKey"`
+	Name         string         `json:"name" gorm:"not null"`
+	Code         *string        `json:"code,omitempty" gorm:"uniqueIndex"` // Stored upper case; nil for automatic promotions
+	Type         PromotionType  `json:"type" gorm:"not null"`
+	Value        float64        `json:"value" gorm:"not null"`                  // Percent off, for percent and buy_x_get_y
+	Amount       Money          `json:"amount" gorm:"not null;default:0"`       // Amount off in the base currency, for fixed
+	MinSubtotal  Money          `json:"min_subtotal" gorm:"not null;default:0"` // Minimum amount of the eligible items in the base currency
+	BuyQuantity  int            `json:"buy_quantity" gorm:"not null;default:0"`
+	GetQuantity  int            `json:"get_quantity" gorm:"not null;default:0"`
+	UsageLimit   int            `json:"usage_limit" gorm:"not null;default:0"`    // Orders in total, 0 for unlimited
+	PerUserLimit int            `json:"per_user_limit" gorm:"not null;default:0"` // Orders per buyer, 0 for unlimited
+	StartsAt     *time.Time     `json:"starts_at,omitempty"`
+	EndsAt       *time.Time     `json:"ends_at,omitempty"`
+	IsActive     bool           `json:"is_active" gorm:"not null;default:true"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Scope: the promotion applies to these products and the products of these categories; to everything when both are empty
+	Products   []Product  `json:"products,omitempty" gorm:"many2many:promotion_products;"`
+	Categories []Category `json:"categories,omitempty" gorm:"many2many:promotion_categories;"`
+}
+
+// OrderDiscount is a promotion applied to an order, with the amount it took off
+type OrderDiscount struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	OrderID     uint      `json:"order_id" gorm:"not null;index"`
+	PromotionID uint      `json:"promotion_id" gorm:"not null;index"`
+	UserID      uint      `json:"user_id" gorm:"not null;index"` // Buyer, for per-user usage limits
+	Code        string    `json:"code,omitempty"`
+	Name        string    `json:"name" gorm:"not null"`
+	Amount      Money     `json:"amount" gorm:"not null"` // In the order currency
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// PromotionRequest creates a promotion or replaces all its settings
+type PromotionRequest struct {
+	Name         string        `json:"name" binding:"required,min=2,max=200"`
+	Code         string        `json:"code" binding:"omitempty,min=3,max=50,alphanum"` // Empty for an automatic promotion
+	Type         PromotionType `json:"type" binding:"required,oneof=percent fixed buy_x_get_y"`
+	Value        float64       `json:"value" binding:"min=0"`  // Percent off, or percent off the free units of buy_x_get_y (100 when 0)
+	Amount       Money         `json:"amount" binding:"min=0"` // Amount off of a fixed promotion
+	MinSubtotal  Money         `json:"min_subtotal" binding:"min=0"`
+	BuyQuantity  int           `json:"buy_quantity" binding:"min=0"`
+	GetQuantity  int           `json:"get_quantity" binding:"min=0"`
+	ProductIDs   []uint        `json:"product_ids"`
+	CategoryIDs  []uint        `json:"category_ids"`
+	UsageLimit   int           `json:"usage_limit" binding:"min=0"`
+	PerUserLimit int           `json:"per_user_limit" binding:"min=0"`
+	StartsAt     *time.Time    `json:"starts_at"`
+	EndsAt       *time.Time    `json:"ends_at"`
+	IsActive     *bool         `json:"is_active"` // Defaults to true
+}
+
+type PromotionResponse struct {
+	ID           uint          `json:"id"`
+	Name         string        `json:"name"`
+	Code         string        `json:"code,omitempty"`
+	Automatic    bool          `json:"automatic"`
+	Type         PromotionType `json:"type"`
+	Value        float64       `json:"value,omitempty"`
+	Amount       Money         `json:"amount,omitempty"`
+	MinSubtotal  Money         `json:"min_subtotal"`
+	BuyQuantity  int           `json:"buy_quantity,omitempty"`
+	GetQuantity  int           `json:"get_quantity,omitempty"`
+	ProductIDs   []uint        `json:"product_ids"`
+	CategoryIDs  []uint        `json:"category_ids"`
+	UsageLimit   int           `json:"usage_limit"`
+	PerUserLimit int           `json:"per_user_limit"`
+	UsedCount    int64         `json:"used_count"` // Orders using the promotion, cancelled ones excluded
+	StartsAt     *time.Time    `json:"starts_at,omitempty"`
+	EndsAt       *time.Time    `json:"ends_at,omitempty"`
+	IsActive     bool          `json:"is_active"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+}
+
+type OrderDiscountResponse struct {
+	PromotionID uint   `json:"promotion_id"`
+	Code        string `json:"code,omitempty"`
+	Name        string `json:"name"`
+	Amount      Money  `json:"amount"`
+}
+
+type CheckoutRequest struct {
+	CouponCode string `json:"coupon_code" binding:"max=50"`
+	Currency   string `json:"currency" binding:"omitempty,len=3"` // Defaults to the base currency
+	AddressID  *uint  `json:"address_id"`                         // Defaults to the default address
+
+	ShippingMethodID *uint `json:"shipping_method_id"`
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"time"
+)
+
+type ReturnStatus string
+
+const (
+	ReturnStatusRequested ReturnStatus = "requested" // Покупатель запросил возврат
+	ReturnStatusApproved  ReturnStatus = "approved"  // Админ одобрил, товар возвращен на склад
+	ReturnStatusRejected  ReturnStatus = "rejected"  // Админ отклонил
+)
+
+type OrderReturn struct {
+	ID           uint         `json:"id" gorm:"primaryKey"`
+	OrderID      uint         `json:"order_id" gorm:"not null;index"`
+	UserID       uint         `json:"user_id" gorm:"not null;index"`
+	Status       ReturnStatus `json:"status" gorm:"not null;default:'requested';index"`
+	Reason       string       `json:"reason"`
+	AdminNote    string       `json:"admin_note"`
+	RefundAmount Money        `json:"refund_amount" gorm:"not null;default:0"`
+	ReviewedBy   *uint        `json:"reviewed_by"`
+	ReviewedAt   *time.Time   `json:"reviewed_at"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+
+	// Relations
+	Order Order        `json:"order,omitempty" gorm:"foreignKey:OrderID"`
+	Items []ReturnItem `json:"items,omitempty" gorm:"foreignKey:ReturnID"`
+}
+
+type ReturnItem struct {
+	ID          uint  `json:"id" gorm:"primaryKey"`
+	ReturnID    uint  `json:"return_id" gorm:"not null;index"`
+	OrderItemID uint  `json:"order_item_id" gorm:"not null;index"`
+	ProductID   uint  `json:"product_id" gorm:"not null"`
+	VariantID   *uint `json:"variant_id"`
+	Quantity    int   `json:"quantity" gorm:"not null"`
+	Amount      Money `json:"amount" gorm:"not null"`
+}
+
+type ReturnCreateRequest struct {
+	Items  []ReturnItemRequest `json:"items" binding:"required,min=1,dive"`
+	Reason string              `json:"reason" binding:"required,max=1000"`
+}
+
+type ReturnItemRequest struct {
+	OrderItemID uint `json:"order_item_id" binding:"required"`
+	Quantity    int  `json:"quantity" binding:"required,min=1"`
+}
+
+type ReturnApproveRequest struct {
+	RefundAmount *Money `json:"refund_amount" binding:"omitempty,min=0"` // Defaults to the full price of returned items
+	Note         string `json:"note" binding:"max=1000"`
+}
+
+type ReturnRejectRequest struct {
+	Note string `json:"note" binding:"required,max=1000"`
+}
+
+type ReturnItemResponse struct {
+	ID          uint  `json:"id"`
+	OrderItemID uint  `json:"order_item_id"`
+	ProductID   uint  `json:"product_id"`
+	VariantID   *uint `json:"variant_id,omitempty"`
+	Quantity    int   `json:"quantity"`
+	Amount      Money `json:"amount"`
+}
+
+type ReturnResponse struct {
+	ID           uint                 `json:"id"`
+	OrderID      uint                 `json:"order_id"`
+	UserID       uint                 `json:"user_id"`
+	Status       ReturnStatus         `json:"status"`
+	Reason       string               `json:"reason"`
+	AdminNote    string               `json:"admin_note,omitempty"`
+	RefundAmount Money                `json:"refund_amount"`
+	ReviewedBy   *uint                `json:"reviewed_by,omitempty"`
+	ReviewedAt   *time.Time           `json:"reviewed_at,omitempty"`
+	CreatedAt    time.Time            `json:"created_at"`
+	UpdatedAt    time.Time            `json:"updated_at"`
+	Items        []ReturnItemResponse `json:"items"`
+}
@@ -22,7 +22,8 @@ type Role struct {
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Relations
-	Users []User `json:"users,omitempty" gorm:"many2many:user_roles;"`
+	Users       []User       `json:"users,omitempty" gorm:"many2many:user_roles;"`
+	Permissions []Permission `json:"permissions,omitempty" gorm:"many2many:role_permissions;"`
 }
 
 type UserRole struct {
@@ -39,8 +40,9 @@ type UserRole struct {
 }
 
 type RoleCreateRequest struct {
-	Name        string `json:"name" binding:"required"`
-	Description string `json:"description"`
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
 }
 
 type RoleUpdateRequest struct {
@@ -52,13 +54,14 @@ type RoleResponse struct {
 	ID          uint      `json:"id"`
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
+	Permissions []string  `json:"permissions,omitempty"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
 type AssignRoleRequest struct {
 	UserID uint   `json:"user_id" binding:"required"`
-	Role   string `json:"role" binding:"required,oneof=super_admin seller user"`
+	Role   string `json:"role" binding:"required"` // Any existing role, built-in or custom
 }
 
 type RemoveRoleRequest struct {
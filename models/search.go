@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+)
+
+// SearchQueryStat is a daily aggregate of search_logs per normalized query,
+// kept after the raw rows are pruned by the retention job
+type SearchQueryStat struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	Day          time.Time `json:"day" gorm:"type:date;not null;uniqueIndex:idx_search_query_stats_day_query"`
+	Query        string    `json:"query" gorm:"not null;uniqueIndex:idx_search_query_stats_day_query"` // Lower-cased and trimmed, "" for filter-only searches
+	Searches     int64     `json:"searches" gorm:"not null;default:0"`
+	ZeroResults  int64     `json:"zero_results" gorm:"not null;default:0"`
+	UserSearches int64     `json:"user_searches" gorm:"not null;default:0"` // Searches by signed-in users, the base of the conversion rate
+	Conversions  int64     `json:"conversions" gorm:"not null;default:0"`   // User searches followed by an order within the conversion window
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type SearchAnalyticsRequest struct {
+	From     string `form:"from"` // YYYY-MM-DD, inclusive; defaults to 30 days ago
+	To       string `form:"to"`   // YYYY-MM-DD, inclusive; defaults to today
+	Limit    int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Interval string `form:"interval" binding:"omitempty,oneof=day week"`
+}
+
+type SearchQueryReport struct {
+	Query       string `json:"query"`
+	Searches    int64  `json:"searches"`
+	ZeroResults int64  `json:"zero_results"`
+}
+
+type SearchConversionReport struct {
+	UserSearches   int64                         `json:"user_searches"`
+	Conversions    int64                         `json:"conversions"`
+	ConversionRate float64                       `json:"conversion_rate"`
+	WindowHours    int                           `json:"window_hours"`
+	Queries        []SearchQueryConversionReport `json:"queries"`
+}
+
+type SearchQueryConversionReport struct {
+	Query          string  `json:"query"`
+	UserSearches   int64   `json:"user_searches"`
+	Conversions    int64   `json:"conversions"`
+	ConversionRate float64 `json:"conversion_rate"`
+}
+
+type SearchTrendReport struct {
+	Period      time.Time `json:"period"` // Start of the day or ISO week
+	Searches    int64     `json:"searches"`
+	ZeroResults int64     `json:"zero_results"`
+	Queries     int64     `json:"queries"` // Distinct non-empty queries
+}
+
+type SearchSuggestRequest struct {
+	Query string `form:"q" binding:"required,min=1,max=100"`
+	Limit int    `form:"limit" binding:"omitempty,min=1,max=20"`
+}
+
+// SearchSuggestions are completions of a partially typed query
+type SearchSuggestions struct {
+	Products   []ProductSuggestion  `json:"products"`
+	Categories []CategorySuggestion `json:"categories"`
+}
+
+type ProductSuggestion struct {
+	ID         uint   `json:"id"`
+	Title      string `json:"title"`
+	CategoryID *uint  `json:"category_id,omitempty"`
+	OrderCount int    `json:"order_count"`
+}
+
+type CategorySuggestion struct {
+	ID         uint   `json:"id"`
+	Name       string `json:"name"`
+	OrderCount int64  `json:"order_count"` // Orders of all products in the category
+}
+
+// SpellingSuggestion is a "did you mean" correction of a query that found nothing
+type SpellingSuggestion struct {
+	Text       string  `json:"text"`
+	Source     string  `json:"source"` // "query" (popular past search) or "product" (product title)
+	Similarity float64 `json:"similarity"`
+}
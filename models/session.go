@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// ClientInfo describes the device a login comes from
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+// SessionResponse is an active login session of the user on one device
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`      // Address the session was started from
+	LastIP     string    `json:"last_ip"` // Address of the latest request
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"` // Session of the token used for this request
+}
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+type ShipmentStatus string
+
+const (
+	ShipmentStatusPending   ShipmentStatus = "pending"   // Ждет подтверждения
+	ShipmentStatusConfirmed ShipmentStatus = "confirmed" // Подтверждена, товар списан со склада
+	ShipmentStatusShipped   ShipmentStatus = "shipped"   // Передана в доставку
+	ShipmentStatusDelivered ShipmentStatus = "delivered" // Доставлена
+	ShipmentStatusCancelled ShipmentStatus = "cancelled" // Отменена вместе с заказом
+)
+
+// Shipment is the part of an order fulfilled by one seller
+type Shipment struct {
+	ID             uint           `json:"id" gorm:"primaryKey"`
+	OrderID        uint           `json:"order_id" gorm:"not null;index"`
+	SellerID       *uint          `json:"seller_id" gorm:"index"` // nil for items sold by the shop itself
+	Status         ShipmentStatus `json:"status" gorm:"not null;default:'pending';index"`
+	Carrier        string         `json:"carrier" gorm:"size:50;not null;default:''"`
+	TrackingNumber string         `json:"tracking_number"`
+	TrackingURL    string         `json:"tracking_url" gorm:"not null;default:''"`
+	ShippedAt      *time.Time     `json:"shipped_at,omitempty"`
+	DeliveredAt    *time.Time     `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+
+	// Relations
+	Order *Order      `json:"order,omitempty" gorm:"foreignKey:OrderID"`
+	Items []OrderItem `json:"items,omitempty" gorm:"foreignKey:ShipmentID"`
+}
+
+// ShipmentShipRequest names how shipments were sent. The carrier defaults to the one of the order's
+// shipping method; without a tracking number the carrier issues one.
+type ShipmentShipRequest struct {
+	Carrier        string `json:"carrier" binding:"max=50"`
+	TrackingNumber string `json:"tracking_number" binding:"max=100"`
+}
+
+type ShipmentResponse struct {
+	ID             uint                `json:"id"`
+	OrderID        uint                `json:"order_id"`
+	SellerID       *uint               `json:"seller_id,omitempty"`
+	Status         ShipmentStatus      `json:"status"`
+	Carrier        string              `json:"carrier,omitempty"`
+	TrackingNumber string              `json:"tracking_number,omitempty"`
+	TrackingURL    string              `json:"tracking_url,omitempty"`
+	ShippedAt      *time.Time          `json:"shipped_at,omitempty"`
+	DeliveredAt    *time.Time          `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time           `json:"created_at"`
+	UpdatedAt      time.Time           `json:"updated_at"`
+	Items          []OrderItemResponse `json:"items,omitempty"`
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ShippingRateType string
+
+const (
+	ShippingRateFlat   ShippingRateType = "flat"   // Price per order
+	ShippingRateWeight ShippingRateType = "weight" // Price plus PricePerKg for the weight of the items
+)
+
+// ShippingMethod is a way of delivering orders, priced in the base currency. Products are weighed by
+// the ExtraInfo attribute SHIPPING_WEIGHT_ATTRIBUTE (kg); products without it weigh nothing.
+type ShippingMethod struct {
+	ID          uint             `json:"id" gorm:"primaryKey"`
+	Name        string           `json:"name" gorm:"not null"`
+	Description string           `json:"description"`
+	Carrier     string           `json:"carrier" gorm:"size:50;not null"` // Carrier shipments of the method are sent with
+	Type        ShippingRateType `json:"type" gorm:"not null"`
+	Price       Money            `json:"price" gorm:"not null;default:0"`        // Flat price, or base price of weight rates
+	PricePerKg  Money            `json:"price_per_kg" gorm:"not null;default:0"` // For weight rates
+	FreeOver    Money            `json:"free_over" gorm:"not null;default:0"`    // Free when the items after discounts reach this amount; 0 for never
+	Countries   StringArray      `json:"countries" gorm:"type:jsonb"`            // ISO 3166-1 alpha-2 destinations; every country when empty
+	IsActive    bool             `json:"is_active" gorm:"not null"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt   `json:"-" gorm:"index"`
+}
+
+// Serves reports whether the method delivers to a country
+func (m *ShippingMethod) Serves(country string) bool {
+	if len(m.Countries) == 0 {
+		return true
+	}
+	for _, served := range m.Countries {
+		if served == country {
+			return true
+		}
+	}
+	return false
+}
+
+// ShippingMethodRequest creates a shipping method or replaces all its settings
+type ShippingMethodRequest struct {
+	Name        string           `json:"name" binding:"required,min=2,max=200"`
+	Description string           `json:"description" binding:"max=500"`
+	Carrier     string           `json:"carrier" binding:"required,max=50"`
+	Type        ShippingRateType `json:"type" binding:"required,oneof=flat weight"`
+	Price       Money            `json:"price" binding:"min=0"`
+	PricePerKg  Money            `json:"price_per_kg" binding:"min=0"`
+	FreeOver    Money            `json:"free_over" binding:"min=0"`
+	Countries   []string         `json:"countries"`
+	IsActive    *bool            `json:"is_active"` // Defaults to true
+}
+
+type ShippingMethodResponse struct {
+	ID          uint             `json:"id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Carrier     string           `json:"carrier"`
+	Type        ShippingRateType `json:"type"`
+	Currency    string           `json:"currency"`
+	Price       Money            `json:"price"`
+	PricePerKg  Money            `json:"price_per_kg,omitempty"`
+	FreeOver    Money            `json:"free_over,omitempty"`
+	Countries   []string         `json:"countries"`
+	IsActive    bool             `json:"is_active"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
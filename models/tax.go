@@ -0,0 +1,84 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// TaxRate is a tax charged on orders shipped to a country, or to one of its regions, optionally only
+// on the products of a category. Each order line is taxed at the most specific active rate: a
+// category rate before a general one, then a region rate before a country-wide one.
+type TaxRate struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	Name       string    `json:"name" gorm:"not null"`
+	Country    string    `json:"country" gorm:"size:2;not null;index"`       // ISO 3166-1 alpha-2 code
+	Region     string    `json:"region" gorm:"size:100;not null;default:''"` // State or province; empty for the whole country
+	CategoryID *uint     `json:"category_id" gorm:"index"`                   // nil for every category
+	Rate       float64   `json:"rate" gorm:"type:numeric(7,4);not null"`     // Percent
+	Inclusive  bool      `json:"inclusive" gorm:"not null;default:false"`    // Prices already include the tax; otherwise it is added to them
+	IsActive   bool      `json:"is_active" gorm:"not null"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+
+	// Relations
+	Category *Category `json:"category,omitempty" gorm:"foreignKey:CategoryID"`
+}
+
+// OrderTax is the tax an order was charged at one rate; the rate is copied so later changes do not affect it
+type OrderTax struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	OrderID   uint      `json:"order_id" gorm:"not null;index"`
+	TaxRateID uint      `json:"tax_rate_id" gorm:"not null;index"`
+	Name      string    `json:"name" gorm:"not null"`
+	Rate      float64   `json:"rate" gorm:"type:numeric(7,4);not null"`
+	Inclusive bool      `json:"inclusive" gorm:"not null"`
+	Taxable   Money     `json:"taxable" gorm:"not null"` // Amount of the lines taxed at the rate, after discounts
+	Amount    Money     `json:"amount" gorm:"not null"`  // In the order currency
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NormalizeCountry upper-cases an ISO 3166-1 alpha-2 code; it returns "" for anything that is not two letters
+func NormalizeCountry(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 2 {
+		return ""
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return ""
+		}
+	}
+	return code
+}
+
+type TaxRateRequest struct {
+	Name       string  `json:"name" binding:"required,min=2,max=200"`
+	Country    string  `json:"country" binding:"required,len=2"`
+	Region     string  `json:"region" binding:"max=100"`
+	CategoryID *uint   `json:"category_id"`
+	Rate       float64 `json:"rate" binding:"min=0,max=100"`
+	Inclusive  bool    `json:"inclusive"`
+	IsActive   *bool   `json:"is_active"` // Defaults to true
+}
+
+type TaxRateResponse struct {
+	ID         uint      `json:"id"`
+	Name       string    `json:"name"`
+	Country    string    `json:"country"`
+	Region     string    `json:"region,omitempty"`
+	CategoryID *uint     `json:"category_id,omitempty"`
+	Rate       float64   `json:"rate"`
+	Inclusive  bool      `json:"inclusive"`
+	IsActive   bool      `json:"is_active"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type OrderTaxResponse struct {
+	TaxRateID uint    `json:"tax_rate_id"`
+	Name      string  `json:"name"`
+	Rate      float64 `json:"rate"`
+	Inclusive bool    `json:"inclusive"`
+	Taxable   Money   `json:"taxable"`
+	Amount    Money   `json:"amount"`
+}
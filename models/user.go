@@ -17,6 +17,11 @@ type User struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 
+	// TOTP two-factor authentication
+	TOTPSecret   string `json:"-"`
+	TOTPEnabled  bool   `json:"two_factor_enabled" gorm:"not null;default:false"`
+	TOTPLastStep int64  `json:"-" gorm:"not null;default:0"` // Last accepted time step, codes cannot be replayed
+
 	// Relations
 	Orders    []Order    `json:"orders,omitempty" gorm:"foreignKey:UserID"`
 	Favorites []Favorite `json:"favorites,omitempty" gorm:"foreignKey:UserID"`
@@ -31,8 +36,9 @@ type UserCreateRequest struct {
 }
 
 type UserLoginRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required"`
+	Email     string `json:"email" binding:"required,email"`
+	Password  string `json:"password" binding:"required"`
+	CartToken string `json:"cart_token"` // Guest cart to merge into the user's cart
 }
 
 type UserUpdateRequest struct {
@@ -48,6 +54,8 @@ type UserResponse struct {
 	Roles     []RoleResponse `json:"roles"`
 	IsActive  bool           `json:"is_active"`
 	CreatedAt time.Time      `json:"created_at"`
+
+	TwoFactorEnabled bool `json:"two_factor_enabled"`
 }
 
 type PasswordResetRequest struct {
@@ -75,7 +83,27 @@ type ErrorResponse struct {
 	Message string `json:"message,omitempty"`
 }
 
+// LoginResponse carries the tokens of a new session, or a two-factor challenge to answer first
 type LoginResponse struct {
-	User  UserResponse `json:"user"`
-	Token string       `json:"token"`
+	User         *UserResponse `json:"user,omitempty"`
+	Token        string        `json:"token,omitempty"`
+	RefreshToken string        `json:"refresh_token,omitempty"`
+	ExpiresAt    *time.Time    `json:"expires_at,omitempty"` // Expiry of the access token
+
+	TwoFactorRequired  bool       `json:"two_factor_required,omitempty"`
+	EnrollmentRequired bool       `json:"enrollment_required,omitempty"` // The role requires 2FA and the user has not enrolled yet
+	ChallengeToken     string     `json:"challenge_token,omitempty"`
+	ChallengeExpiresAt *time.Time `json:"challenge_expires_at,omitempty"`
+	RecoveryCodes      []string   `json:"recovery_codes,omitempty"` // Shown once, after enrolling during login
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// TokenResponse is a new access/refresh token pair
+type TokenResponse struct {
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
 }
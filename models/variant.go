@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProductVariant is a sellable option of a product (e.g. size M, color red) with its own SKU and stock
+type ProductVariant struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	ProductID uint           `json:"product_id" gorm:"not null;index"`
+	SKU       string         `json:"sku" gorm:"uniqueIndex;not null"`
+	Options   JSONB          `json:"options" gorm:"type:jsonb"`       // Option name -> value, e.g. {"size": "M"}
+	Price     *Money         `json:"price"`                           // Overrides the product price when set
+	Stock     int            `json:"stock" gorm:"not null;default:0"` // Stock of products with variants is kept here
+	Reserved  int            `json:"reserved" gorm:"not null;default:0"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Relations
+	Product *Product `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+}
+
+// AvailableStock returns stock that is not reserved by pending orders
+func (v *ProductVariant) AvailableStock() int {
+	return v.Stock - v.Reserved
+}
+
+// EffectivePrice returns the variant price, falling back to the product price
+func (v *ProductVariant) EffectivePrice(productPrice Money) Money {
+	if v.Price != nil {
+		return *v.Price
+	}
+	return productPrice
+}
+
+type ProductVariantCreateRequest struct {
+	SKU     string            `json:"sku" binding:"required,min=1,max=100"`
+	Options map[string]string `json:"options" binding:"required,min=1"`
+	Price   *Money            `json:"price" binding:"omitempty,min=0"`
+	Stock   int               `json:"stock" binding:"min=0"`
+}
+
+type ProductVariantUpdateRequest struct {
+	SKU        string            `json:"sku" binding:"omitempty,min=1,max=100"`
+	Options    map[string]string `json:"options"`
+	Price      *Money            `json:"price" binding:"omitempty,min=0"`
+	ClearPrice bool              `json:"clear_price"` // Go back to the product price
+	Stock      *int              `json:"stock" binding:"omitempty,min=0"`
+}
+
+type ProductVariantResponse struct {
+	ID        uint      `json:"id"`
+	ProductID uint      `json:"product_id"`
+	SKU       string    `json:"sku"`
+	Options   JSONB     `json:"options"`
+	Price     Money     `json:"price"`
+	Stock     int       `json:"stock"`
+	Available bool      `json:"available"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
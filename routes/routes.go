@@ -4,6 +4,7 @@ import (
 	"go-shop/config"
 	"go-shop/handlers"
 	"go-shop/middleware"
+	"go-shop/models"
 	"go-shop/services"
 
 	"github.com/gin-gonic/gin"
@@ -15,20 +16,40 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 	authService := services.NewAuthService(cfg, emailService)
 	userService := services.NewUserService()
 	categoryService := services.NewCategoryService()
-	productService := services.NewProductService()
-	orderService := services.NewOrderService()
+	productService := services.NewProductService(cfg)
+	paymentService := services.NewPaymentService(cfg, services.NewPaymentProvider(cfg))
+	carriers := services.NewCarriers(cfg)
+	orderService := services.NewOrderService(cfg, paymentService, carriers)
 	favoriteService := services.NewFavoriteService()
-	roleService := services.NewRoleService()
+	roleService := services.NewRoleService(cfg)
+	cartService := services.NewCartService(cfg, orderService)
+	returnService := services.NewReturnService(cfg, paymentService)
+	searchAnalyticsService := services.NewSearchAnalyticsService(cfg)
+	promotionService := services.NewPromotionService()
+	currencyService := services.NewCurrencyService(cfg)
+	taxService := services.NewTaxService()
+	addressService := services.NewAddressService()
+	shippingService := services.NewShippingService(cfg, carriers)
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(authService)
 	userHandler := handlers.NewUserHandler(userService)
 	categoryHandler := handlers.NewCategoryHandler(categoryService)
 	productHandler := handlers.NewProductHandler(productService)
-	orderHandler := handlers.NewOrderHandler(orderService)
+	orderHandler := handlers.NewOrderHandler(orderService, paymentService)
 	favoriteHandler := handlers.NewFavoriteHandler(favoriteService)
 	adminHandler := handlers.NewAdminHandler(categoryService, productService, orderService)
 	roleHandler := handlers.NewRoleHandler(roleService)
+	cartHandler := handlers.NewCartHandler(cartService)
+	paymentHandler := handlers.NewPaymentHandler(paymentService)
+	returnHandler := handlers.NewReturnHandler(returnService)
+	shipmentHandler := handlers.NewShipmentHandler(orderService)
+	searchAnalyticsHandler := handlers.NewSearchAnalyticsHandler(searchAnalyticsService)
+	promotionHandler := handlers.NewPromotionHandler(promotionService)
+	currencyHandler := handlers.NewCurrencyHandler(currencyService)
+	taxHandler := handlers.NewTaxHandler(taxService)
+	addressHandler := handlers.NewAddressHandler(addressService)
+	shippingHandler := handlers.NewShippingHandler(shippingService)
 
 	// Set Gin mode
 	gin.SetMode(cfg.Server.GinMode)
@@ -41,6 +62,7 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
+		c.Header("Access-Control-Expose-Headers", "RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, RateLimit-Policy, Retry-After")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -58,23 +80,35 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 		})
 	})
 
+	// Public keys for verifying our access tokens
+	router.GET("/.well-known/jwks.json", authHandler.JWKS)
+
 	// API v1 routes
 	v1 := router.Group("/api/v1")
 	{
 		// Auth routes (public)
 		auth := v1.Group("/auth")
+		auth.Use(middleware.RateLimitMiddleware(middleware.RateLimitPublic, cfg))
 		{
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/verify-otp", authHandler.VerifyOTP)
 			auth.POST("/login", authHandler.Login)
 			auth.POST("/request-password-reset", authHandler.RequestPasswordReset)
 			auth.POST("/reset-password", authHandler.ResetPassword)
+			auth.POST("/refresh", authHandler.Refresh)
+			auth.POST("/logout", middleware.AuthMiddleware(cfg), authHandler.Logout)
+			auth.POST("/2fa/enroll", authHandler.EnrollTwoFactorChallenge)
+			auth.POST("/2fa/verify", authHandler.VerifyTwoFactor)
+			auth.GET("/oidc/providers", authHandler.GetOIDCProviders)
+			auth.GET("/oidc/:provider/login", authHandler.StartOIDCLogin)
+			auth.GET("/oidc/:provider/callback", authHandler.OIDCCallback)
 		}
 
 		// Public routes
 		{
 			// Category routes (public)
 			categories := v1.Group("/categories")
+			categories.Use(middleware.RateLimitMiddleware(middleware.RateLimitPublic, cfg))
 			{
 				categories.GET("/", categoryHandler.GetCategories)
 				categories.GET("/:id", categoryHandler.GetCategoryByID)
@@ -82,22 +116,56 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 
 			// Product routes (public)
 			products := v1.Group("/products")
+			products.Use(middleware.RateLimitMiddleware(middleware.RateLimitPublic, cfg))
 			{
 				products.GET("/", productHandler.GetProducts)
-				products.GET("/search", productHandler.SearchProducts)
+				products.GET("/search", middleware.OptionalAuthMiddleware(cfg), productHandler.SearchProducts)
+				products.GET("/suggest", productHandler.SuggestProducts)
 				products.GET("/:id", productHandler.GetProductByID)
 			}
+
+			// Currencies prices can be requested and paid in (public)
+			v1.GET("/currencies", middleware.RateLimitMiddleware(middleware.RateLimitPublic, cfg), currencyHandler.GetCurrencies)
+
+			// Shipping methods (public)
+			v1.GET("/shipping-methods", middleware.RateLimitMiddleware(middleware.RateLimitPublic, cfg), shippingHandler.GetShippingMethods)
+
+			// Guest cart routes (public, identified by cart token)
+			guestCart := v1.Group("/cart/guest")
+			guestCart.Use(middleware.RateLimitMiddleware(middleware.RateLimitPublic, cfg))
+			{
+				guestCart.POST("/", cartHandler.CreateGuestCart)
+				guestCart.GET("/:token", cartHandler.GetCart)
+				guestCart.DELETE("/:token", cartHandler.ClearCart)
+				guestCart.POST("/:token/items", cartHandler.AddItem)
+				guestCart.PUT("/:token/items/:product_id", cartHandler.UpdateItem)
+				guestCart.DELETE("/:token/items/:product_id", cartHandler.RemoveItem)
+			}
+
+			// Payment provider webhook (public, verified by signature; not rate limited so provider retries get through)
+			v1.POST("/payments/webhook", paymentHandler.Webhook)
 		}
 
 		// Protected routes (require authentication)
 		protected := v1.Group("/")
-		protected.Use(middleware.AuthMiddleware(cfg))
+		protected.Use(middleware.AuthMiddleware(cfg), middleware.RateLimitMiddleware(middleware.RateLimitAuthenticated, cfg))
 		{
 			// User routes
 			user := protected.Group("/user")
 			{
 				user.GET("/profile", userHandler.GetProfile)
 				user.PUT("/profile", userHandler.UpdateProfile)
+				user.GET("/sessions", authHandler.GetSessions)
+				user.DELETE("/sessions/:id", authHandler.RevokeSession)
+				user.POST("/2fa/enroll", authHandler.EnrollTwoFactor)
+				user.POST("/2fa/confirm", authHandler.ConfirmTwoFactor)
+				user.POST("/2fa/disable", authHandler.DisableTwoFactor)
+				user.POST("/2fa/recovery-codes", authHandler.RegenerateRecoveryCodes)
+				user.GET("/addresses", addressHandler.GetAddresses)
+				user.POST("/addresses", addressHandler.CreateAddress)
+				user.GET("/addresses/:id", addressHandler.GetAddress)
+				user.PUT("/addresses/:id", addressHandler.UpdateAddress)
+				user.DELETE("/addresses/:id", addressHandler.DeleteAddress)
 				user.GET("/:id", userHandler.GetUserByID)
 			}
 
@@ -109,7 +177,23 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 				orders.GET("/:id", orderHandler.GetOrderByID)
 				orders.PUT("/:id", orderHandler.UpdateOrderStatus)
 				orders.POST("/:id/pay", orderHandler.PayOrder)
+				orders.GET("/:id/payments", orderHandler.GetOrderPayments)
+				orders.POST("/:id/returns", returnHandler.CreateReturn)
+				orders.GET("/:id/returns", returnHandler.GetOrderReturns)
 				orders.POST("/:id/cancel", orderHandler.CancelOrder)
+				orders.GET("/:id/history", orderHandler.GetOrderHistory)
+			}
+
+			// Cart routes
+			cart := protected.Group("/cart")
+			{
+				cart.GET("/", cartHandler.GetCart)
+				cart.DELETE("/", cartHandler.ClearCart)
+				cart.POST("/items", cartHandler.AddItem)
+				cart.PUT("/items/:product_id", cartHandler.UpdateItem)
+				cart.DELETE("/items/:product_id", cartHandler.RemoveItem)
+				cart.POST("/merge", cartHandler.MergeCart)
+				cart.POST("/checkout", cartHandler.Checkout)
 			}
 
 			// Favorite routes
@@ -122,24 +206,28 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 			}
 		}
 
-		// Super Admin routes (require super_admin role)
+		// Super Admin routes (each group requires its permission, granted to super_admin by default)
 		superAdmin := v1.Group("/super-admin")
-		superAdmin.Use(middleware.SuperAdminMiddleware(cfg))
+		superAdmin.Use(middleware.AuthMiddleware(cfg), middleware.RateLimitMiddleware(middleware.RateLimitSuperAdmin, cfg))
 		{
 			// Role management
 			roles := superAdmin.Group("/roles")
+			roles.Use(middleware.RequirePermission(models.PermissionRolesRead, cfg))
 			{
-				roles.POST("/assign", roleHandler.AssignRole)
-				roles.DELETE("/remove", roleHandler.RemoveRole)
+				roles.POST("/assign", middleware.RequirePermission(models.PermissionRolesAssign, cfg), roleHandler.AssignRole)
+				roles.DELETE("/remove", middleware.RequirePermission(models.PermissionRolesAssign, cfg), roleHandler.RemoveRole)
 				roles.GET("/", roleHandler.GetAllRoles)
-				roles.POST("/", roleHandler.CreateRole)
+				roles.POST("/", middleware.RequirePermission(models.PermissionRolesManage, cfg), roleHandler.CreateRole)
+				roles.GET("/permissions", roleHandler.GetAllPermissions)
+				roles.PUT("/:id/permissions", middleware.RequirePermission(models.PermissionRolesManage, cfg), roleHandler.SetRolePermissions)
 				roles.GET("/users/:role", roleHandler.GetUsersByRole)
 				roles.GET("/user/:id", roleHandler.GetUserRole)
 				roles.GET("/all-users", roleHandler.GetAllUsersWithRoles)
 			}
 
-			// Full category management (super admin only)
+			// Full category management
 			superAdminCategories := superAdmin.Group("/categories")
+			superAdminCategories.Use(middleware.RequirePermission(models.PermissionCategoriesWrite, cfg))
 			{
 				superAdminCategories.POST("/", adminHandler.CreateCategory)
 				superAdminCategories.GET("/", adminHandler.GetCategories)
@@ -147,45 +235,141 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 				superAdminCategories.DELETE("/:id", adminHandler.DeleteCategory)
 			}
 
-			// Full product management (super admin only)
+			// Full product management (every seller's products)
 			superAdminProducts := superAdmin.Group("/products")
+			superAdminProducts.Use(middleware.RequirePermission(models.PermissionProductsManage, cfg))
 			{
 				superAdminProducts.POST("/", adminHandler.CreateProduct)
 				superAdminProducts.GET("/", adminHandler.GetProducts)
 				superAdminProducts.PUT("/:id", adminHandler.UpdateProduct)
 				superAdminProducts.DELETE("/:id", adminHandler.DeleteProduct)
+				superAdminProducts.POST("/:id/variants", adminHandler.CreateProductVariant)
+				superAdminProducts.PUT("/:id/variants/:variant_id", adminHandler.UpdateProductVariant)
+				superAdminProducts.DELETE("/:id/variants/:variant_id", adminHandler.DeleteProductVariant)
+				superAdminProducts.GET("/:id/prices", adminHandler.GetProductPrices)
+				superAdminProducts.PUT("/:id/prices", adminHandler.SetProductPrices)
 			}
 
-			// Full order management (super admin only)
+			// Full order management
 			superAdminOrders := superAdmin.Group("/orders")
+			superAdminOrders.Use(middleware.RequirePermission(models.PermissionOrdersManage, cfg))
 			{
 				superAdminOrders.GET("/", adminHandler.GetAllOrders)
 				superAdminOrders.POST("/:id/confirm", adminHandler.ConfirmOrder)
 				superAdminOrders.POST("/:id/ship", adminHandler.ShipOrder)
 				superAdminOrders.POST("/:id/deliver", adminHandler.DeliverOrder)
 				superAdminOrders.POST("/:id/cancel", adminHandler.CancelOrder)
+				superAdminOrders.GET("/:id/history", adminHandler.GetOrderHistory)
+				superAdminOrders.GET("/returns", returnHandler.GetReturns)
+				superAdminOrders.POST("/returns/:return_id/approve", returnHandler.ApproveReturn)
+				superAdminOrders.POST("/returns/:return_id/reject", returnHandler.RejectReturn)
+			}
+
+			// Per-seller shipments of orders
+			superAdminShipments := superAdmin.Group("/shipments")
+			superAdminShipments.Use(middleware.RequirePermission(models.PermissionOrdersManage, cfg))
+			{
+				superAdminShipments.GET("/", shipmentHandler.GetShipments)
+				superAdminShipments.POST("/:id/confirm", shipmentHandler.ConfirmShipment)
+				superAdminShipments.POST("/:id/ship", shipmentHandler.ShipShipment)
+				superAdminShipments.POST("/:id/deliver", shipmentHandler.DeliverShipment)
+			}
+
+			// Search analytics
+			superAdminSearch := superAdmin.Group("/search")
+			superAdminSearch.Use(middleware.RequirePermission(models.PermissionAnalyticsRead, cfg))
+			{
+				superAdminSearch.GET("/top-queries", searchAnalyticsHandler.GetTopQueries)
+				superAdminSearch.GET("/zero-results", searchAnalyticsHandler.GetZeroResultQueries)
+				superAdminSearch.GET("/conversion", searchAnalyticsHandler.GetConversion)
+				superAdminSearch.GET("/trends", searchAnalyticsHandler.GetTrends)
+			}
+
+			// Coupons and automatic promotions
+			superAdminPromotions := superAdmin.Group("/promotions")
+			superAdminPromotions.Use(middleware.RequirePermission(models.PermissionPromotionsManage, cfg))
+			{
+				superAdminPromotions.POST("/", promotionHandler.CreatePromotion)
+				superAdminPromotions.GET("/", promotionHandler.GetPromotions)
+				superAdminPromotions.GET("/:id", promotionHandler.GetPromotionByID)
+				superAdminPromotions.PUT("/:id", promotionHandler.UpdatePromotion)
+				superAdminPromotions.DELETE("/:id", promotionHandler.DeletePromotion)
+			}
+
+			// Exchange rates of the currencies offered besides the base currency
+			superAdminCurrencies := superAdmin.Group("/currencies")
+			superAdminCurrencies.Use(middleware.RequirePermission(models.PermissionCurrenciesManage, cfg))
+			{
+				superAdminCurrencies.GET("/", currencyHandler.GetExchangeRates)
+				superAdminCurrencies.PUT("/:currency", currencyHandler.SetExchangeRate)
+				superAdminCurrencies.DELETE("/:currency", currencyHandler.DeleteExchangeRate)
+			}
+
+			// Shipping methods and their rates
+			superAdminShipping := superAdmin.Group("/shipping-methods")
+			superAdminShipping.Use(middleware.RequirePermission(models.PermissionShippingManage, cfg))
+			{
+				superAdminShipping.POST("/", shippingHandler.CreateShippingMethod)
+				superAdminShipping.GET("/", shippingHandler.GetAllShippingMethods)
+				superAdminShipping.GET("/:id", shippingHandler.GetShippingMethodByID)
+				superAdminShipping.PUT("/:id", shippingHandler.UpdateShippingMethod)
+				superAdminShipping.DELETE("/:id", shippingHandler.DeleteShippingMethod)
+			}
+
+			// Tax rates by country, region and category
+			superAdminTaxes := superAdmin.Group("/taxes")
+			superAdminTaxes.Use(middleware.RequirePermission(models.PermissionTaxesManage, cfg))
+			{
+				superAdminTaxes.POST("/", taxHandler.CreateTaxRate)
+				superAdminTaxes.GET("/", taxHandler.GetTaxRates)
+				superAdminTaxes.GET("/:id", taxHandler.GetTaxRateByID)
+				superAdminTaxes.PUT("/:id", taxHandler.UpdateTaxRate)
+				superAdminTaxes.DELETE("/:id", taxHandler.DeleteTaxRate)
+			}
+
+			// Sandbox payment simulation (local development only, never in release mode)
+			if cfg.Payment.Provider == services.SandboxProviderName && gin.Mode() != gin.ReleaseMode {
+				superAdminPayments := superAdmin.Group("/payments")
+				superAdminPayments.Use(middleware.RequirePermission(models.PermissionOrdersManage, cfg))
+				{
+					superAdminPayments.POST("/sandbox/:reference", paymentHandler.SimulateSandboxPayment)
+				}
 			}
 		}
 
-		// Seller routes (require seller or super_admin role)
+		// Seller routes (products.write and orders.ship, granted to seller and super_admin by default)
 		seller := v1.Group("/seller")
-		seller.Use(middleware.SellerMiddleware(cfg))
+		seller.Use(middleware.AuthMiddleware(cfg), middleware.RateLimitMiddleware(middleware.RateLimitSeller, cfg))
 		{
-			// Product management (sellers can manage products)
+			// Product management (sellers can manage their own products)
 			sellerProducts := seller.Group("/products")
+			sellerProducts.Use(middleware.RequirePermission(models.PermissionProductsWrite, cfg))
 			{
 				sellerProducts.POST("/", adminHandler.CreateProduct)
 				sellerProducts.GET("/", adminHandler.GetProducts)
 				sellerProducts.PUT("/:id", adminHandler.UpdateProduct)
+				sellerProducts.POST("/:id/variants", adminHandler.CreateProductVariant)
+				sellerProducts.PUT("/:id/variants/:variant_id", adminHandler.UpdateProductVariant)
+				sellerProducts.GET("/:id/prices", adminHandler.GetProductPrices)
+				sellerProducts.PUT("/:id/prices", adminHandler.SetProductPrices)
 				// Sellers cannot delete products
 			}
 
-			// Order management (sellers can only ship orders)
+			// Order management (sellers see and ship only orders with their products)
 			sellerOrders := seller.Group("/orders")
+			sellerOrders.Use(middleware.RequirePermission(models.PermissionOrdersShip, cfg))
 			{
 				sellerOrders.GET("/", adminHandler.GetAllOrders)
 				sellerOrders.POST("/:id/ship", adminHandler.ShipOrder)
 			}
+
+			// Shipments (sellers ship their own part of each order)
+			sellerShipments := seller.Group("/shipments")
+			sellerShipments.Use(middleware.RequirePermission(models.PermissionOrdersShip, cfg))
+			{
+				sellerShipments.GET("/", shipmentHandler.GetShipments)
+				sellerShipments.POST("/:id/ship", shipmentHandler.ShipShipment)
+			}
 		}
 	}
 	return router
@@ -0,0 +1,126 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-shop/config"
+	"go-shop/database"
+	"go-shop/models"
+	"go-shop/services"
+	"go-shop/utils"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestSandboxPaymentRoute(t *testing.T) {
+	defer gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		ginMode    string
+		wantStatus int
+	}{
+		// Registered behind the super admin check
+		{"debug mode", gin.DebugMode, http.StatusUnauthorized},
+		// Not registered at all
+		{"release mode", gin.ReleaseMode, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Server:   config.ServerConfig{GinMode: tt.ginMode},
+				Payment:  config.PaymentConfig{Provider: services.SandboxProviderName, WebhookSecret: "test-webhook-secret"},
+				Currency: config.CurrencyConfig{Base: "USD"},
+			}
+			router := SetupRoutes(cfg)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/super-admin/payments/sandbox/sbx_1", nil)
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestSandboxPaymentRequiresOrdersManage(t *testing.T) {
+	defer gin.SetMode(gin.TestMode)
+
+	server := miniredis.RunT(t)
+	previous := database.RedisClient
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	database.RedisClient = client
+	t.Cleanup(func() {
+		database.RedisClient = previous
+		client.Close()
+	})
+
+	cfg := &config.Config{
+		Server:   config.ServerConfig{GinMode: gin.DebugMode},
+		JWT:      config.JWTConfig{Issuer: "go-shop-test", AccessExpireMinutes: 15},
+		Payment:  config.PaymentConfig{Provider: services.SandboxProviderName, WebhookSecret: "test-webhook-secret"},
+		Currency: config.CurrencyConfig{Base: "USD"},
+		RBAC:     config.RBACConfig{PermissionCacheSeconds: 60},
+	}
+	if err := utils.LoadJWTKeys(cfg); err != nil {
+		t.Fatal(err)
+	}
+	router := SetupRoutes(cfg)
+
+	tests := []struct {
+		name       string
+		userID     uint
+		access     database.UserAccess
+		wantStatus int
+	}{
+		{"buyer", 1, database.UserAccess{Roles: []string{models.ROLE_USER}, Permissions: []string{}}, http.StatusForbidden},
+		{"seller", 2, database.UserAccess{Roles: []string{models.ROLE_SELLER}, Permissions: []string{models.PermissionOrdersShip}}, http.StatusForbidden},
+		// Passes the permission check and fails on the empty body
+		{"orders manager", 3, database.UserAccess{Roles: []string{models.ROLE_SUPER_ADMIN}, Permissions: []string{models.PermissionOrdersManage}}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			// Permissions come from the cache, so no database is needed
+			cached, err := json.Marshal(tt.access)
+			if err != nil {
+				t.Fatal(err)
+			}
+			server.Set(fmt.Sprintf("user_access:0:%d", tt.userID), string(cached))
+
+			sessionID := fmt.Sprintf("session-%d", tt.userID)
+			session := &database.RefreshSession{ID: sessionID, UserID: tt.userID, CreatedAt: time.Now(), LastSeenAt: time.Now()}
+			if err := database.CreateRefreshSession(ctx, session, "hash", time.Hour); err != nil {
+				t.Fatal(err)
+			}
+			token, _, err := utils.GenerateToken(tt.userID, "user@example.com", tt.access.Roles[0], sessionID, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/super-admin/payments/sandbox/sbx_1", strings.NewReader("{}"))
+			req.Header.Set("Authorization", "Bearer "+token)
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"go-shop/database"
+	"go-shop/models"
+
+	"gorm.io/gorm"
+)
+
+type AddressService struct{}
+
+func NewAddressService() *AddressService {
+	return &AddressService{}
+}
+
+// GetAddresses returns the address book of a user, the default address first
+func (as *AddressService) GetAddresses(userID uint) ([]models.AddressResponse, error) {
+	var addresses []models.Address
+	if err := database.DB.Where("user_id = ?", userID).Order("is_default DESC, created_at DESC").Find(&addresses).Error; err != nil {
+		return nil, errors.New("failed to get addresses")
+	}
+
+	responses := []models.AddressResponse{}
+	for i := range addresses {
+		responses = append(responses, *toAddressResponse(&addresses[i]))
+	}
+	return responses, nil
+}
+
+func (as *AddressService) GetAddress(userID, addressID uint) (*models.AddressResponse, error) {
+	address, err := findAddress(database.DB, userID, addressID)
+	if err != nil {
+		return nil, err
+	}
+	return toAddressResponse(address), nil
+}
+
+// CreateAddress adds an address to the address book; the first address becomes the default
+func (as *AddressService) CreateAddress(userID uint, req *models.AddressRequest) (*models.AddressResponse, error) {
+	address := models.Address{UserID: userID}
+	if err := fillAddress(&address, req); err != nil {
+		return nil, err
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Address{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			return err
+		}
+		address.IsDefault = req.IsDefault || count == 0
+
+		if err := tx.Create(&address).Error; err != nil {
+			return err
+		}
+		if address.IsDefault {
+			return makeDefaultAddress(tx, &address)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New("failed to create address")
+	}
+
+	return toAddressResponse(&address), nil
+}
+
+// UpdateAddress replaces an address; orders already placed keep the address they were shipped to.
+// is_default=false does not unset the default, another address has to be made the default instead.
+func (as *AddressService) UpdateAddress(userID, addressID uint, req *models.AddressRequest) (*models.AddressResponse, error) {
+	address, err := findAddress(database.DB, userID, addressID)
+	if err != nil {
+		return nil, err
+	}
+	if err := fillAddress(address, req); err != nil {
+		return nil, err
+	}
+	address.IsDefault = address.IsDefault || req.IsDefault
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(address).Error; err != nil {
+			return err
+		}
+		if address.IsDefault {
+			return makeDefaultAddress(tx, address)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New("failed to update address")
+	}
+
+	return toAddressResponse(address), nil
+}
+
+// DeleteAddress removes an address; the newest remaining address becomes the default in its place
+func (as *AddressService) DeleteAddress(userID, addressID uint) error {
+	address, err := findAddress(database.DB, userID, addressID)
+	if err != nil {
+		return err
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(address).Error; err != nil {
+			return err
+		}
+		if !address.IsDefault {
+			return nil
+		}
+
+		var next models.Address
+		if err := tx.Where("user_id = ?", userID).Order("created_at DESC").First(&next).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		return makeDefaultAddress(tx, &next)
+	})
+	if err != nil {
+		return errors.New("failed to delete address")
+	}
+	return nil
+}
+
+func findAddress(db *gorm.DB, userID, addressID uint) (*models.Address, error) {
+	var address models.Address
+	if err := db.Where("id = ? AND user_id = ?", addressID, userID).First(&address).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("address not found")
+		}
+		return nil, errors.New("database error")
+	}
+	return &address, nil
+}
+
+// fillAddress validates a request and copies it onto the address
+func fillAddress(address *models.Address, req *models.AddressRequest) error {
+	country := models.NormalizeCountry(req.Country)
+	if country == "" {
+		return errors.New("country must be an ISO 3166-1 alpha-2 code")
+	}
+
+	address.Label = strings.TrimSpace(req.Label)
+	address.PostalAddress = models.PostalAddress{
+		FullName:   strings.TrimSpace(req.FullName),
+		Phone:      strings.TrimSpace(req.Phone),
+		Line1:      strings.TrimSpace(req.Line1),
+		Line2:      strings.TrimSpace(req.Line2),
+		City:       strings.TrimSpace(req.City),
+		Region:     strings.TrimSpace(req.Region),
+		PostalCode: strings.TrimSpace(req.PostalCode),
+		Country:    country,
+	}
+	return nil
+}
+
+// makeDefaultAddress makes an address the only default address of its user
+func makeDefaultAddress(tx *gorm.DB, address *models.Address) error {
+	if err := tx.Model(&models.Address{}).Where("user_id = ? AND id <> ? AND is_default = ?", address.UserID, address.ID, true).
+		Update("is_default", false).Error; err != nil {
+		return err
+	}
+	address.IsDefault = true
+	return tx.Model(address).Update("is_default", true).Error
+}
+
+// shippingAddress returns the address an order is shipped to: the given one, or the user's default address
+func shippingAddress(tx *gorm.DB, userID uint, addressID *uint) (*models.Address, error) {
+	if addressID != nil {
+		return findAddress(tx, userID, *addressID)
+	}
+
+	var address models.Address
+	if err := tx.Where("user_id = ? AND is_default = ?", userID, true).First(&address).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("add a shipping address to your address book before ordering")
+		}
+		return nil, errors.New("database error")
+	}
+	return &address, nil
+}
+
+func toAddressResponse(address *models.Address) *models.AddressResponse {
+	return &models.AddressResponse{
+		ID:            address.ID,
+		Label:         address.Label,
+		PostalAddress: address.PostalAddress,
+		IsDefault:     address.IsDefault,
+		CreatedAt:     address.CreatedAt,
+		UpdatedAt:     address.UpdatedAt,
+	}
+}
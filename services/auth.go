@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"time"
 
 	"go-shop/config"
@@ -17,14 +18,21 @@ import (
 )
 
 type AuthService struct {
-	config       *config.Config
-	emailService *EmailService
+	config        *config.Config
+	emailService  *EmailService
+	oidcProviders map[string]*OIDCProvider
 }
 
 func NewAuthService(cfg *config.Config, emailService *EmailService) *AuthService {
+	oidcProviders := make(map[string]*OIDCProvider)
+	for _, providerConfig := range cfg.OIDC.Providers {
+		oidcProviders[providerConfig.Name] = NewOIDCProvider(providerConfig, cfg.OIDC.RedirectBaseURL)
+	}
+
 	return &AuthService{
-		config:       cfg,
-		emailService: emailService,
+		config:        cfg,
+		emailService:  emailService,
+		oidcProviders: oidcProviders,
 	}
 }
 
@@ -35,10 +43,10 @@ func (as *AuthService) Register(req *models.UserCreateRequest) (*models.UserResp
 		return nil, errors.New("user with this email already exists")
 	}
 
-	// Check if user is pending verification
+	// Registering again while verification is pending sends a new OTP, at most once per cooldown
 	ctx := context.Background()
-	if database.CheckPendingUserExists(ctx, req.Email) {
-		return nil, errors.New("user registration is pending verification")
+	if err := as.startOTPCooldown(otpPurposeRegistration, req.Email); err != nil {
+		return nil, err
 	}
 
 	// Hash password
@@ -70,6 +78,7 @@ func (as *AuthService) Register(req *models.UserCreateRequest) (*models.UserResp
 	if err := database.SetOTP(ctx, req.Email, otp, expiration); err != nil {
 		return nil, errors.New("failed to store OTP")
 	}
+	database.DeleteOTPAttempts(ctx, otpPurposeRegistration, limitKey(req.Email))
 
 	// Send OTP email
 	if err := as.emailService.SendOTPEmail(req.Email, otp); err != nil {
@@ -85,16 +94,32 @@ func (as *AuthService) Register(req *models.UserCreateRequest) (*models.UserResp
 	}, nil
 }
 
-func (as *AuthService) VerifyOTP(req *models.OTPVerifyRequest) (*models.UserResponse, error) {
+// VerifyOTP activates a pending registration. Wrong guesses count towards the lockout of
+// the email and the client IP, and too many of them invalidate the OTP.
+func (as *AuthService) VerifyOTP(req *models.OTPVerifyRequest, clientIP string) (*models.UserResponse, error) {
 	ctx := context.Background()
 
+	if err := as.checkAuthLockout(limitScopeOTP, req.Email, clientIP); err != nil {
+		return nil, err
+	}
+
 	// Verify OTP
 	storedOTP, err := database.GetOTP(ctx, req.Email)
 	if err != nil {
 		return nil, errors.New("invalid or expired OTP")
 	}
 
-	if storedOTP != req.OTP {
+	matched, invalidated := as.checkOTP(otpPurposeRegistration, req.Email, storedOTP, req.OTP, func(ctx context.Context) {
+		database.DeleteOTP(ctx, req.Email)
+		database.DeletePendingUser(ctx, req.Email)
+	})
+	if !matched {
+		if err := as.recordAuthFailure(limitScopeOTP, req.Email, clientIP); err != nil {
+			return nil, err
+		}
+		if invalidated {
+			return nil, errors.New("too many invalid attempts, please register again")
+		}
 		return nil, errors.New("invalid OTP")
 	}
 
@@ -123,25 +148,15 @@ func (as *AuthService) VerifyOTP(req *models.OTPVerifyRequest) (*models.UserResp
 	}
 
 	// Assign default user role
-	var userRole models.Role
-	if err := database.DB.Where("name = ?", models.ROLE_USER).First(&userRole).Error; err != nil {
-		log.Printf("Warning: Failed to find user role: %v", err)
-	} else {
-		// Create user role assignment
-		userRoleAssignment := models.UserRole{
-			UserID: user.ID,
-			RoleID: userRole.ID,
-		}
-		if err := database.DB.Create(&userRoleAssignment).Error; err != nil {
-			log.Printf("Warning: Failed to assign default role to user: %v", err)
-		} else {
-			log.Printf("Assigned default role 'user' to new user: %s", user.Email)
-		}
+	if err := assignDefaultRole(database.DB, &user); err != nil {
+		log.Printf("Warning: %v", err)
 	}
 
 	// Clean up Redis data
 	database.DeleteOTP(ctx, req.Email)
 	database.DeletePendingUser(ctx, req.Email)
+	database.DeleteOTPAttempts(ctx, otpPurposeRegistration, limitKey(req.Email))
+	as.clearAuthFailures(limitScopeOTP, req.Email)
 
 	// Send welcome email
 	if err := as.emailService.SendWelcomeEmail(user.Email, user.FirstName); err != nil {
@@ -178,11 +193,41 @@ func (as *AuthService) VerifyOTP(req *models.OTPVerifyRequest) (*models.UserResp
 	}, nil
 }
 
-func (as *AuthService) Login(req *models.UserLoginRequest) (*models.LoginResponse, error) {
+// assignDefaultRole gives a new user the user role
+func assignDefaultRole(db *gorm.DB, user *models.User) error {
+	var userRole models.Role
+	if err := db.Where("name = ?", models.ROLE_USER).First(&userRole).Error; err != nil {
+		return fmt.Errorf("failed to find user role: %v", err)
+	}
+
+	// Create user role assignment
+	userRoleAssignment := models.UserRole{
+		UserID: user.ID,
+		RoleID: userRole.ID,
+	}
+	if err := db.Create(&userRoleAssignment).Error; err != nil {
+		return fmt.Errorf("failed to assign default role to user: %v", err)
+	}
+
+	log.Printf("Assigned default role 'user' to new user: %s", user.Email)
+	return nil
+}
+
+// Login checks credentials and starts a session on the client's device.
+// Failed attempts lock out the email and the client IP for exponentially growing periods.
+func (as *AuthService) Login(req *models.UserLoginRequest, client models.ClientInfo) (*models.LoginResponse, error) {
+	if err := as.checkAuthLockout(limitScopeLogin, req.Email, client.IP); err != nil {
+		return nil, err
+	}
+
 	// Find user with roles
 	var user models.User
 	if err := database.DB.Preload("Roles").Where("email = ?", req.Email).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Unknown emails count too, so guessing does not reveal which accounts exist
+			if err := as.recordAuthFailure(limitScopeLogin, req.Email, client.IP); err != nil {
+				return nil, err
+			}
 			return nil, errors.New("invalid email or password")
 		}
 		return nil, errors.New("database error")
@@ -193,19 +238,35 @@ func (as *AuthService) Login(req *models.UserLoginRequest) (*models.LoginRespons
 	}
 
 	if !utils.CheckPasswordHash(req.Password, user.Password) {
+		if err := as.recordAuthFailure(limitScopeLogin, req.Email, client.IP); err != nil {
+			return nil, err
+		}
 		return nil, errors.New("invalid email or password")
 	}
 
-	// Get user's primary role (first role or default to user)
-	userRole := models.ROLE_USER
-	if len(user.Roles) > 0 {
-		userRole = user.Roles[0].Name
+	// Accounts with 2FA, and roles that require it, answer a challenge before getting tokens
+	if user.TOTPEnabled || requiresTwoFactor(&user) {
+		return as.startTwoFactorChallenge(&user, req.CartToken, client)
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateToken(user.ID, user.Email, userRole, as.config)
+	return as.completeLogin(&user, req.CartToken, client)
+}
+
+// completeLogin starts a session for an authenticated user and merges their guest cart
+func (as *AuthService) completeLogin(user *models.User, cartToken string, client models.ClientInfo) (*models.LoginResponse, error) {
+	as.clearAuthFailures(limitScopeLogin, user.Email)
+
+	// Start a session with an access and a refresh token
+	tokens, err := issueTokens(as.config, user, primaryRole(user), client)
 	if err != nil {
-		return nil, errors.New("failed to generate token")
+		return nil, err
+	}
+
+	// Merge guest cart into the user's cart
+	if cartToken != "" {
+		if err := mergeGuestCart(as.config, cartToken, user.ID); err != nil {
+			log.Printf("Warning: Failed to merge guest cart for user %d: %v", user.ID, err)
+		}
 	}
 
 	// Convert roles to response format
@@ -228,16 +289,86 @@ func (as *AuthService) Login(req *models.UserLoginRequest) (*models.LoginRespons
 		Roles:     roleResponses,
 		IsActive:  user.IsActive,
 		CreatedAt: user.CreatedAt,
+
+		TwoFactorEnabled: user.TOTPEnabled,
 	}
 
-	// Return response with token
+	// Return response with tokens
 	return &models.LoginResponse{
-		User:  *response,
-		Token: token,
+		User:         response,
+		Token:        tokens.Token,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    &tokens.ExpiresAt,
+	}, nil
+}
+
+// Refresh rotates a refresh token: the presented token is invalidated and a new pair is issued.
+// Presenting an already rotated token revokes its session.
+func (as *AuthService) Refresh(req *models.RefreshTokenRequest) (*models.TokenResponse, error) {
+	sessionID, secret, ok := splitRefreshToken(req.RefreshToken)
+	if !ok {
+		return nil, database.ErrRefreshTokenInvalid
+	}
+
+	newSecret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, errors.New("failed to generate refresh token")
+	}
+
+	ctx := context.Background()
+	expiration := time.Duration(as.config.JWT.RefreshExpireHours) * time.Hour
+	userID, err := database.RotateRefreshSession(ctx, sessionID, utils.HashToken(secret), utils.HashToken(newSecret), expiration)
+	if err != nil {
+		if errors.Is(err, database.ErrRefreshTokenReused) {
+			log.Printf("Refresh token reuse detected for session %s, session revoked", sessionID)
+		}
+		if errors.Is(err, database.ErrRefreshTokenInvalid) || errors.Is(err, database.ErrRefreshTokenReused) {
+			return nil, err
+		}
+		return nil, errors.New("failed to refresh session")
+	}
+
+	// Roles and activation may have changed since login
+	var user models.User
+	if err := database.DB.Preload("Roles").First(&user, userID).Error; err != nil || !user.IsActive {
+		database.DeleteRefreshSession(ctx, userID, sessionID)
+		return nil, database.ErrRefreshTokenInvalid
+	}
+
+	token, claims, err := utils.GenerateToken(user.ID, user.Email, primaryRole(&user), sessionID, as.config)
+	if err != nil {
+		return nil, errors.New("failed to generate token")
+	}
+
+	return &models.TokenResponse{
+		Token:        token,
+		RefreshToken: sessionID + "." + newSecret,
+		ExpiresAt:    claims.ExpiresAt.Time,
 	}, nil
 }
 
+// Logout ends the session of an access token: its refresh token stops working and
+// the access token itself is denylisted until it expires
+func (as *AuthService) Logout(claims *utils.Claims) error {
+	ctx := context.Background()
+
+	if _, err := database.DeleteRefreshSession(ctx, claims.UserID, claims.SessionID); err != nil {
+		return errors.New("failed to revoke session")
+	}
+
+	if err := database.DenyToken(ctx, claims.ID, time.Until(claims.ExpiresAt.Time)); err != nil {
+		return errors.New("failed to revoke token")
+	}
+
+	return nil
+}
+
 func (as *AuthService) RequestPasswordReset(req *models.PasswordResetRequest) error {
+	// The cooldown applies to unknown emails as well, so it does not reveal which accounts exist
+	if err := as.startOTPCooldown(otpPurposePasswordReset, req.Email); err != nil {
+		return err
+	}
+
 	// Check if user exists
 	var user models.User
 	if err := database.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
@@ -260,6 +391,7 @@ func (as *AuthService) RequestPasswordReset(req *models.PasswordResetRequest) er
 	if err := database.SetPasswordResetToken(ctx, req.Email, otp, expiration); err != nil {
 		return errors.New("failed to store reset token")
 	}
+	database.DeleteOTPAttempts(ctx, otpPurposePasswordReset, limitKey(req.Email))
 
 	// Send password reset email
 	if err := as.emailService.SendPasswordResetEmail(req.Email, otp); err != nil {
@@ -270,16 +402,30 @@ func (as *AuthService) RequestPasswordReset(req *models.PasswordResetRequest) er
 	return nil
 }
 
-func (as *AuthService) ResetPassword(req *models.PasswordResetConfirmRequest) error {
+// ResetPassword sets a new password with the OTP from RequestPasswordReset; guesses are limited like in VerifyOTP
+func (as *AuthService) ResetPassword(req *models.PasswordResetConfirmRequest, clientIP string) error {
 	ctx := context.Background()
 
+	if err := as.checkAuthLockout(limitScopeReset, req.Email, clientIP); err != nil {
+		return err
+	}
+
 	// Verify OTP
 	storedOTP, err := database.GetPasswordResetToken(ctx, req.Email)
 	if err != nil {
 		return errors.New("invalid or expired reset token")
 	}
 
-	if storedOTP != req.OTP {
+	matched, invalidated := as.checkOTP(otpPurposePasswordReset, req.Email, storedOTP, req.OTP, func(ctx context.Context) {
+		database.DeletePasswordResetToken(ctx, req.Email)
+	})
+	if !matched {
+		if err := as.recordAuthFailure(limitScopeReset, req.Email, clientIP); err != nil {
+			return err
+		}
+		if invalidated {
+			return errors.New("too many invalid attempts, please request a new reset code")
+		}
 		return errors.New("invalid reset token")
 	}
 
@@ -302,6 +448,51 @@ func (as *AuthService) ResetPassword(req *models.PasswordResetConfirmRequest) er
 
 	// Clean up Redis data
 	database.DeletePasswordResetToken(ctx, req.Email)
+	database.DeleteOTPAttempts(ctx, otpPurposePasswordReset, limitKey(req.Email))
+	as.clearAuthFailures(limitScopeReset, req.Email)
+
+	// Whoever knew the old password is logged out
+	if err := revokeUserSessions(as.config, user.ID); err != nil {
+		log.Printf("Warning: Failed to revoke sessions of user %d after password reset: %v", user.ID, err)
+	}
 
 	return nil
 }
+
+// GetSessions lists the active sessions of a user, most recently used first
+func (as *AuthService) GetSessions(userID uint, currentSessionID string) ([]models.SessionResponse, error) {
+	sessions, err := database.GetUserRefreshSessions(context.Background(), userID)
+	if err != nil {
+		return nil, errors.New("failed to get sessions")
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+
+	responses := []models.SessionResponse{}
+	for _, session := range sessions {
+		responses = append(responses, models.SessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			LastIP:     session.LastIP,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			Current:    session.ID == currentSessionID,
+		})
+	}
+	return responses, nil
+}
+
+// RevokeSession ends one session of a user; its refresh token and access tokens stop working
+func (as *AuthService) RevokeSession(userID uint, sessionID string) error {
+	deleted, err := database.DeleteRefreshSession(context.Background(), userID, sessionID)
+	if err != nil {
+		return errors.New("failed to revoke session")
+	}
+	if !deleted {
+		return errors.New("session not found")
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go-shop/database"
+)
+
+// Scopes of failure counters: per email for each kind of secret, and one per client IP for all of them
+const (
+	limitScopeLogin = "login"
+	limitScopeOTP   = "otp"
+	limitScopeReset = "reset"
+	limitScopeIP    = "ip"
+)
+
+// OTP purposes, for attempt counters and resend cooldowns
+const (
+	otpPurposeRegistration  = "registration"
+	otpPurposePasswordReset = "password_reset"
+)
+
+// TooManyAttemptsError is returned while an email or IP is locked out, or an OTP resend is on cooldown
+type TooManyAttemptsError struct {
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *TooManyAttemptsError) Error() string {
+	return e.Message
+}
+
+func lockoutError(retryAfter time.Duration) error {
+	return &TooManyAttemptsError{
+		Message:    fmt.Sprintf("too many failed attempts, try again in %s", retryAfter.Round(time.Second)),
+		RetryAfter: retryAfter,
+	}
+}
+
+// limitKey normalizes an email so case variants share one counter
+func limitKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func (as *AuthService) emailLimit() database.AuthLimit {
+	return database.AuthLimit{
+		MaxFailures: as.config.AuthLimit.MaxFailures,
+		Window:      time.Duration(as.config.AuthLimit.FailureWindowMinutes) * time.Minute,
+		Lockout:     time.Duration(as.config.AuthLimit.LockoutSeconds) * time.Second,
+		MaxLockout:  time.Duration(as.config.AuthLimit.MaxLockoutSeconds) * time.Second,
+	}
+}
+
+func (as *AuthService) ipLimit() database.AuthLimit {
+	limit := as.emailLimit()
+	limit.MaxFailures = as.config.AuthLimit.MaxIPFailures
+	return limit
+}
+
+// checkAuthLockout rejects attempts on a locked out email or from a locked out IP
+func (as *AuthService) checkAuthLockout(scope, email, ip string) error {
+	ctx := context.Background()
+
+	retryAfter, err := database.GetAuthLockout(ctx, scope, limitKey(email))
+	if err != nil {
+		return errors.New("failed to check login attempts")
+	}
+	if ip != "" {
+		ipRetryAfter, err := database.GetAuthLockout(ctx, limitScopeIP, ip)
+		if err != nil {
+			return errors.New("failed to check login attempts")
+		}
+		retryAfter = max(retryAfter, ipRetryAfter)
+	}
+
+	if retryAfter > 0 {
+		return lockoutError(retryAfter)
+	}
+	return nil
+}
+
+// recordAuthFailure counts a failed attempt against the email and the IP. Returns a TooManyAttemptsError
+// if this failure started a lockout, nil otherwise so the caller reports its own error.
+func (as *AuthService) recordAuthFailure(scope, email, ip string) error {
+	ctx := context.Background()
+
+	lockout, err := database.RecordAuthFailure(ctx, scope, limitKey(email), as.emailLimit())
+	if err != nil {
+		log.Printf("Failed to record %s failure: %v", scope, err)
+	}
+	if lockout > 0 {
+		log.Printf("Locked out %s attempts for %s for %s", scope, limitKey(email), lockout)
+	}
+
+	if ip != "" {
+		ipLockout, err := database.RecordAuthFailure(ctx, limitScopeIP, ip, as.ipLimit())
+		if err != nil {
+			log.Printf("Failed to record %s failure of IP: %v", scope, err)
+		}
+		if ipLockout > 0 {
+			log.Printf("Locked out IP %s for %s", ip, ipLockout)
+		}
+		lockout = max(lockout, ipLockout)
+	}
+
+	if lockout > 0 {
+		return lockoutError(lockout)
+	}
+	return nil
+}
+
+// clearAuthFailures resets the failures of an email after a successful attempt. IP counters only
+// expire, so an attacker cannot reset them by logging into an account of their own.
+func (as *AuthService) clearAuthFailures(scope, email string) {
+	if err := database.ClearAuthFailures(context.Background(), scope, limitKey(email)); err != nil {
+		log.Printf("Failed to clear %s failures: %v", scope, err)
+	}
+}
+
+// startOTPCooldown rejects sending another OTP to the email before the resend cooldown is over
+func (as *AuthService) startOTPCooldown(purpose, email string) error {
+	cooldown := time.Duration(as.config.AuthLimit.ResendCooldownSeconds) * time.Second
+	retryAfter, err := database.StartOTPCooldown(context.Background(), purpose, limitKey(email), cooldown)
+	if err != nil {
+		return errors.New("failed to check OTP cooldown")
+	}
+	if retryAfter > 0 {
+		return &TooManyAttemptsError{
+			Message:    fmt.Sprintf("a code was sent recently, request a new one in %s", retryAfter.Round(time.Second)),
+			RetryAfter: retryAfter,
+		}
+	}
+	return nil
+}
+
+// checkOTP compares an OTP with the stored one. After OTPMaxAttempts wrong guesses
+// invalidate is called so the code cannot be guessed further, and invalidated is true.
+func (as *AuthService) checkOTP(purpose, email, storedOTP, otp string, invalidate func(ctx context.Context)) (matched, invalidated bool) {
+	if subtle.ConstantTimeCompare([]byte(storedOTP), []byte(otp)) == 1 {
+		return true, false
+	}
+
+	ctx := context.Background()
+	expiration := time.Duration(as.config.OTP.ExpireMinutes) * time.Minute
+	attempts, err := database.IncrOTPAttempts(ctx, purpose, limitKey(email), expiration)
+	if err != nil {
+		log.Printf("Failed to count OTP attempts: %v", err)
+		return false, false
+	}
+
+	if as.config.AuthLimit.OTPMaxAttempts > 0 && attempts >= as.config.AuthLimit.OTPMaxAttempts {
+		invalidate(ctx)
+		database.DeleteOTPAttempts(ctx, purpose, limitKey(email))
+		log.Printf("Invalidated %s OTP of %s after %d wrong attempts", purpose, limitKey(email), attempts)
+		return false, true
+	}
+	return false, false
+}
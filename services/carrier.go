@@ -0,0 +1,101 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"go-shop/config"
+	"go-shop/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrUnknownCarrier = errors.New("unknown carrier")
+
+// Carrier is implemented by every shipping carrier integration
+type Carrier interface {
+	// Name returns the carrier identifier stored on shipping methods and shipments
+	Name() string
+	// CreateShipment registers a shipment of an order with the carrier and returns its tracking number
+	CreateShipment(order *models.Order, shipment *models.Shipment) (string, error)
+	// ValidateTrackingNumber checks a tracking number entered by hand
+	ValidateTrackingNumber(trackingNumber string) error
+	// TrackingURL returns the page buyers can follow the parcel on, or "" when the carrier has none
+	TrackingURL(trackingNumber string) string
+}
+
+// Carriers are the carrier integrations enabled in configuration, by name
+type Carriers map[string]Carrier
+
+// NewCarriers builds the carriers listed in SHIPPING_CARRIERS. The fake carrier is refused in
+// release mode, where it would hand buyers tracking numbers no carrier knows.
+func NewCarriers(cfg *config.Config) Carriers {
+	carriers := make(Carriers)
+	for _, name := range strings.Split(cfg.Shipping.Carriers, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "":
+			continue
+		case FakeCarrierName:
+			if cfg.Server.GinMode == gin.ReleaseMode {
+				log.Fatal("The fake shipping carrier cannot be used in release mode")
+			}
+			carriers[name] = NewFakeCarrier()
+		default:
+			log.Fatalf("Unknown shipping carrier: %s", name)
+		}
+	}
+	return carriers
+}
+
+// Get returns the carrier with a name
+func (cs Carriers) Get(name string) (Carrier, error) {
+	carrier, ok := cs[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCarrier, name)
+	}
+	return carrier, nil
+}
+
+// shipmentTracking is how a shipment was sent
+type shipmentTracking struct {
+	Carrier        string
+	TrackingNumber string
+	TrackingURL    string
+}
+
+// trackShipment resolves the carrier and tracking number of a shipment being shipped. The carrier
+// defaults to the one of the order's shipping method and issues a tracking number when none is
+// given. Orders without a carrier keep the tracking number as entered.
+func (cs Carriers) trackShipment(order *models.Order, shipment *models.Shipment, req *models.ShipmentShipRequest) (*shipmentTracking, error) {
+	name := order.ShippingCarrier
+	tracking := &shipmentTracking{}
+	if req != nil {
+		if req.Carrier != "" {
+			name = req.Carrier
+		}
+		tracking.TrackingNumber = strings.TrimSpace(req.TrackingNumber)
+	}
+	if name == "" {
+		return tracking, nil
+	}
+
+	carrier, err := cs.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if tracking.TrackingNumber == "" {
+		if tracking.TrackingNumber, err = carrier.CreateShipment(order, shipment); err != nil {
+			return nil, err
+		}
+	} else if err := carrier.ValidateTrackingNumber(tracking.TrackingNumber); err != nil {
+		return nil, err
+	}
+
+	tracking.Carrier = carrier.Name()
+	tracking.TrackingURL = carrier.TrackingURL(tracking.TrackingNumber)
+	return tracking, nil
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"go-shop/models"
+	"go-shop/utils"
+)
+
+const FakeCarrierName = "fake"
+
+// fakeTrackingNumberMinLength is the shortest tracking number the fake carrier accepts; the ones it
+// issues are "FAKE" followed by 12 characters
+const fakeTrackingNumberMinLength = 8
+
+// FakeCarrier issues tracking numbers locally without contacting anyone.
+// It is meant for local development and tests.
+type FakeCarrier struct{}
+
+func NewFakeCarrier() *FakeCarrier {
+	return &FakeCarrier{}
+}
+
+func (fc *FakeCarrier) Name() string {
+	return FakeCarrierName
+}
+
+func (fc *FakeCarrier) CreateShipment(order *models.Order, shipment *models.Shipment) (string, error) {
+	token, err := utils.GenerateRandomToken(6)
+	if err != nil {
+		return "", errors.New("failed to generate tracking number")
+	}
+	trackingNumber := "FAKE" + strings.ToUpper(token)
+
+	log.Printf("Fake carrier shipment %s created for order %s (shipment #%d)", trackingNumber, order.OrderNumber, shipment.ID)
+	return trackingNumber, nil
+}
+
+func (fc *FakeCarrier) ValidateTrackingNumber(trackingNumber string) error {
+	if len(trackingNumber) < fakeTrackingNumberMinLength {
+		return fmt.Errorf("tracking number must be at least %d characters long", fakeTrackingNumberMinLength)
+	}
+	for _, r := range trackingNumber {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return errors.New("tracking number must consist of upper-case letters and digits")
+		}
+	}
+	return nil
+}
+
+func (fc *FakeCarrier) TrackingURL(trackingNumber string) string {
+	return ""
+}
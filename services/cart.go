@@ -0,0 +1,421 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"go-shop/config"
+	"go-shop/database"
+	"go-shop/models"
+	"go-shop/utils"
+
+	"gorm.io/gorm"
+)
+
+const cartTokenBytes = 16
+
+// CartOwner identifies a cart: either an authenticated user or an anonymous cart token
+type CartOwner struct {
+	UserID   uint
+	Token    string
+	Currency string // Currency the cart is priced in; "" for the base currency
+}
+
+func (co CartOwner) key() string {
+	if co.UserID != 0 {
+		return fmt.Sprintf("user:%d", co.UserID)
+	}
+	return fmt.Sprintf("guest:%s", co.Token)
+}
+
+func (co CartOwner) validate() error {
+	if co.UserID != 0 {
+		return nil
+	}
+	if !isValidCartToken(co.Token) {
+		return errors.New("invalid cart token")
+	}
+	return nil
+}
+
+func isValidCartToken(token string) bool {
+	if len(token) != cartTokenBytes*2 {
+		return false
+	}
+	_, err := hex.DecodeString(token)
+	return err == nil
+}
+
+type CartService struct {
+	config       *config.Config
+	orderService *OrderService
+}
+
+func NewCartService(cfg *config.Config, orderService *OrderService) *CartService {
+	return &CartService{
+		config:       cfg,
+		orderService: orderService,
+	}
+}
+
+func (cs *CartService) expiration() time.Duration {
+	return time.Duration(cs.config.Cart.ExpireHours) * time.Hour
+}
+
+// CreateGuestCart issues a new anonymous cart token
+func (cs *CartService) CreateGuestCart() (*models.CartResponse, error) {
+	token, err := utils.GenerateRandomToken(cartTokenBytes)
+	if err != nil {
+		return nil, errors.New("failed to generate cart token")
+	}
+
+	return &models.CartResponse{
+		CartToken: token,
+		Items:     []models.CartItemResponse{},
+		Currency:  cs.config.Currency.Base,
+		Available: true,
+	}, nil
+}
+
+// GetCart returns the cart with live prices and stock
+func (cs *CartService) GetCart(owner CartOwner) (*models.CartResponse, error) {
+	if err := owner.validate(); err != nil {
+		return nil, err
+	}
+
+	items, err := database.GetCart(context.Background(), owner.key())
+	if err != nil {
+		return nil, errors.New("failed to get cart")
+	}
+
+	response, err := buildCartResponse(cs.config, items, owner.Currency)
+	if err != nil {
+		return nil, err
+	}
+	response.CartToken = owner.Token
+	return response, nil
+}
+
+// AddItem adds quantity of a product to the cart
+func (cs *CartService) AddItem(owner CartOwner, req *models.CartItemRequest) (*models.CartResponse, error) {
+	if err := owner.validate(); err != nil {
+		return nil, err
+	}
+
+	line := cartLine(req.ProductID, req.VariantID)
+	stock, err := checkStock(line, req.Quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lines already in the cart are capped at the stock
+	if _, err := database.AddCartItem(context.Background(), owner.key(), line, req.Quantity, stock, cs.expiration()); err != nil {
+		return nil, errors.New("failed to update cart")
+	}
+
+	return cs.GetCart(owner)
+}
+
+// UpdateItem sets the quantity of a cart line, removing it when quantity is 0
+func (cs *CartService) UpdateItem(owner CartOwner, productID uint, variantID *uint, req *models.CartItemUpdateRequest) (*models.CartResponse, error) {
+	if err := owner.validate(); err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	line := cartLine(productID, variantID)
+	if req.Quantity == 0 {
+		if err := database.RemoveCartItem(ctx, owner.key(), line); err != nil {
+			return nil, errors.New("failed to remove cart item")
+		}
+		return cs.GetCart(owner)
+	}
+
+	if err := cs.setItem(ctx, owner, line, req.Quantity); err != nil {
+		return nil, err
+	}
+
+	return cs.GetCart(owner)
+}
+
+// RemoveItem removes a line from the cart
+func (cs *CartService) RemoveItem(owner CartOwner, productID uint, variantID *uint) (*models.CartResponse, error) {
+	if err := owner.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := database.RemoveCartItem(context.Background(), owner.key(), cartLine(productID, variantID)); err != nil {
+		return nil, errors.New("failed to remove cart item")
+	}
+
+	return cs.GetCart(owner)
+}
+
+// ClearCart removes all lines from the cart
+func (cs *CartService) ClearCart(owner CartOwner) error {
+	if err := owner.validate(); err != nil {
+		return err
+	}
+
+	if err := database.DeleteCart(context.Background(), owner.key()); err != nil {
+		return errors.New("failed to clear cart")
+	}
+	return nil
+}
+
+// MergeGuestCart moves an anonymous cart into the user's cart and returns it priced in currency
+func (cs *CartService) MergeGuestCart(userID uint, currency string, req *models.CartMergeRequest) (*models.CartResponse, error) {
+	if err := mergeGuestCart(cs.config, req.CartToken, userID); err != nil {
+		return nil, err
+	}
+	return cs.GetCart(CartOwner{UserID: userID, Currency: currency})
+}
+
+// Checkout turns the user's cart into an order and clears the cart
+func (cs *CartService) Checkout(userID uint, checkout *models.CheckoutRequest) (*models.OrderResponse, error) {
+	ctx := context.Background()
+	owner := CartOwner{UserID: userID}
+	lockExpiration := time.Duration(cs.config.Cart.CheckoutLockSeconds) * time.Second
+
+	items, err := database.LockCart(ctx, owner.key(), lockExpiration)
+	if err != nil {
+		if errors.Is(err, database.ErrCartEmpty) || errors.Is(err, database.ErrCartLocked) {
+			return nil, err
+		}
+		return nil, errors.New("failed to lock cart")
+	}
+
+	if len(items) == 0 {
+		database.DeleteLockedCart(ctx, owner.key())
+		return nil, database.ErrCartEmpty
+	}
+
+	req := models.OrderCreateRequest{
+		CouponCode: checkout.CouponCode,
+		Currency:   checkout.Currency,
+		AddressID:  checkout.AddressID,
+
+		ShippingMethodID: checkout.ShippingMethodID,
+	}
+	for _, line := range sortedCartLines(items) {
+		req.Items = append(req.Items, models.OrderItemRequest{
+			ProductID: line.ProductID,
+			VariantID: lineVariantID(line),
+			Quantity:  items[line],
+		})
+	}
+
+	order, err := cs.orderService.CreateOrder(userID, &req)
+	if err != nil {
+		// Give the cart back so the user can fix it and retry
+		if unlockErr := database.UnlockCart(ctx, owner.key(), cs.expiration()); unlockErr != nil {
+			log.Printf("Failed to restore cart for user %d: %v", userID, unlockErr)
+		}
+		return nil, err
+	}
+
+	if err := database.DeleteLockedCart(ctx, owner.key()); err != nil {
+		log.Printf("Failed to delete checked out cart for user %d: %v", userID, err)
+	}
+
+	return order, nil
+}
+
+func (cs *CartService) setItem(ctx context.Context, owner CartOwner, line database.CartLine, quantity int) error {
+	if _, err := checkStock(line, quantity); err != nil {
+		return err
+	}
+
+	if err := database.SetCartItem(ctx, owner.key(), line, quantity, cs.expiration()); err != nil {
+		return errors.New("failed to update cart")
+	}
+	return nil
+}
+
+// checkStock fails unless quantity of a cart line is available and returns the available stock
+func checkStock(line database.CartLine, quantity int) (int, error) {
+	var product models.Product
+	if err := database.DB.First(&product, line.ProductID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, errors.New("product not found")
+		}
+		return 0, errors.New("database error")
+	}
+
+	if line.VariantID == 0 {
+		withVariants, err := productsWithVariants(database.DB, []uint{product.ID})
+		if err != nil {
+			return 0, err
+		}
+		if withVariants[product.ID] {
+			return 0, fmt.Errorf("choose a variant of product %s", product.Title)
+		}
+		if product.AvailableStock() < quantity {
+			return 0, fmt.Errorf("insufficient stock for product %s", product.Title)
+		}
+		return product.AvailableStock(), nil
+	}
+
+	var variant models.ProductVariant
+	if err := database.DB.Where("id = ? AND product_id = ?", line.VariantID, product.ID).First(&variant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, errors.New("product variant not found")
+		}
+		return 0, errors.New("database error")
+	}
+	if variant.AvailableStock() < quantity {
+		return 0, fmt.Errorf("insufficient stock for product %s (%s)", product.Title, variant.SKU)
+	}
+	return variant.AvailableStock(), nil
+}
+
+// mergeGuestCart moves the lines of an anonymous cart into a user's cart
+func mergeGuestCart(cfg *config.Config, token string, userID uint) error {
+	guest := CartOwner{Token: token}
+	if err := guest.validate(); err != nil {
+		return err
+	}
+
+	user := CartOwner{UserID: userID}
+	expiration := time.Duration(cfg.Cart.ExpireHours) * time.Hour
+	if err := database.MergeCart(context.Background(), guest.key(), user.key(), expiration); err != nil {
+		return errors.New("failed to merge cart")
+	}
+	return nil
+}
+
+func cartLine(productID uint, variantID *uint) database.CartLine {
+	line := database.CartLine{ProductID: productID}
+	if variantID != nil {
+		line.VariantID = *variantID
+	}
+	return line
+}
+
+func lineVariantID(line database.CartLine) *uint {
+	if line.VariantID == 0 {
+		return nil
+	}
+	variantID := line.VariantID
+	return &variantID
+}
+
+func sortedCartLines(items map[database.CartLine]int) []database.CartLine {
+	lines := make([]database.CartLine, 0, len(items))
+	for line := range items {
+		lines = append(lines, line)
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].ProductID != lines[j].ProductID {
+			return lines[i].ProductID < lines[j].ProductID
+		}
+		return lines[i].VariantID < lines[j].VariantID
+	})
+	return lines
+}
+
+func buildCartResponse(cfg *config.Config, items map[database.CartLine]int, currency string) (*models.CartResponse, error) {
+	lines := sortedCartLines(items)
+
+	productIDs := make([]uint, 0, len(lines))
+	var variantIDs []uint
+	for _, line := range lines {
+		productIDs = append(productIDs, line.ProductID)
+		if line.VariantID != 0 {
+			variantIDs = append(variantIDs, line.VariantID)
+		}
+	}
+
+	prices, err := loadPriceList(database.DB, cfg, currency, productIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.CartResponse{
+		Items:     []models.CartItemResponse{},
+		Currency:  prices.currency,
+		Available: true,
+	}
+	if len(items) == 0 {
+		return response, nil
+	}
+
+	var products []models.Product
+	if err := database.DB.Where("id IN ?", productIDs).Find(&products).Error; err != nil {
+		return nil, errors.New("failed to load cart products")
+	}
+
+	productsByID := make(map[uint]models.Product, len(products))
+	for _, product := range products {
+		productsByID[product.ID] = product
+	}
+
+	variantsByID := make(map[uint]models.ProductVariant, len(variantIDs))
+	if len(variantIDs) > 0 {
+		var variants []models.ProductVariant
+		if err := database.DB.Where("id IN ?", variantIDs).Find(&variants).Error; err != nil {
+			return nil, errors.New("failed to load cart product variants")
+		}
+		for _, variant := range variants {
+			variantsByID[variant.ID] = variant
+		}
+	}
+
+	for _, line := range lines {
+		quantity := items[line]
+		item := models.CartItemResponse{
+			ProductID: line.ProductID,
+			VariantID: lineVariantID(line),
+			Quantity:  quantity,
+		}
+
+		if product, ok := productsByID[line.ProductID]; ok {
+			item.Price = prices.Price(&product, nil)
+			item.Stock = product.AvailableStock()
+			item.Product = &models.ProductResponse{
+				ID:          product.ID,
+				CategoryID:  product.CategoryID,
+				SellerID:    product.SellerID,
+				Title:       product.Title,
+				Description: product.Description,
+				Images:      []string(product.Images),
+				Price:       item.Price,
+				Currency:    prices.currency,
+				Model:       product.Model,
+				ExtraInfo:   product.ExtraInfo,
+				Stock:       product.Stock,
+				OrderCount:  product.OrderCount,
+				CreatedAt:   product.CreatedAt,
+				UpdatedAt:   product.UpdatedAt,
+			}
+
+			if line.VariantID != 0 {
+				variant, ok := variantsByID[line.VariantID]
+				if ok && variant.ProductID == product.ID {
+					item.Variant = toVariantResponse(&variant, prices.Price(&product, &variant))
+					item.Price = item.Variant.Price
+					item.Stock = variant.AvailableStock()
+				} else {
+					item.Stock = 0
+				}
+			}
+
+			item.Subtotal = item.Price.Mul(quantity)
+			item.Available = item.Stock >= quantity
+		}
+
+		if !item.Available {
+			response.Available = false
+		}
+		response.TotalQuantity += quantity
+		response.TotalAmount += item.Subtotal
+		response.Items = append(response.Items, item)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"errors"
+	"math/big"
+
+	"go-shop/config"
+	"go-shop/database"
+	"go-shop/models"
+
+	"gorm.io/gorm"
+)
+
+var ErrUnsupportedCurrency = errors.New("unsupported currency")
+
+type CurrencyService struct {
+	cfg *config.Config
+}
+
+func NewCurrencyService(cfg *config.Config) *CurrencyService {
+	return &CurrencyService{
+		cfg: cfg,
+	}
+}
+
+// GetCurrencies returns the base currency and every currency with an exchange rate
+func (cs *CurrencyService) GetCurrencies() (*models.CurrenciesResponse, error) {
+	var rates []models.ExchangeRate
+	if err := database.DB.Order("currency ASC").Find(&rates).Error; err != nil {
+		return nil, errors.New("failed to get currencies")
+	}
+
+	response := &models.CurrenciesResponse{
+		Base:       cs.cfg.Currency.Base,
+		Currencies: []string{cs.cfg.Currency.Base},
+	}
+	for _, rate := range rates {
+		response.Currencies = append(response.Currencies, rate.Currency)
+	}
+	return response, nil
+}
+
+func (cs *CurrencyService) GetExchangeRates() ([]models.ExchangeRateResponse, error) {
+	var rates []models.ExchangeRate
+	if err := database.DB.Order("currency ASC").Find(&rates).Error; err != nil {
+		return nil, errors.New("failed to get exchange rates")
+	}
+
+	responses := []models.ExchangeRateResponse{}
+	for i := range rates {
+		responses = append(responses, toExchangeRateResponse(&rates[i]))
+	}
+	return responses, nil
+}
+
+// SetExchangeRate creates or updates the rate of a currency; prices shown afterwards use the new rate,
+// orders already placed keep theirs
+func (cs *CurrencyService) SetExchangeRate(currency string, req *models.ExchangeRateRequest) (*models.ExchangeRateResponse, error) {
+	code := models.NormalizeCurrency(currency)
+	if code == "" {
+		return nil, errors.New("currency must be an ISO 4217 code")
+	}
+	if code == cs.cfg.Currency.Base {
+		return nil, errors.New("the base currency has no exchange rate")
+	}
+
+	var rate models.ExchangeRate
+	err := database.DB.Where("currency = ?", code).First(&rate).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("database error")
+	}
+
+	rate.Currency = code
+	rate.Rate = req.Rate
+	if err := database.DB.Save(&rate).Error; err != nil {
+		return nil, errors.New("failed to save exchange rate")
+	}
+
+	response := toExchangeRateResponse(&rate)
+	return &response, nil
+}
+
+// DeleteExchangeRate stops offering a currency; its list prices are kept for when it comes back
+func (cs *CurrencyService) DeleteExchangeRate(currency string) error {
+	result := database.DB.Where("currency = ?", models.NormalizeCurrency(currency)).Delete(&models.ExchangeRate{})
+	if result.Error != nil {
+		return errors.New("failed to delete exchange rate")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("exchange rate not found")
+	}
+	return nil
+}
+
+func toExchangeRateResponse(rate *models.ExchangeRate) models.ExchangeRateResponse {
+	return models.ExchangeRateResponse{
+		Currency:  rate.Currency,
+		Rate:      rate.Rate,
+		UpdatedAt: rate.UpdatedAt,
+	}
+}
+
+// priceKey identifies a list price; VariantID is 0 for the product itself
+type priceKey struct {
+	ProductID uint
+	VariantID uint
+}
+
+// priceList prices products in one currency: list prices where they are set, otherwise the base
+// price converted at the exchange rate and rounded to the currency's smallest unit
+type priceList struct {
+	currency string
+	rate     *big.Rat // nil for the base currency
+	prices   map[priceKey]models.Money
+}
+
+// loadPriceList loads the exchange rate of a currency ("" for the base currency) and the list
+// prices of the given products in it
+func loadPriceList(db *gorm.DB, cfg *config.Config, currency string, productIDs []uint) (*priceList, error) {
+	if currency == "" {
+		currency = cfg.Currency.Base
+	}
+	code := models.NormalizeCurrency(currency)
+	if code == "" {
+		return nil, ErrUnsupportedCurrency
+	}
+
+	list := &priceList{currency: code}
+	if code == cfg.Currency.Base {
+		return list, nil
+	}
+
+	var rate models.ExchangeRate
+	if err := db.Where("currency = ?", code).First(&rate).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUnsupportedCurrency
+		}
+		return nil, errors.New("database error")
+	}
+	list.rate = models.DecimalRat(rate.Rate)
+
+	list.prices = make(map[priceKey]models.Money)
+	if len(productIDs) > 0 {
+		var prices []models.ProductPrice
+		if err := db.Where("currency = ? AND product_id IN ?", code, productIDs).Find(&prices).Error; err != nil {
+			return nil, errors.New("failed to get product prices")
+		}
+		for _, price := range prices {
+			list.prices[priceKey{ProductID: price.ProductID, VariantID: price.VariantID}] = price.Price
+		}
+	}
+	return list, nil
+}
+
+// Price returns the price of a product, or of one of its variants when variant is set. A variant
+// without an own base price uses the product's list price.
+func (pl *priceList) Price(product *models.Product, variant *models.ProductVariant) models.Money {
+	if variant != nil {
+		if price, ok := pl.prices[priceKey{ProductID: product.ID, VariantID: variant.ID}]; ok {
+			return price
+		}
+		if variant.Price != nil {
+			return pl.Convert(*variant.Price)
+		}
+	}
+	if price, ok := pl.prices[priceKey{ProductID: product.ID}]; ok {
+		return price
+	}
+	return pl.Convert(product.Price)
+}
+
+// Convert converts an amount from the base currency
+func (pl *priceList) Convert(amount models.Money) models.Money {
+	if pl.rate == nil {
+		return amount
+	}
+	return amount.MulRat(pl.rate).RoundTo(pl.currency)
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"go-shop/database"
+	"go-shop/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrUnknownOIDCProvider is returned for a provider that is not configured
+var ErrUnknownOIDCProvider = errors.New("unknown sign-in provider")
+
+// oidcRandomString returns a URL-safe random value for state, nonce and PKCE verifier
+func oidcRandomString() (string, error) {
+	value := make([]byte, 32)
+	if _, err := rand.Read(value); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(value), nil
+}
+
+// OIDCProviders returns the names of the configured sign-in providers
+func (as *AuthService) OIDCProviders() []string {
+	names := make([]string, 0, len(as.oidcProviders))
+	for name := range as.oidcProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StartOIDCLogin prepares a sign-in at a provider and returns the URL to send the user to
+func (as *AuthService) StartOIDCLogin(providerName string, req *models.OIDCLoginRequest) (*models.OIDCLoginResponse, error) {
+	provider, ok := as.oidcProviders[providerName]
+	if !ok {
+		return nil, ErrUnknownOIDCProvider
+	}
+
+	var values [3]string
+	for i := range values {
+		value, err := oidcRandomString()
+		if err != nil {
+			return nil, errors.New("failed to start sign-in")
+		}
+		values[i] = value
+	}
+	state, nonce, codeVerifier := values[0], values[1], values[2]
+
+	ctx := context.Background()
+	authorizationURL, err := provider.AuthorizationURL(ctx, state, nonce, codeVerifier)
+	if err != nil {
+		log.Printf("OIDC: %v", err)
+		return nil, errors.New("sign-in provider is unavailable")
+	}
+
+	expiration := time.Duration(as.config.OIDC.StateExpireMinutes) * time.Minute
+	pending := &database.OIDCState{
+		Provider:     provider.Name(),
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		CartToken:    req.CartToken,
+	}
+	if err := database.SetOIDCState(ctx, state, pending, expiration); err != nil {
+		return nil, errors.New("failed to store sign-in state")
+	}
+
+	return &models.OIDCLoginResponse{
+		AuthorizationURL: authorizationURL,
+		State:            state,
+		ExpiresAt:        time.Now().Add(expiration),
+	}, nil
+}
+
+// CompleteOIDCLogin finishes a sign-in when the provider redirects back: the code is exchanged,
+// the ID token verified and the user logged in like with a password, including the 2FA step
+func (as *AuthService) CompleteOIDCLogin(providerName string, req *models.OIDCCallbackRequest, client models.ClientInfo) (*models.LoginResponse, error) {
+	provider, ok := as.oidcProviders[providerName]
+	if !ok {
+		return nil, ErrUnknownOIDCProvider
+	}
+
+	ctx := context.Background()
+	pending, err := database.TakeOIDCState(ctx, req.State)
+	if err != nil {
+		if errors.Is(err, database.ErrOIDCStateInvalid) {
+			return nil, err
+		}
+		return nil, errors.New("failed to get sign-in state")
+	}
+	if pending.Provider != provider.Name() {
+		return nil, database.ErrOIDCStateInvalid
+	}
+
+	if req.Error != "" {
+		return nil, errors.New("sign-in was not completed at the provider: " + req.Error)
+	}
+	if req.Code == "" {
+		return nil, errors.New("authorization code is missing")
+	}
+
+	tokens, err := provider.Exchange(ctx, req.Code, pending.CodeVerifier)
+	if err != nil {
+		log.Printf("OIDC: %v", err)
+		return nil, errors.New("failed to verify sign-in with the provider")
+	}
+
+	identity, err := provider.VerifyIDToken(ctx, tokens.IDToken, pending.Nonce)
+	if err != nil {
+		log.Printf("OIDC %s: %v", provider.Name(), err)
+		return nil, errors.New("failed to verify sign-in with the provider")
+	}
+	if identity.Email == "" {
+		if err := provider.UserInfo(ctx, tokens.AccessToken, identity); err != nil {
+			log.Printf("OIDC: %v", err)
+		}
+	}
+
+	user, err := as.oidcUser(provider.Name(), identity)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, errors.New("account is not activated")
+	}
+
+	if user.TOTPEnabled || requiresTwoFactor(user) {
+		return as.startTwoFactorChallenge(user, pending.CartToken, client)
+	}
+	return as.completeLogin(user, pending.CartToken, client)
+}
+
+// oidcUser returns the user linked to a provider identity. Unlinked identities are linked to the user
+// with the same email if the provider verified it, or to a new activated user.
+func (as *AuthService) oidcUser(provider string, identity *OIDCIdentity) (*models.User, error) {
+	var link models.UserIdentity
+	err := database.DB.Where("provider = ? AND subject = ?", provider, identity.Subject).First(&link).Error
+	if err == nil {
+		var user models.User
+		if err := database.DB.Preload("Roles").First(&user, link.UserID).Error; err != nil {
+			return nil, errors.New("linked user not found")
+		}
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("database error")
+	}
+
+	// An unverified email could belong to someone else's account
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, errors.New("the provider did not confirm your email address")
+	}
+
+	var user models.User
+	created := false
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Preload("Roles").Where("lower(email) = lower(?)", identity.Email).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			firstName, lastName := oidcNames(identity)
+			user = models.User{
+				Email:     identity.Email,
+				FirstName: firstName,
+				LastName:  lastName,
+				IsActive:  true, // The provider verified the email, no OTP needed
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return errors.New("failed to create user")
+			}
+			if err := assignDefaultRole(tx, &user); err != nil {
+				return err
+			}
+			if err := tx.Preload("Roles").First(&user, user.ID).Error; err != nil {
+				return errors.New("failed to load user")
+			}
+			created = true
+		} else if err != nil {
+			return errors.New("database error")
+		}
+
+		link = models.UserIdentity{
+			UserID:   user.ID,
+			Provider: provider,
+			Subject:  identity.Subject,
+			Email:    identity.Email,
+		}
+		if err := tx.Create(&link).Error; err != nil {
+			return errors.New("failed to link account")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if created {
+		// A registration pending OTP verification for the same email is superseded
+		ctx := context.Background()
+		database.DeleteOTP(ctx, user.Email)
+		database.DeletePendingUser(ctx, user.Email)
+
+		if err := as.emailService.SendWelcomeEmail(user.Email, user.FirstName); err != nil {
+			log.Printf("Failed to send welcome email: %v", err)
+		}
+	}
+	log.Printf("Linked %s account %s to user %d", provider, identity.Subject, user.ID)
+	return &user, nil
+}
+
+// oidcNames picks first and last name from the identity's claims, falling back to the email's local part
+func oidcNames(identity *OIDCIdentity) (string, string) {
+	if identity.GivenName != "" || identity.FamilyName != "" {
+		return identity.GivenName, identity.FamilyName
+	}
+	if identity.Name != "" {
+		firstName, lastName, _ := strings.Cut(identity.Name, " ")
+		return firstName, strings.TrimSpace(lastName)
+	}
+	localPart, _, _ := strings.Cut(identity.Email, "@")
+	return localPart, ""
+}
@@ -0,0 +1,399 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go-shop/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// oidcHTTPClient is used for every request to OIDC providers
+var oidcHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// oidcKeysMinRefresh limits how often a token signed with an unknown key refetches the provider's keys
+const oidcKeysMinRefresh = time.Minute
+
+// oidcSigningMethods are the ID token algorithms accepted; symmetric and "none" tokens are rejected
+var oidcSigningMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// oidcMetadata is the part of the provider's discovery document we use
+type oidcMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCIdentity is the account a user signed in with at a provider
+type OIDCIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+	Name          string
+}
+
+// OIDCProvider signs users in at an OpenID Connect provider with the authorization code flow and PKCE.
+// The discovery document and signing keys are fetched on first use.
+type OIDCProvider struct {
+	config      config.OIDCProviderConfig
+	redirectURL string
+
+	mu            sync.Mutex
+	metadata      *oidcMetadata
+	keys          map[string]interface{}
+	keysFetchedAt time.Time
+}
+
+func NewOIDCProvider(cfg config.OIDCProviderConfig, redirectBaseURL string) *OIDCProvider {
+	return &OIDCProvider{
+		config:      cfg,
+		redirectURL: redirectBaseURL + "/" + url.PathEscape(cfg.Name) + "/callback",
+	}
+}
+
+// Name returns the provider identifier used in routes and stored on linked identities
+func (p *OIDCProvider) Name() string {
+	return p.config.Name
+}
+
+// discover returns the provider's discovery document, fetching it once
+func (p *OIDCProvider) discover(ctx context.Context) (*oidcMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	var metadata oidcMetadata
+	discoveryURL := strings.TrimSuffix(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := oidcGetJSON(ctx, discoveryURL, "", &metadata); err != nil {
+		return nil, fmt.Errorf("failed to discover provider %s: %v", p.config.Name, err)
+	}
+	if metadata.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("provider %s reports issuer %q, expected %q", p.config.Name, metadata.Issuer, p.config.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("provider %s has an incomplete discovery document", p.config.Name)
+	}
+
+	p.metadata = &metadata
+	return p.metadata, nil
+}
+
+// AuthorizationURL returns the provider URL the user signs in at
+func (p *OIDCProvider) AuthorizationURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(codeVerifier))
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientID)
+	query.Set("redirect_uri", p.redirectURL)
+	query.Set("scope", strings.Join(p.config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// oidcTokens is the token endpoint response
+type oidcTokens struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+}
+
+// Exchange redeems an authorization code for tokens
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier string) (*oidcTokens, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.redirectURL)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+
+	var tokens oidcTokens
+	if err := oidcDo(req, &tokens); err != nil {
+		return nil, fmt.Errorf("token exchange with %s failed: %v", p.config.Name, err)
+	}
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("provider %s returned no ID token", p.config.Name)
+	}
+	return &tokens, nil
+}
+
+// oidcIDTokenClaims are the ID token claims we check and use
+type oidcIDTokenClaims struct {
+	Nonce           string      `json:"nonce"`
+	AuthorizedParty string      `json:"azp"`
+	Email           string      `json:"email"`
+	EmailVerified   oidcBoolean `json:"email_verified"`
+	GivenName       string      `json:"given_name"`
+	FamilyName      string      `json:"family_name"`
+	Name            string      `json:"name"`
+	jwt.RegisteredClaims
+}
+
+// VerifyIDToken checks the signature, issuer, audience, expiry and nonce of an ID token
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*OIDCIdentity, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &oidcIDTokenClaims{}
+	_, err = jwt.ParseWithClaims(rawIDToken, claims,
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			return p.verificationKey(ctx, metadata, kid)
+		},
+		jwt.WithValidMethods(oidcSigningMethods),
+		jwt.WithIssuer(p.config.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %v", err)
+	}
+
+	if claims.ExpiresAt == nil {
+		return nil, errors.New("invalid ID token: no expiry")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("invalid ID token: no subject")
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.New("invalid ID token: nonce mismatch")
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.config.ClientID {
+		return nil, errors.New("invalid ID token: issued for another client")
+	}
+
+	return &OIDCIdentity{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		GivenName:     claims.GivenName,
+		FamilyName:    claims.FamilyName,
+		Name:          claims.Name,
+	}, nil
+}
+
+// UserInfo fills in the email and names of an identity from the userinfo endpoint,
+// for providers that leave them out of the ID token
+func (p *OIDCProvider) UserInfo(ctx context.Context, accessToken string, identity *OIDCIdentity) error {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return err
+	}
+	if metadata.UserinfoEndpoint == "" || accessToken == "" {
+		return nil
+	}
+
+	var info struct {
+		Subject       string      `json:"sub"`
+		Email         string      `json:"email"`
+		EmailVerified oidcBoolean `json:"email_verified"`
+		GivenName     string      `json:"given_name"`
+		FamilyName    string      `json:"family_name"`
+		Name          string      `json:"name"`
+	}
+	if err := oidcGetJSON(ctx, metadata.UserinfoEndpoint, accessToken, &info); err != nil {
+		return fmt.Errorf("userinfo request to %s failed: %v", p.config.Name, err)
+	}
+	// Userinfo of another subject must not be mixed into the identity
+	if info.Subject != identity.Subject {
+		return fmt.Errorf("provider %s returned userinfo of another subject", p.config.Name)
+	}
+
+	identity.Email, identity.EmailVerified = info.Email, bool(info.EmailVerified)
+	if identity.GivenName == "" && identity.FamilyName == "" && identity.Name == "" {
+		identity.GivenName, identity.FamilyName, identity.Name = info.GivenName, info.FamilyName, info.Name
+	}
+	return nil
+}
+
+// verificationKey returns the provider key with the given ID, refetching the key set when the
+// key is unknown (the provider rotated its keys)
+func (p *OIDCProvider) verificationKey(ctx context.Context, metadata *oidcMetadata, kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetchedAt) < oidcKeysMinRefresh {
+		return nil, errors.New("unknown signing key")
+	}
+
+	var set struct {
+		Keys []oidcJWK `json:"keys"`
+	}
+	if err := oidcGetJSON(ctx, metadata.JWKSURI, "", &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch signing keys: %v", err)
+	}
+
+	keys := make(map[string]interface{})
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	p.keys, p.keysFetchedAt = keys, time.Now()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, errors.New("unknown signing key")
+}
+
+// lookupKey finds a key by ID; tokens without a kid are accepted when the provider has a single key
+func (p *OIDCProvider) lookupKey(kid string) (interface{}, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+// oidcJWK is a public key of a provider's JSON Web Key Set
+type oidcJWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k oidcJWK) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid RSA key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.New("unsupported curve")
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		if errX != nil || errY != nil {
+			return nil, errors.New("invalid EC key")
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("invalid EC key")
+		}
+		return key, nil
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if k.Crv != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid OKP key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, errors.New("unsupported key type")
+}
+
+// oidcBoolean decodes booleans that some providers send as strings ("true")
+type oidcBoolean bool
+
+func (b *oidcBoolean) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true":
+		*b = true
+	default:
+		*b = false
+	}
+	return nil
+}
+
+// oidcGetJSON fetches a JSON document, with a bearer token if given
+func oidcGetJSON(ctx context.Context, endpoint, bearerToken string, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+bearerToken)
+	}
+	return oidcDo(req, target)
+}
+
+func oidcDo(req *http.Request, target interface{}) error {
+	resp, err := oidcHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, target)
+}
@@ -0,0 +1,517 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-shop/config"
+	"go-shop/database"
+	"go-shop/models"
+	"go-shop/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testOIDCClientID     = "shop-client"
+	testOIDCClientSecret = "shop-secret"
+)
+
+// fakeOIDCServer is a local OpenID Connect provider serving discovery, JWKS, token and userinfo endpoints
+type fakeOIDCServer struct {
+	*httptest.Server
+	t *testing.T
+
+	mu          sync.Mutex
+	keys        map[string]*rsa.PrivateKey // Published in the JWKS
+	signingKey  string
+	jwksFetches int
+	grants      map[string]fakeOIDCGrant // By authorization code, used once
+	userinfo    map[string]jwt.MapClaims // By access token
+}
+
+// fakeOIDCGrant is an authorization the user gave at the provider
+type fakeOIDCGrant struct {
+	challenge string
+	claims    jwt.MapClaims
+	userinfo  jwt.MapClaims
+}
+
+func newFakeOIDCServer(t *testing.T) *fakeOIDCServer {
+	t.Helper()
+	s := &fakeOIDCServer{
+		t:        t,
+		keys:     make(map[string]*rsa.PrivateKey),
+		grants:   make(map[string]fakeOIDCGrant),
+		userinfo: make(map[string]jwt.MapClaims),
+	}
+	s.rotateKey("key-1")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.handleDiscovery)
+	mux.HandleFunc("/jwks", s.handleJWKS)
+	mux.HandleFunc("/token", s.handleToken)
+	mux.HandleFunc("/userinfo", s.handleUserinfo)
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeOIDCServer) providerConfig(name string) config.OIDCProviderConfig {
+	return config.OIDCProviderConfig{
+		Name:         name,
+		Issuer:       s.URL,
+		ClientID:     testOIDCClientID,
+		ClientSecret: testOIDCClientSecret,
+		Scopes:       []string{"openid", "email", "profile"},
+	}
+}
+
+// rotateKey publishes a new key and signs further tokens with it; earlier keys stay published
+func (s *fakeOIDCServer) rotateKey(kid string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[kid] = key
+	s.signingKey = kid
+}
+
+func (s *fakeOIDCServer) fetches() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jwksFetches
+}
+
+// claims returns valid ID token claims for a subject, issued to the shop; an empty nonce is left out
+func (s *fakeOIDCServer) claims(subject, nonce string) jwt.MapClaims {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            s.URL,
+		"aud":            testOIDCClientID,
+		"sub":            subject,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"email":          subject + "@example.com",
+		"email_verified": true,
+		"given_name":     "Test",
+		"family_name":    "User",
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	return claims
+}
+
+// sign signs claims with the current key, naming it in the kid header
+func (s *fakeOIDCServer) sign(claims jwt.MapClaims) string {
+	s.t.Helper()
+	signed, err := s.signToken(claims)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return signed
+}
+
+func (s *fakeOIDCServer) signToken(claims jwt.MapClaims) (string, error) {
+	s.mu.Lock()
+	kid, key := s.signingKey, s.keys[s.signingKey]
+	s.mu.Unlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	return token.SignedString(key)
+}
+
+// authorize plays the user signing in at the authorization URL and returns the code the provider
+// redirects back with. The nonce of the URL is added to the ID token claims unless they set one.
+func (s *fakeOIDCServer) authorize(authorizationURL string, claims, userinfo jwt.MapClaims) string {
+	s.t.Helper()
+	parsed, err := url.Parse(authorizationURL)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	query := parsed.Query()
+	if query.Get("client_id") != testOIDCClientID || query.Get("response_type") != "code" {
+		s.t.Fatalf("unexpected authorization request %s", authorizationURL)
+	}
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		s.t.Fatalf("authorization request without PKCE: %s", authorizationURL)
+	}
+	if _, ok := claims["nonce"]; !ok {
+		claims["nonce"] = query.Get("nonce")
+	}
+
+	code := "code-" + testToken(s.t)
+	s.mu.Lock()
+	s.grants[code] = fakeOIDCGrant{challenge: query.Get("code_challenge"), claims: claims, userinfo: userinfo}
+	s.mu.Unlock()
+	return code
+}
+
+func (s *fakeOIDCServer) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 s.URL,
+		"authorization_endpoint": s.URL + "/authorize",
+		"token_endpoint":         s.URL + "/token",
+		"userinfo_endpoint":      s.URL + "/userinfo",
+		"jwks_uri":               s.URL + "/jwks",
+	})
+}
+
+func (s *fakeOIDCServer) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jwksFetches++
+
+	keys := []map[string]string{}
+	for kid, key := range s.keys {
+		keys = append(keys, map[string]string{
+			"kty": "RSA",
+			"kid": kid,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+}
+
+func (s *fakeOIDCServer) handleToken(w http.ResponseWriter, r *http.Request) {
+	clientID, secret, ok := r.BasicAuth()
+	if r.Method != http.MethodPost || !ok || clientID != testOIDCClientID || secret != testOIDCClientSecret {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	grant, ok := s.grants[r.PostForm.Get("code")]
+	delete(s.grants, r.PostForm.Get("code"))
+	s.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(challenge[:]) != grant.challenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	idToken, err := s.signToken(grant.claims)
+	if err != nil {
+		http.Error(w, `{"error":"server_error"}`, http.StatusInternalServerError)
+		return
+	}
+	accessToken, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		http.Error(w, `{"error":"server_error"}`, http.StatusInternalServerError)
+		return
+	}
+	s.mu.Lock()
+	s.userinfo[accessToken] = grant.userinfo
+	s.mu.Unlock()
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+func (s *fakeOIDCServer) handleUserinfo(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	info, ok := s.userinfo[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+	s.mu.Unlock()
+	if !ok || info == nil {
+		http.Error(w, `{"error":"invalid_token"}`, http.StatusUnauthorized)
+		return
+	}
+	json.NewEncoder(w).Encode(info)
+}
+
+func TestOIDCProviderLogin(t *testing.T) {
+	server := newFakeOIDCServer(t)
+	provider := NewOIDCProvider(server.providerConfig("fake"), "http://localhost/api/v1/auth/oidc")
+	ctx := context.Background()
+
+	authorizationURL, err := provider.AuthorizationURL(ctx, "state-1", "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(authorizationURL, server.URL+"/authorize?") {
+		t.Fatalf("unexpected authorization URL %s", authorizationURL)
+	}
+
+	code := server.authorize(authorizationURL, server.claims("alice", ""), nil)
+	if _, err := provider.Exchange(ctx, code, "wrong-verifier"); err == nil {
+		t.Fatal("expected the exchange to fail with a wrong PKCE verifier")
+	}
+
+	code = server.authorize(authorizationURL, server.claims("alice", ""), nil)
+	tokens, err := provider.Exchange(ctx, code, "verifier-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	identity, err := provider.VerifyIDToken(ctx, tokens.IDToken, "nonce-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if identity.Subject != "alice" || identity.Email != "alice@example.com" || !identity.EmailVerified {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+
+	// Providers that leave the email out of the ID token serve it from userinfo
+	claims := server.claims("bob", "")
+	delete(claims, "email")
+	delete(claims, "email_verified")
+	code = server.authorize(authorizationURL, claims, jwt.MapClaims{"sub": "bob", "email": "bob@example.com", "email_verified": "true"})
+	tokens, err = provider.Exchange(ctx, code, "verifier-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	identity, err = provider.VerifyIDToken(ctx, tokens.IDToken, "nonce-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := provider.UserInfo(ctx, tokens.AccessToken, identity); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if identity.Email != "bob@example.com" || !identity.EmailVerified {
+		t.Fatalf("userinfo not applied: %+v", identity)
+	}
+}
+
+func TestOIDCVerifyIDToken(t *testing.T) {
+	server := newFakeOIDCServer(t)
+	provider := NewOIDCProvider(server.providerConfig("fake"), "http://localhost/api/v1/auth/oidc")
+
+	tests := []struct {
+		name    string
+		modify  func(jwt.MapClaims)
+		wantErr string
+	}{
+		{"valid", func(jwt.MapClaims) {}, ""},
+		{"nonce mismatch", func(c jwt.MapClaims) { c["nonce"] = "other-nonce" }, "nonce mismatch"},
+		{"missing nonce", func(c jwt.MapClaims) { delete(c, "nonce") }, "nonce mismatch"},
+		{"wrong audience", func(c jwt.MapClaims) { c["aud"] = "other-client" }, "audience"},
+		{"several audiences for another client", func(c jwt.MapClaims) {
+			c["aud"] = []string{testOIDCClientID, "other-client"}
+			c["azp"] = "other-client"
+		}, "issued for another client"},
+		{"several audiences without azp", func(c jwt.MapClaims) {
+			c["aud"] = []string{testOIDCClientID, "other-client"}
+		}, "issued for another client"},
+		{"several audiences for the shop", func(c jwt.MapClaims) {
+			c["aud"] = []string{testOIDCClientID, "other-client"}
+			c["azp"] = testOIDCClientID
+		}, ""},
+		{"wrong issuer", func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, "issuer"},
+		{"expired", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, "expired"},
+		{"no subject", func(c jwt.MapClaims) { delete(c, "sub") }, "no subject"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := server.claims("alice", "nonce-1")
+			tt.modify(claims)
+
+			identity, err := provider.VerifyIDToken(context.Background(), server.sign(claims), "nonce-1")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if identity.Subject != "alice" {
+					t.Fatalf("unexpected identity %+v", identity)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("symmetric signature", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, server.claims("alice", "nonce-1"))
+		signed, err := token.SignedString([]byte(testOIDCClientSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := provider.VerifyIDToken(context.Background(), signed, "nonce-1"); err == nil {
+			t.Fatal("expected an HS256 ID token to be rejected")
+		}
+	})
+}
+
+func TestOIDCKeyRotation(t *testing.T) {
+	server := newFakeOIDCServer(t)
+	provider := NewOIDCProvider(server.providerConfig("fake"), "http://localhost/api/v1/auth/oidc")
+	ctx := context.Background()
+
+	if _, err := provider.VerifyIDToken(ctx, server.sign(server.claims("alice", "n")), "n"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fetches := server.fetches(); fetches != 1 {
+		t.Fatalf("expected the keys to be fetched once, got %d", fetches)
+	}
+
+	// Tokens signed with an unknown key refetch the keys, at most once per oidcKeysMinRefresh
+	server.rotateKey("key-2")
+	rotated := server.sign(server.claims("alice", "n"))
+	if _, err := provider.VerifyIDToken(ctx, rotated, "n"); err == nil {
+		t.Fatal("expected the unknown key to be rejected until the keys may be refetched")
+	}
+	if fetches := server.fetches(); fetches != 1 {
+		t.Fatalf("expected no refetch within the minimum interval, got %d fetches", fetches)
+	}
+
+	provider.mu.Lock()
+	provider.keysFetchedAt = time.Now().Add(-oidcKeysMinRefresh)
+	provider.mu.Unlock()
+
+	if _, err := provider.VerifyIDToken(ctx, rotated, "n"); err != nil {
+		t.Fatalf("expected the rotated key to be found after a refetch, got %v", err)
+	}
+	if fetches := server.fetches(); fetches != 2 {
+		t.Fatalf("expected the keys to be refetched once, got %d fetches", fetches)
+	}
+
+	// Tokens signed with the previous key still verify without another fetch
+	server.mu.Lock()
+	server.signingKey = "key-1"
+	server.mu.Unlock()
+	if _, err := provider.VerifyIDToken(ctx, server.sign(server.claims("alice", "n")), "n"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fetches := server.fetches(); fetches != 2 {
+		t.Fatalf("expected known keys not to be refetched, got %d fetches", fetches)
+	}
+}
+
+func newTestOIDCAuthService(t *testing.T, server *fakeOIDCServer) *AuthService {
+	t.Helper()
+	cfg := testConfig()
+	cfg.OIDC.Providers = []config.OIDCProviderConfig{server.providerConfig("fake"), server.providerConfig("other")}
+	return NewAuthService(cfg, NewEmailService(cfg))
+}
+
+func TestOIDCLoginState(t *testing.T) {
+	server := newFakeOIDCServer(t)
+	as := newTestOIDCAuthService(t, server)
+
+	if _, err := as.StartOIDCLogin("missing", &models.OIDCLoginRequest{}); !errors.Is(err, ErrUnknownOIDCProvider) {
+		t.Fatalf("expected ErrUnknownOIDCProvider, got %v", err)
+	}
+
+	useTestRedis(t)
+
+	t.Run("wrong provider", func(t *testing.T) {
+		login, err := as.StartOIDCLogin("fake", &models.OIDCLoginRequest{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		code := server.authorize(login.AuthorizationURL, server.claims("alice", ""), nil)
+
+		_, err = as.CompleteOIDCLogin("other", &models.OIDCCallbackRequest{Code: code, State: login.State}, models.ClientInfo{})
+		if !errors.Is(err, database.ErrOIDCStateInvalid) {
+			t.Fatalf("expected ErrOIDCStateInvalid, got %v", err)
+		}
+		// The state is used up by the failed attempt
+		_, err = as.CompleteOIDCLogin("fake", &models.OIDCCallbackRequest{Code: code, State: login.State}, models.ClientInfo{})
+		if !errors.Is(err, database.ErrOIDCStateInvalid) {
+			t.Fatalf("expected ErrOIDCStateInvalid, got %v", err)
+		}
+	})
+
+	t.Run("state reuse", func(t *testing.T) {
+		login, err := as.StartOIDCLogin("fake", &models.OIDCLoginRequest{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		_, err = as.CompleteOIDCLogin("fake", &models.OIDCCallbackRequest{State: login.State, Error: "access_denied"}, models.ClientInfo{})
+		if err == nil || errors.Is(err, database.ErrOIDCStateInvalid) {
+			t.Fatalf("expected the provider error, got %v", err)
+		}
+		_, err = as.CompleteOIDCLogin("fake", &models.OIDCCallbackRequest{State: login.State, Code: "code"}, models.ClientInfo{})
+		if !errors.Is(err, database.ErrOIDCStateInvalid) {
+			t.Fatalf("expected ErrOIDCStateInvalid, got %v", err)
+		}
+	})
+}
+
+func TestOIDCLoginLinksVerifiedEmail(t *testing.T) {
+	requireDatabase(t)
+	useTestRedis(t)
+	if err := utils.LoadJWTKeys(testConfig()); err != nil {
+		t.Fatal(err)
+	}
+
+	server := newFakeOIDCServer(t)
+	as := newTestOIDCAuthService(t, server)
+	user := createTestUser(t)
+	subject := "sub-" + testToken(t)
+
+	login, err := as.StartOIDCLogin("fake", &models.OIDCLoginRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	claims := server.claims(subject, "")
+	claims["email"] = strings.ToUpper(user.Email)
+	code := server.authorize(login.AuthorizationURL, claims, nil)
+
+	response, err := as.CompleteOIDCLogin("fake", &models.OIDCCallbackRequest{Code: code, State: login.State}, models.ClientInfo{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if response.Token == "" || response.RefreshToken == "" || response.User == nil || response.User.ID != user.ID {
+		t.Fatalf("expected a login of user %d, got %+v", user.ID, response)
+	}
+
+	var link models.UserIdentity
+	if err := database.DB.Where("provider = ? AND subject = ?", "fake", subject).First(&link).Error; err != nil || link.UserID != user.ID {
+		t.Fatalf("expected the identity to be linked to user %d, got %+v (%v)", user.ID, link, err)
+	}
+}
+
+func TestOIDCUnverifiedEmailDoesNotLink(t *testing.T) {
+	requireDatabase(t)
+
+	server := newFakeOIDCServer(t)
+	as := newTestOIDCAuthService(t, server)
+	user := createTestUser(t)
+	subject := "sub-" + testToken(t)
+
+	identity := &OIDCIdentity{Subject: subject, Email: user.Email, EmailVerified: false}
+	if _, err := as.oidcUser("fake", identity); err == nil {
+		t.Fatal("expected an unverified email to be refused")
+	}
+
+	var links int64
+	database.DB.Model(&models.UserIdentity{}).Where("provider = ? AND subject = ?", "fake", subject).Count(&links)
+	if links != 0 {
+		t.Fatalf("expected no identity to be linked, got %d", links)
+	}
+	var users int64
+	database.DB.Model(&models.User{}).Where("lower(email) = lower(?)", user.Email).Count(&users)
+	if users != 1 {
+		t.Fatalf("expected no second account for the email, got %d users", users)
+	}
+}
@@ -3,18 +3,30 @@ package services
 import (
 	"errors"
 	"fmt"
+	"log"
+	"sort"
 	"time"
 
+	"go-shop/config"
 	"go-shop/database"
 	"go-shop/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-type OrderService struct{}
+type OrderService struct {
+	config         *config.Config
+	paymentService *PaymentService
+	carriers       Carriers
+}
 
-func NewOrderService() *OrderService {
-	return &OrderService{}
+func NewOrderService(cfg *config.Config, paymentService *PaymentService, carriers Carriers) *OrderService {
+	return &OrderService{
+		config:         cfg,
+		paymentService: paymentService,
+		carriers:       carriers,
+	}
 }
 
 func (os *OrderService) CreateOrder(userID uint, req *models.OrderCreateRequest) (*models.OrderResponse, error) {
@@ -29,46 +41,166 @@ func (os *OrderService) CreateOrder(userID uint, req *models.OrderCreateRequest)
 	// Generate order number
 	orderNumber := fmt.Sprintf("ORD-%d-%d", time.Now().Unix(), userID)
 
-	// Calculate total amount and validate products
-	var totalAmount float64
-	var orderItems []models.OrderItem
-
+	// Lock requested products and variants so concurrent orders cannot reserve the same units
+	requested := make(map[stockKey]int)
+	productQuantities := make(map[uint]int)
 	for _, item := range req.Items {
-		// Get product details
-		var product models.Product
-		if err := tx.First(&product, item.ProductID).Error; err != nil {
-			tx.Rollback()
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return nil, errors.New("product not found")
+		requested[newStockKey(item.ProductID, item.VariantID)] += item.Quantity
+		productQuantities[item.ProductID] += item.Quantity
+	}
+
+	products, err := lockProducts(tx, productQuantities)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	variants, err := lockVariants(tx, requested)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	withVariants, err := productsWithVariants(tx, sortedProductIDs(productQuantities))
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Check available (not reserved) stock
+	keys := sortedStockKeys(requested)
+	for _, key := range keys {
+		product := products[key.ProductID]
+		if key.VariantID == 0 {
+			if withVariants[key.ProductID] {
+				tx.Rollback()
+				return nil, fmt.Errorf("choose a variant of product %s", product.Title)
 			}
-			return nil, errors.New("database error")
+			if product.AvailableStock() < requested[key] {
+				tx.Rollback()
+				return nil, fmt.Errorf("insufficient stock for product %s", product.Title)
+			}
+			continue
 		}
 
-		// Check stock
-		if product.Stock < item.Quantity {
+		if variants[key.VariantID].AvailableStock() < requested[key] {
 			tx.Rollback()
-			return nil, fmt.Errorf("insufficient stock for product %s", product.Title)
+			return nil, fmt.Errorf("insufficient stock for product %s (%s)", product.Title, variants[key.VariantID].SKU)
 		}
+	}
+
+	// The shipping address is copied onto the order; taxes depend on where it is
+	address, err := shippingAddress(tx, userID, req.AddressID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	taxRules, err := loadTaxRules(tx, address.Country, address.Region)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	shippingMethod, err := orderShippingMethod(tx, req.ShippingMethodID, address.Country)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Prices are fixed in the order currency: list prices, or base prices at the current exchange rate
+	prices, err := loadPriceList(tx, os.config, req.Currency, sortedProductIDs(productQuantities))
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Calculate subtotal
+	var subtotal models.Money
+	var orderItems []models.OrderItem
+	var lines []*discountLine
+	var categoryIDs []*uint
+	var grams int64
+
+	for _, item := range req.Items {
+		product := products[item.ProductID]
+		var variant *models.ProductVariant
+		if item.VariantID != nil {
+			variant = variants[*item.VariantID]
+		}
+		price := prices.Price(product, variant)
 
 		// Calculate item total
-		itemTotal := product.Price * float64(item.Quantity)
-		totalAmount += itemTotal
+		itemTotal := price.Mul(item.Quantity)
+		subtotal += itemTotal
 
 		// Create order item
 		orderItem := models.OrderItem{
 			ProductID:     item.ProductID,
+			VariantID:     item.VariantID,
 			Quantity:      item.Quantity,
-			PriceAtMoment: product.Price,
+			PriceAtMoment: price,
 		}
 		orderItems = append(orderItems, orderItem)
+		categoryIDs = append(categoryIDs, product.CategoryID)
+		grams += productWeight(product, os.config.Shipping.WeightAttribute) * int64(item.Quantity)
+		lines = append(lines, &discountLine{
+			ProductID:  item.ProductID,
+			CategoryID: product.CategoryID,
+			Quantity:   item.Quantity,
+			Amount:     itemTotal,
+		})
+	}
+
+	// Apply automatic promotions and the coupon
+	discounts, err := applyPromotions(tx, userID, req.CouponCode, lines, prices)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	var discount models.Money
+	for _, applied := range discounts {
+		discount += applied.Amount
+	}
+	for i := range orderItems {
+		orderItems[i].Discount = lines[i].Discount
+	}
+
+	// Tax the lines after their discounts
+	taxes := applyTaxes(orderItems, categoryIDs, taxRules, prices.currency)
+	var tax, addedTax models.Money
+	for i := range orderItems {
+		tax += orderItems[i].Tax
+		if !orderItems[i].TaxInclusive {
+			addedTax += orderItems[i].Tax
+		}
+	}
+
+	// Shipping is priced on the items after discounts
+	var shippingCostAmount models.Money
+	if shippingMethod != nil {
+		shippingCostAmount = shippingCost(shippingMethod, grams, subtotal-discount, prices)
 	}
 
 	// Create order
+	expiresAt := time.Now().Add(time.Duration(os.config.Order.PaymentWindowMinutes) * time.Minute)
 	order := models.Order{
 		UserID:      userID,
 		OrderNumber: orderNumber,
 		Status:      models.OrderStatusPending,
-		TotalAmount: totalAmount,
+		Currency:    prices.currency,
+		Subtotal:    subtotal,
+		Discount:    discount,
+		Tax:         tax,
+		TotalAmount: subtotal - discount + addedTax + shippingCostAmount,
+		ExpiresAt:   &expiresAt,
+
+		ShippingAddress: address.PostalAddress,
+		ShippingCost:    shippingCostAmount,
+	}
+	if shippingMethod != nil {
+		order.ShippingMethodID = &shippingMethod.ID
+		order.ShippingMethod = shippingMethod.Name
+		order.ShippingCarrier = shippingMethod.Carrier
 	}
 
 	if err := tx.Create(&order).Error; err != nil {
@@ -76,6 +208,11 @@ func (os *OrderService) CreateOrder(userID uint, req *models.OrderCreateRequest)
 		return nil, errors.New("failed to create order")
 	}
 
+	if err := recordOrderStatus(tx, order.ID, "", order.Status, OrderActor{UserID: &userID, Role: models.ROLE_USER}, "order placed"); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
 	// Create order items
 	for i := range orderItems {
 		orderItems[i].OrderID = order.ID
@@ -86,6 +223,66 @@ func (os *OrderService) CreateOrder(userID uint, req *models.OrderCreateRequest)
 		return nil, errors.New("failed to create order items")
 	}
 
+	if len(discounts) > 0 {
+		for i := range discounts {
+			discounts[i].OrderID = order.ID
+		}
+		if err := tx.Create(&discounts).Error; err != nil {
+			tx.Rollback()
+			return nil, errors.New("failed to save order discounts")
+		}
+	}
+
+	if len(taxes) > 0 {
+		for i := range taxes {
+			taxes[i].OrderID = order.ID
+		}
+		if err := tx.Create(&taxes).Error; err != nil {
+			tx.Rollback()
+			return nil, errors.New("failed to save order taxes")
+		}
+	}
+
+	// Nothing to charge when promotions cover the whole order
+	if order.TotalAmount <= 0 {
+		order.ExpiresAt = nil
+		if err := transitionOrder(tx, &order, models.OrderStatusPaid, SystemActor(), "fully discounted"); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	// Split the order into one shipment per seller
+	sellers := make(map[uint]*uint, len(products))
+	for productID, product := range products {
+		sellers[productID] = product.SellerID
+	}
+	if _, err := createShipments(tx, &order, orderItems, sellers, models.ShipmentStatusPending); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Reserve stock until the order is confirmed, cancelled or expired
+	for _, key := range keys {
+		quantity := requested[key]
+		if err := stockRow(tx, key.ProductID, key.variantID()).Update("reserved", gorm.Expr("reserved + ?", quantity)).Error; err != nil {
+			tx.Rollback()
+			return nil, errors.New("failed to reserve product stock")
+		}
+
+		reservation := models.StockReservation{
+			OrderID:   order.ID,
+			ProductID: key.ProductID,
+			VariantID: key.variantID(),
+			Quantity:  quantity,
+			Status:    models.ReservationStatusActive,
+		}
+		if err := tx.Create(&reservation).Error; err != nil {
+			tx.Rollback()
+			return nil, errors.New("failed to reserve product stock")
+		}
+	}
+
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
 		return nil, errors.New("failed to commit transaction")
@@ -93,63 +290,22 @@ func (os *OrderService) CreateOrder(userID uint, req *models.OrderCreateRequest)
 
 	// Load order with items for response
 	var orderWithItems models.Order
-	if err := database.DB.Preload("OrderItems.Product").First(&orderWithItems, order.ID).Error; err != nil {
+	if err := database.DB.Preload("OrderItems").Preload("Shipments").Preload("Discounts").Preload("Taxes").First(&orderWithItems, order.ID).Error; err != nil {
 		return nil, errors.New("failed to load order")
 	}
 
-	// Build response
-	var orderItemResponses []models.OrderItemResponse
-	for _, item := range orderWithItems.OrderItems {
-		orderItemResponses = append(orderItemResponses, models.OrderItemResponse{
-			ID:            item.ID,
-			OrderID:       item.OrderID,
-			ProductID:     item.ProductID,
-			Quantity:      item.Quantity,
-			PriceAtMoment: item.PriceAtMoment,
-		})
-	}
-
-	return &models.OrderResponse{
-		ID:          order.ID,
-		UserID:      order.UserID,
-		OrderNumber: order.OrderNumber,
-		Status:      order.Status,
-		TotalAmount: order.TotalAmount,
-		CreatedAt:   order.CreatedAt,
-		UpdatedAt:   order.UpdatedAt,
-		OrderItems:  orderItemResponses,
-	}, nil
+	return toOrderResponse(&orderWithItems), nil
 }
 
 func (os *OrderService) GetUserOrders(userID uint) ([]models.OrderResponse, error) {
 	var orders []models.Order
-	if err := database.DB.Preload("OrderItems").Where("user_id = ?", userID).Order("created_at DESC").Find(&orders).Error; err != nil {
+	if err := database.DB.Preload("OrderItems").Preload("Shipments").Preload("Discounts").Preload("Taxes").Where("user_id = ?", userID).Order("created_at DESC").Find(&orders).Error; err != nil {
 		return nil, errors.New("failed to get orders")
 	}
 
 	var orderResponses []models.OrderResponse
-	for _, order := range orders {
-		var orderItemResponses []models.OrderItemResponse
-		for _, item := range order.OrderItems {
-			orderItemResponses = append(orderItemResponses, models.OrderItemResponse{
-				ID:            item.ID,
-				OrderID:       item.OrderID,
-				ProductID:     item.ProductID,
-				Quantity:      item.Quantity,
-				PriceAtMoment: item.PriceAtMoment,
-			})
-		}
-
-		orderResponses = append(orderResponses, models.OrderResponse{
-			ID:          order.ID,
-			UserID:      order.UserID,
-			OrderNumber: order.OrderNumber,
-			Status:      order.Status,
-			TotalAmount: order.TotalAmount,
-			CreatedAt:   order.CreatedAt,
-			UpdatedAt:   order.UpdatedAt,
-			OrderItems:  orderItemResponses,
-		})
+	for i := range orders {
+		orderResponses = append(orderResponses, *toOrderResponse(&orders[i]))
 	}
 
 	return orderResponses, nil
@@ -157,109 +313,100 @@ func (os *OrderService) GetUserOrders(userID uint) ([]models.OrderResponse, erro
 
 func (os *OrderService) GetOrderByID(orderID, userID uint) (*models.OrderResponse, error) {
 	var order models.Order
-	if err := database.DB.Preload("OrderItems.Product").Where("id = ? AND user_id = ?", orderID, userID).First(&order).Error; err != nil {
+	if err := database.DB.Preload("OrderItems.Product").Preload("Shipments").Preload("Discounts").Preload("Taxes").Where("id = ? AND user_id = ?", orderID, userID).First(&order).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("order not found")
 		}
 		return nil, errors.New("database error")
 	}
 
-	var orderItemResponses []models.OrderItemResponse
-	for _, item := range order.OrderItems {
-		orderItemResponses = append(orderItemResponses, models.OrderItemResponse{
-			ID:            item.ID,
-			OrderID:       item.OrderID,
-			ProductID:     item.ProductID,
-			Quantity:      item.Quantity,
-			PriceAtMoment: item.PriceAtMoment,
-		})
-	}
-
-	return &models.OrderResponse{
-		ID:          order.ID,
-		UserID:      order.UserID,
-		OrderNumber: order.OrderNumber,
-		Status:      order.Status,
-		TotalAmount: order.TotalAmount,
-		CreatedAt:   order.CreatedAt,
-		UpdatedAt:   order.UpdatedAt,
-		OrderItems:  orderItemResponses,
-	}, nil
+	return toOrderResponse(&order), nil
 }
 
-func (os *OrderService) UpdateOrderStatus(orderID, userID uint, req *models.OrderUpdateRequest) (*models.OrderResponse, error) {
+// UpdateOrderStatus changes the status of one of the user's orders through the order state machine
+func (os *OrderService) UpdateOrderStatus(orderID uint, actor OrderActor, req *models.OrderUpdateRequest) (*models.OrderResponse, error) {
+	scope := actor.orderScope()
+
+	switch req.Status {
+	case models.OrderStatusCancelled:
+		return os.cancelOrder(orderID, actor, req.Reason, scope)
+	case models.OrderStatusConfirmed:
+		return os.advanceShipments(orderID, models.ShipmentStatusConfirmed, actor, req.Reason, nil, scope)
+	case models.OrderStatusShipped:
+		return os.advanceShipments(orderID, models.ShipmentStatusShipped, actor, req.Reason, nil, scope)
+	case models.OrderStatusDelivered:
+		return os.advanceShipments(orderID, models.ShipmentStatusDelivered, actor, req.Reason, nil, scope)
+	}
+
+	// Remaining statuses are only reached through payments and returns
 	var order models.Order
-	if err := database.DB.Where("id = ? AND user_id = ?", orderID, userID).First(&order).Error; err != nil {
+	if err := database.DB.Scopes(scope).Where("id = ?", orderID).First(&order).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("order not found")
 		}
 		return nil, errors.New("database error")
 	}
-
-	// Users can only cancel their own orders
-	if req.Status != models.OrderStatusCancelled {
-		return nil, errors.New("users can only cancel orders")
+	if err := CanTransitionOrder(order.Status, req.Status, actor.Role); err != nil {
+		return nil, err
 	}
+	return nil, fmt.Errorf("order status %s is set automatically", req.Status)
+}
 
-	// Check if order can be cancelled
-	if order.Status == models.OrderStatusDelivered || order.Status == models.OrderStatusCancelled {
-		return nil, errors.New("order cannot be cancelled")
+// Admin functions
+func (os *OrderService) GetAllOrders(limit, offset int) ([]models.OrderResponse, error) {
+	var orders []models.Order
+	if err := database.DB.Preload("OrderItems").Preload("Shipments").Preload("Discounts").Preload("Taxes").Preload("User").Limit(limit).Offset(offset).Order("created_at DESC").Find(&orders).Error; err != nil {
+		return nil, errors.New("failed to get orders")
 	}
 
-	// Update status to cancelled
-	order.Status = req.Status
-
-	if err := database.DB.Save(&order).Error; err != nil {
-		return nil, errors.New("failed to update order")
+	var orderResponses []models.OrderResponse
+	for i := range orders {
+		orderResponses = append(orderResponses, *toOrderResponse(&orders[i]))
 	}
 
-	return &models.OrderResponse{
-		ID:          order.ID,
-		UserID:      order.UserID,
-		OrderNumber: order.OrderNumber,
-		Status:      order.Status,
-		TotalAmount: order.TotalAmount,
-		CreatedAt:   order.CreatedAt,
-		UpdatedAt:   order.UpdatedAt,
-	}, nil
+	return orderResponses, nil
 }
 
-// Admin functions
-func (os *OrderService) GetAllOrders(limit, offset int) ([]models.OrderResponse, error) {
+// GetSellerOrders returns orders containing the seller's products, with only the seller's order items
+func (os *OrderService) GetSellerOrders(sellerID uint, limit, offset int) ([]models.OrderResponse, error) {
 	var orders []models.Order
-	if err := database.DB.Preload("OrderItems").Preload("User").Limit(limit).Offset(offset).Order("created_at DESC").Find(&orders).Error; err != nil {
+	if err := database.DB.Preload("OrderItems", "product_id IN (?)", sellerProductIDs(sellerID)).Preload("Shipments", "seller_id = ?", sellerID).
+		Where("id IN (?)", sellerOrderIDs(sellerID)).
+		Limit(limit).Offset(offset).Order("created_at DESC").Find(&orders).Error; err != nil {
 		return nil, errors.New("failed to get orders")
 	}
 
 	var orderResponses []models.OrderResponse
-	for _, order := range orders {
-		var orderItemResponses []models.OrderItemResponse
-		for _, item := range order.OrderItems {
-			orderItemResponses = append(orderItemResponses, models.OrderItemResponse{
-				ID:            item.ID,
-				OrderID:       item.OrderID,
-				ProductID:     item.ProductID,
-				Quantity:      item.Quantity,
-				PriceAtMoment: item.PriceAtMoment,
-			})
-		}
-
-		orderResponses = append(orderResponses, models.OrderResponse{
-			ID:          order.ID,
-			UserID:      order.UserID,
-			OrderNumber: order.OrderNumber,
-			Status:      order.Status,
-			TotalAmount: order.TotalAmount,
-			CreatedAt:   order.CreatedAt,
-			UpdatedAt:   order.UpdatedAt,
-			OrderItems:  orderItemResponses,
-		})
+	for i := range orders {
+		orderResponses = append(orderResponses, *toOrderResponse(&orders[i]))
 	}
 
 	return orderResponses, nil
 }
 
-func (os *OrderService) ConfirmOrder(orderID uint) (*models.OrderResponse, error) {
+// ConfirmOrder confirms every pending shipment of a paid order, turning reservations into stock decrements (Admin only)
+func (os *OrderService) ConfirmOrder(orderID uint, actor OrderActor) (*models.OrderResponse, error) {
+	return os.advanceShipments(orderID, models.ShipmentStatusConfirmed, actor, "", nil)
+}
+
+// ShipOrder marks the confirmed shipments of an order as shipped with a carrier and tracking number
+// (Admin/Seller only); sellers ship only their own shipments
+func (os *OrderService) ShipOrder(orderID uint, actor OrderActor, req *models.ShipmentShipRequest) (*models.OrderResponse, error) {
+	return os.advanceShipments(orderID, models.ShipmentStatusShipped, actor, "", req, actor.orderScope())
+}
+
+// DeliverOrder marks the shipped shipments of an order as delivered (Admin only)
+func (os *OrderService) DeliverOrder(orderID uint, actor OrderActor) (*models.OrderResponse, error) {
+	return os.advanceShipments(orderID, models.ShipmentStatusDelivered, actor, "", nil)
+}
+
+// CancelOrder cancels an order (User or Admin); buyers can only cancel their own orders
+func (os *OrderService) CancelOrder(orderID uint, actor OrderActor) (*models.OrderResponse, error) {
+	return os.cancelOrder(orderID, actor, "", actor.orderScope())
+}
+
+// cancelOrder cancels an order matched by the optional scopes and releases its stock reservations
+func (os *OrderService) cancelOrder(orderID uint, actor OrderActor, reason string, scopes ...func(*gorm.DB) *gorm.DB) (*models.OrderResponse, error) {
 	// Start transaction
 	tx := database.DB.Begin()
 	defer func() {
@@ -268,41 +415,55 @@ func (os *OrderService) ConfirmOrder(orderID uint) (*models.OrderResponse, error
 		}
 	}()
 
-	var order models.Order
-	if err := tx.Preload("OrderItems").First(&order, orderID).Error; err != nil {
+	order, err := lockOrder(tx, orderID, scopes...)
+	if err != nil {
 		tx.Rollback()
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("order not found")
-		}
-		return nil, errors.New("database error")
+		return nil, err
 	}
 
-	// Check if order is paid
-	if order.Status != models.OrderStatusPaid {
+	// Check the transition before releasing stock and refunding
+	if err := CanTransitionOrder(order.Status, models.OrderStatusCancelled, actor.Role); err != nil {
 		tx.Rollback()
-		return nil, errors.New("order must be paid before confirmation")
+		return nil, err
 	}
 
-	// Update stock and order_count for each item
-	for _, item := range order.OrderItems {
-		// Update stock
-		if err := tx.Model(&models.Product{}).Where("id = ?", item.ProductID).Update("stock", gorm.Expr("stock - ?", item.Quantity)).Error; err != nil {
-			tx.Rollback()
-			return nil, errors.New("failed to update product stock")
-		}
+	var shipments []models.Shipment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("order_id = ?", order.ID).Find(&shipments).Error; err != nil {
+		tx.Rollback()
+		return nil, errors.New("failed to get shipments")
+	}
+	if err := checkCancellable(shipments); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
 
-		// Update order_count (increment by 1 for each confirmed order)
-		if err := tx.Model(&models.Product{}).Where("id = ?", item.ProductID).Update("order_count", gorm.Expr("order_count + 1")).Error; err != nil {
-			tx.Rollback()
-			return nil, errors.New("failed to update product order count")
+	if err := releaseReservations(tx, order.ID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Return the money of orders that were already paid
+	var refunds []models.Refund
+	if order.Status != models.OrderStatusPending {
+		if amount := order.TotalAmount - order.Refunded; amount > 0 {
+			var err error
+			if refunds, err = os.paymentService.refundOrder(tx, order.ID, amount, nil, "order cancelled"); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			order.Refunded += amount
 		}
 	}
 
-	// Update order status
-	order.Status = models.OrderStatusConfirmed
-	if err := tx.Save(&order).Error; err != nil {
+	// Update status to cancelled
+	if err := transitionOrder(tx, order, models.OrderStatusCancelled, actor, reason); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := cancelShipments(tx, order.ID); err != nil {
 		tx.Rollback()
-		return nil, errors.New("failed to update order status")
+		return nil, err
 	}
 
 	// Commit transaction
@@ -310,145 +471,263 @@ func (os *OrderService) ConfirmOrder(orderID uint) (*models.OrderResponse, error
 		return nil, errors.New("failed to commit transaction")
 	}
 
-	return &models.OrderResponse{
-		ID:          order.ID,
-		UserID:      order.UserID,
-		OrderNumber: order.OrderNumber,
-		Status:      order.Status,
-		TotalAmount: order.TotalAmount,
-		CreatedAt:   order.CreatedAt,
-		UpdatedAt:   order.UpdatedAt,
-	}, nil
+	os.paymentService.sendRefunds(refunds)
+
+	return toOrderResponse(order), nil
 }
 
-// ShipOrder marks an order as shipped (Admin/Seller only)
-func (os *OrderService) ShipOrder(orderID uint) (*models.OrderResponse, error) {
+// markOrderPaid moves a pending order to paid once its payment is confirmed by the provider.
+// It returns false when the order is no longer pending (e.g. cancelled or expired meanwhile).
+func markOrderPaid(tx *gorm.DB, orderID uint) (bool, error) {
 	var order models.Order
-	if err := database.DB.Where("id = ?", orderID).First(&order).Error; err != nil {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("order not found")
+			return false, errors.New("order not found")
 		}
-		return nil, errors.New("database error")
+		return false, errors.New("database error")
 	}
 
-	// Check if order can be shipped
-	if order.Status != models.OrderStatusConfirmed {
-		return nil, errors.New("order must be confirmed before shipping")
+	if order.Status != models.OrderStatusPending {
+		return false, nil
 	}
 
-	// Update status to shipped
-	order.Status = models.OrderStatusShipped
-
-	if err := database.DB.Save(&order).Error; err != nil {
-		return nil, errors.New("failed to update order status")
+	// Update status to paid
+	if err := transitionOrder(tx, &order, models.OrderStatusPaid, SystemActor(), "payment captured"); err != nil {
+		return false, err
 	}
 
-	return &models.OrderResponse{
-		ID:          order.ID,
-		UserID:      order.UserID,
-		OrderNumber: order.OrderNumber,
-		Status:      order.Status,
-		TotalAmount: order.TotalAmount,
-		CreatedAt:   order.CreatedAt,
-		UpdatedAt:   order.UpdatedAt,
-	}, nil
+	return true, nil
 }
 
-// DeliverOrder marks an order as delivered (Admin only)
-func (os *OrderService) DeliverOrder(orderID uint) (*models.OrderResponse, error) {
-	var order models.Order
-	if err := database.DB.Where("id = ?", orderID).First(&order).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("order not found")
-		}
-		return nil, errors.New("database error")
+// ExpirePendingOrders cancels pending orders whose payment window has passed and releases their stock
+func (os *OrderService) ExpirePendingOrders() (int, error) {
+	var orders []models.Order
+	if err := database.DB.Where("status = ? AND expires_at IS NOT NULL AND expires_at < ?", models.OrderStatusPending, time.Now()).
+		Order("expires_at ASC").Limit(100).Find(&orders).Error; err != nil {
+		return 0, errors.New("failed to get expired orders")
 	}
 
-	// Check if order can be delivered
-	if order.Status != models.OrderStatusShipped {
-		return nil, errors.New("order must be shipped before delivery")
+	expired := 0
+	for _, order := range orders {
+		// Re-check status under the row lock: the order may have been paid meanwhile
+		pending := func(db *gorm.DB) *gorm.DB {
+			return db.Where("status = ?", models.OrderStatusPending)
+		}
+		if _, err := os.cancelOrder(order.ID, SystemActor(), "payment window expired", pending); err != nil {
+			log.Printf("Failed to expire order %d: %v", order.ID, err)
+			continue
+		}
+		log.Printf("Order %s expired, reservations released", order.OrderNumber)
+		expired++
 	}
 
-	// Update status to delivered
-	order.Status = models.OrderStatusDelivered
+	return expired, nil
+}
 
-	if err := database.DB.Save(&order).Error; err != nil {
-		return nil, errors.New("failed to update order status")
+// StartExpiryWorker runs ExpirePendingOrders in the background at the configured interval
+func (os *OrderService) StartExpiryWorker() {
+	interval := time.Duration(os.config.Order.ExpiryCheckSeconds) * time.Second
+	if interval <= 0 {
+		log.Println("Order expiry worker disabled")
+		return
 	}
 
-	return &models.OrderResponse{
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if _, err := os.ExpirePendingOrders(); err != nil {
+				log.Printf("Order expiry worker: %v", err)
+			}
+		}
+	}()
+
+	log.Printf("Order expiry worker started (interval %s)", interval)
+}
+
+func toOrderResponse(order *models.Order) *models.OrderResponse {
+	response := &models.OrderResponse{
 		ID:          order.ID,
 		UserID:      order.UserID,
 		OrderNumber: order.OrderNumber,
 		Status:      order.Status,
+		Currency:    order.Currency,
+		Subtotal:    order.Subtotal,
+		Discount:    order.Discount,
+		Tax:         order.Tax,
 		TotalAmount: order.TotalAmount,
+		ExpiresAt:   order.ExpiresAt,
+		DeliveredAt: order.DeliveredAt,
+		Refunded:    order.Refunded,
 		CreatedAt:   order.CreatedAt,
 		UpdatedAt:   order.UpdatedAt,
-	}, nil
+
+		ShippingMethod: order.ShippingMethod,
+		ShippingCost:   order.ShippingCost,
+	}
+
+	// Orders placed before the address book have none
+	if order.ShippingAddress.Country != "" {
+		address := order.ShippingAddress
+		response.ShippingAddress = &address
+	}
+
+	for i := range order.OrderItems {
+		response.OrderItems = append(response.OrderItems, toOrderItemResponse(&order.OrderItems[i]))
+	}
+
+	for i := range order.Shipments {
+		response.Shipments = append(response.Shipments, *toShipmentResponse(&order.Shipments[i]))
+	}
+
+	for i := range order.Discounts {
+		response.Discounts = append(response.Discounts, toOrderDiscountResponse(&order.Discounts[i]))
+	}
+
+	for i := range order.Taxes {
+		response.Taxes = append(response.Taxes, toOrderTaxResponse(&order.Taxes[i]))
+	}
+
+	return response
+}
+
+func toOrderItemResponse(item *models.OrderItem) models.OrderItemResponse {
+	return models.OrderItemResponse{
+		ID:            item.ID,
+		OrderID:       item.OrderID,
+		ShipmentID:    item.ShipmentID,
+		ProductID:     item.ProductID,
+		VariantID:     item.VariantID,
+		Quantity:      item.Quantity,
+		PriceAtMoment: item.PriceAtMoment,
+		Discount:      item.Discount,
+		TaxRate:       item.TaxRate,
+		TaxInclusive:  item.TaxInclusive,
+		Tax:           item.Tax,
+	}
 }
 
-// CancelOrder cancels an order (User or Admin)
-func (os *OrderService) CancelOrder(orderID uint) (*models.OrderResponse, error) {
+// lockOrder loads an order matched by the optional scopes with SELECT ... FOR UPDATE
+func lockOrder(tx *gorm.DB, orderID uint, scopes ...func(*gorm.DB) *gorm.DB) (*models.Order, error) {
 	var order models.Order
-	if err := database.DB.Where("id = ?", orderID).First(&order).Error; err != nil {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(scopes...).Where("id = ?", orderID).First(&order).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("order not found")
 		}
 		return nil, errors.New("database error")
 	}
+	return &order, nil
+}
+
+// sellerProductIDs selects the IDs of a seller's products, including deleted ones
+func sellerProductIDs(sellerID uint) *gorm.DB {
+	return database.DB.Unscoped().Model(&models.Product{}).Select("id").Where("seller_id = ?", sellerID)
+}
+
+// sellerOrderIDs selects the IDs of orders containing at least one of a seller's products
+func sellerOrderIDs(sellerID uint) *gorm.DB {
+	return database.DB.Model(&models.OrderItem{}).Select("order_id").Where("product_id IN (?)", sellerProductIDs(sellerID))
+}
 
-	// Check if order can be cancelled
-	if order.Status == models.OrderStatusDelivered || order.Status == models.OrderStatusCancelled {
-		return nil, errors.New("order cannot be cancelled")
+// lockProducts loads requested products with SELECT ... FOR UPDATE in ID order
+func lockProducts(tx *gorm.DB, requested map[uint]int) (map[uint]*models.Product, error) {
+	products := make(map[uint]*models.Product, len(requested))
+	for _, productID := range sortedProductIDs(requested) {
+		var product models.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, productID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("product not found")
+			}
+			return nil, errors.New("database error")
+		}
+		products[productID] = &product
 	}
+	return products, nil
+}
 
-	// Update status to cancelled
-	order.Status = models.OrderStatusCancelled
+// consumeReservations turns active reservations of a confirmed order into stock decrements
+func consumeReservations(tx *gorm.DB, orderID uint, items []models.OrderItem) error {
+	if len(items) == 0 {
+		return nil
+	}
 
-	if err := database.DB.Save(&order).Error; err != nil {
-		return nil, errors.New("failed to update order status")
+	productIDs := make([]uint, 0, len(items))
+	for _, item := range items {
+		productIDs = append(productIDs, item.ProductID)
 	}
 
-	return &models.OrderResponse{
-		ID:          order.ID,
-		UserID:      order.UserID,
-		OrderNumber: order.OrderNumber,
-		Status:      order.Status,
-		TotalAmount: order.TotalAmount,
-		CreatedAt:   order.CreatedAt,
-		UpdatedAt:   order.UpdatedAt,
-	}, nil
-}
+	var reservations []models.StockReservation
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_id = ? AND product_id IN ? AND status = ?", orderID, productIDs, models.ReservationStatusActive).
+		Order("product_id ASC, variant_id ASC").Find(&reservations).Error; err != nil {
+		return errors.New("failed to get stock reservations")
+	}
 
-// PayOrder marks an order as paid (User only)
-func (os *OrderService) PayOrder(orderID, userID uint) (*models.OrderResponse, error) {
-	var order models.Order
-	if err := database.DB.Where("id = ? AND user_id = ?", orderID, userID).First(&order).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("order not found")
+	// Orders created before reservations existed decrement stock directly
+	var total int64
+	if err := tx.Model(&models.StockReservation{}).Where("order_id = ?", orderID).Count(&total).Error; err != nil {
+		return errors.New("failed to get stock reservations")
+	}
+	if total == 0 {
+		for _, item := range items {
+			if err := stockRow(tx, item.ProductID, item.VariantID).Update("stock", gorm.Expr("stock - ?", item.Quantity)).Error; err != nil {
+				return errors.New("failed to update product stock")
+			}
 		}
-		return nil, errors.New("database error")
+		return nil
 	}
 
-	// Check if order can be paid
-	if order.Status != models.OrderStatusPending {
-		return nil, errors.New("only pending orders can be paid")
+	for _, reservation := range reservations {
+		if err := stockRow(tx, reservation.ProductID, reservation.VariantID).Updates(map[string]interface{}{
+			"stock":    gorm.Expr("stock - ?", reservation.Quantity),
+			"reserved": gorm.Expr("reserved - ?", reservation.Quantity),
+		}).Error; err != nil {
+			return errors.New("failed to update product stock")
+		}
+
+		if err := tx.Model(&reservation).Update("status", models.ReservationStatusConsumed).Error; err != nil {
+			return errors.New("failed to update stock reservation")
+		}
 	}
 
-	// Update status to paid
-	order.Status = models.OrderStatusPaid
+	return nil
+}
+
+// releaseReservations returns reserved or already consumed stock of an order back to the products and variants
+func releaseReservations(tx *gorm.DB, orderID uint) error {
+	var reservations []models.StockReservation
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_id = ? AND status IN ?", orderID, []models.ReservationStatus{models.ReservationStatusActive, models.ReservationStatusConsumed}).
+		Order("product_id ASC, variant_id ASC").Find(&reservations).Error; err != nil {
+		return errors.New("failed to get stock reservations")
+	}
+
+	for _, reservation := range reservations {
+		column := "reserved"
+		expr := gorm.Expr("reserved - ?", reservation.Quantity)
+		if reservation.Status == models.ReservationStatusConsumed {
+			column = "stock"
+			expr = gorm.Expr("stock + ?", reservation.Quantity)
+		}
+
+		if err := stockRow(tx, reservation.ProductID, reservation.VariantID).Update(column, expr).Error; err != nil {
+			return errors.New("failed to release product stock")
+		}
 
-	if err := database.DB.Save(&order).Error; err != nil {
-		return nil, errors.New("failed to update order status")
+		if err := tx.Model(&reservation).Update("status", models.ReservationStatusReleased).Error; err != nil {
+			return errors.New("failed to update stock reservation")
+		}
 	}
 
-	return &models.OrderResponse{
-		ID:          order.ID,
-		UserID:      order.UserID,
-		OrderNumber: order.OrderNumber,
-		Status:      order.Status,
-		TotalAmount: order.TotalAmount,
-		CreatedAt:   order.CreatedAt,
-		UpdatedAt:   order.UpdatedAt,
-	}, nil
+	return nil
+}
+
+func sortedProductIDs(quantities map[uint]int) []uint {
+	productIDs := make([]uint, 0, len(quantities))
+	for productID := range quantities {
+		productIDs = append(productIDs, productID)
+	}
+	sort.Slice(productIDs, func(i, j int) bool { return productIDs[i] < productIDs[j] })
+	return productIDs
 }
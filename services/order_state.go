@@ -0,0 +1,156 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"go-shop/database"
+	"go-shop/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OrderActorSystem is the role of transitions made by the shop itself (payment webhooks, expiry worker)
+const OrderActorSystem = "system"
+
+// OrderActor is who changes an order status
+type OrderActor struct {
+	UserID *uint
+	Role   string
+}
+
+// SystemActor returns the actor of automatic transitions
+func SystemActor() OrderActor {
+	return OrderActor{Role: OrderActorSystem}
+}
+
+// orderScope limits order lookups to the buyer's own orders, or to orders containing a seller's products
+func (a OrderActor) orderScope() func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if a.Role != models.ROLE_USER && a.Role != models.ROLE_SELLER {
+			return db
+		}
+		if a.UserID == nil {
+			return db.Where("1 = 0")
+		}
+		if a.Role == models.ROLE_SELLER {
+			return db.Where("id IN (?)", sellerOrderIDs(*a.UserID))
+		}
+		return db.Where("user_id = ?", *a.UserID)
+	}
+}
+
+// orderTransitions declares every legal status change and the roles allowed to make it.
+// Buyers can cancel only until the order is confirmed; shipped goods come back through returns.
+var orderTransitions = map[models.OrderStatus]map[models.OrderStatus][]string{
+	models.OrderStatusPending: {
+		models.OrderStatusPaid:      {OrderActorSystem},
+		models.OrderStatusCancelled: {models.ROLE_USER, models.ROLE_SUPER_ADMIN, OrderActorSystem},
+	},
+	models.OrderStatusPaid: {
+		models.OrderStatusConfirmed: {models.ROLE_SUPER_ADMIN},
+		models.OrderStatusCancelled: {models.ROLE_USER, models.ROLE_SUPER_ADMIN},
+	},
+	models.OrderStatusConfirmed: {
+		models.OrderStatusShipped:   {models.ROLE_SELLER, models.ROLE_SUPER_ADMIN},
+		models.OrderStatusCancelled: {models.ROLE_SUPER_ADMIN},
+	},
+	models.OrderStatusShipped: {
+		models.OrderStatusDelivered: {models.ROLE_SUPER_ADMIN},
+		models.OrderStatusCancelled: {models.ROLE_SUPER_ADMIN},
+	},
+	models.OrderStatusDelivered: {
+		models.OrderStatusPartiallyRefunded: {models.ROLE_SUPER_ADMIN},
+		models.OrderStatusRefunded:          {models.ROLE_SUPER_ADMIN},
+	},
+	models.OrderStatusPartiallyRefunded: {
+		models.OrderStatusPartiallyRefunded: {models.ROLE_SUPER_ADMIN},
+		models.OrderStatusRefunded:          {models.ROLE_SUPER_ADMIN},
+	},
+	models.OrderStatusRefunded:  {},
+	models.OrderStatusCancelled: {},
+}
+
+// CanTransitionOrder reports whether the actor role may move an order from one status to another
+func CanTransitionOrder(from, to models.OrderStatus, role string) error {
+	roles, ok := orderTransitions[from][to]
+	if !ok {
+		return fmt.Errorf("cannot change order status from %s to %s", from, to)
+	}
+
+	for _, allowed := range roles {
+		if allowed == role {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s cannot change order status from %s to %s", role, from, to)
+}
+
+// transitionOrder validates a status change, saves the order with any other pending field changes
+// and records the transition in the order history
+func transitionOrder(tx *gorm.DB, order *models.Order, to models.OrderStatus, actor OrderActor, reason string) error {
+	from := order.Status
+	if err := CanTransitionOrder(from, to, actor.Role); err != nil {
+		return err
+	}
+
+	order.Status = to
+	if err := tx.Omit(clause.Associations).Save(order).Error; err != nil {
+		return errors.New("failed to update order status")
+	}
+
+	return recordOrderStatus(tx, order.ID, from, to, actor, reason)
+}
+
+// recordOrderStatus appends an entry to the order status history
+func recordOrderStatus(tx *gorm.DB, orderID uint, from, to models.OrderStatus, actor OrderActor, reason string) error {
+	history := models.OrderStatusHistory{
+		OrderID:    orderID,
+		FromStatus: from,
+		ToStatus:   to,
+		ActorID:    actor.UserID,
+		ActorRole:  actor.Role,
+		Reason:     reason,
+	}
+	if err := tx.Create(&history).Error; err != nil {
+		return errors.New("failed to record order status history")
+	}
+	return nil
+}
+
+// GetOrderHistory returns the status history of an order; userID limits it to the buyer's own orders
+func (os *OrderService) GetOrderHistory(orderID uint, userID *uint) ([]models.OrderStatusHistoryResponse, error) {
+	query := database.DB.Where("id = ?", orderID)
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+
+	var order models.Order
+	if err := query.First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("order not found")
+		}
+		return nil, errors.New("database error")
+	}
+
+	var history []models.OrderStatusHistory
+	if err := database.DB.Where("order_id = ?", order.ID).Order("created_at ASC, id ASC").Find(&history).Error; err != nil {
+		return nil, errors.New("failed to get order history")
+	}
+
+	responses := []models.OrderStatusHistoryResponse{}
+	for _, entry := range history {
+		responses = append(responses, models.OrderStatusHistoryResponse{
+			ID:         entry.ID,
+			OrderID:    entry.OrderID,
+			FromStatus: entry.FromStatus,
+			ToStatus:   entry.ToStatus,
+			ActorID:    entry.ActorID,
+			ActorRole:  entry.ActorRole,
+			Reason:     entry.Reason,
+			CreatedAt:  entry.CreatedAt,
+		})
+	}
+	return responses, nil
+}
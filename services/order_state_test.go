@@ -0,0 +1,49 @@
+package services
+
+import (
+	"testing"
+
+	"go-shop/models"
+)
+
+func TestCanTransitionOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    models.OrderStatus
+		to      models.OrderStatus
+		role    string
+		allowed bool
+	}{
+		{"buyer cancels pending order", models.OrderStatusPending, models.OrderStatusCancelled, models.ROLE_USER, true},
+		{"buyer cancels paid order", models.OrderStatusPaid, models.OrderStatusCancelled, models.ROLE_USER, true},
+		{"buyer cannot cancel confirmed order", models.OrderStatusConfirmed, models.OrderStatusCancelled, models.ROLE_USER, false},
+		{"buyer cannot cancel shipped order", models.OrderStatusShipped, models.OrderStatusCancelled, models.ROLE_USER, false},
+		{"super admin cancels confirmed order", models.OrderStatusConfirmed, models.OrderStatusCancelled, models.ROLE_SUPER_ADMIN, true},
+		{"super admin cancels shipped order", models.OrderStatusShipped, models.OrderStatusCancelled, models.ROLE_SUPER_ADMIN, true},
+		{"nobody cancels delivered order", models.OrderStatusDelivered, models.OrderStatusCancelled, models.ROLE_SUPER_ADMIN, false},
+		{"system expires pending order", models.OrderStatusPending, models.OrderStatusCancelled, OrderActorSystem, true},
+		{"system marks order paid", models.OrderStatusPending, models.OrderStatusPaid, OrderActorSystem, true},
+		{"buyer cannot mark order paid", models.OrderStatusPending, models.OrderStatusPaid, models.ROLE_USER, false},
+		{"super admin confirms paid order", models.OrderStatusPaid, models.OrderStatusConfirmed, models.ROLE_SUPER_ADMIN, true},
+		{"seller cannot confirm order", models.OrderStatusPaid, models.OrderStatusConfirmed, models.ROLE_SELLER, false},
+		{"seller ships confirmed order", models.OrderStatusConfirmed, models.OrderStatusShipped, models.ROLE_SELLER, true},
+		{"seller cannot ship paid order", models.OrderStatusPaid, models.OrderStatusShipped, models.ROLE_SELLER, false},
+		{"seller cannot deliver order", models.OrderStatusShipped, models.OrderStatusDelivered, models.ROLE_SELLER, false},
+		{"super admin delivers shipped order", models.OrderStatusShipped, models.OrderStatusDelivered, models.ROLE_SUPER_ADMIN, true},
+		{"super admin refunds delivered order", models.OrderStatusDelivered, models.OrderStatusPartiallyRefunded, models.ROLE_SUPER_ADMIN, true},
+		{"cancelled order is final", models.OrderStatusCancelled, models.OrderStatusPending, models.ROLE_SUPER_ADMIN, false},
+		{"refunded order is final", models.OrderStatusRefunded, models.OrderStatusDelivered, models.ROLE_SUPER_ADMIN, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CanTransitionOrder(tt.from, tt.to, tt.role)
+			if tt.allowed && err != nil {
+				t.Fatalf("expected %s to move %s -> %s, got %v", tt.role, tt.from, tt.to, err)
+			}
+			if !tt.allowed && err == nil {
+				t.Fatalf("expected %s not to move %s -> %s", tt.role, tt.from, tt.to)
+			}
+		})
+	}
+}
@@ -0,0 +1,432 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go-shop/config"
+	"go-shop/database"
+	"go-shop/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PaymentService struct {
+	config   *config.Config
+	provider PaymentProvider
+}
+
+func NewPaymentService(cfg *config.Config, provider PaymentProvider) *PaymentService {
+	return &PaymentService{
+		config:   cfg,
+		provider: provider,
+	}
+}
+
+// CreatePayment starts payment of a pending order with the configured provider
+func (ps *PaymentService) CreatePayment(orderID, userID uint) (*models.PaymentResponse, error) {
+	var order models.Order
+	if err := database.DB.Where("id = ? AND user_id = ?", orderID, userID).First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("order not found")
+		}
+		return nil, errors.New("database error")
+	}
+
+	// Check if order can be paid
+	if order.Status != models.OrderStatusPending {
+		return nil, errors.New("only pending orders can be paid")
+	}
+
+	if order.ExpiresAt != nil && time.Now().After(*order.ExpiresAt) {
+		return nil, errors.New("order payment window has expired")
+	}
+
+	// Reuse a payment that is still waiting for the buyer
+	var existing models.Payment
+	err := database.DB.Where("order_id = ? AND provider = ? AND status = ?", order.ID, ps.provider.Name(), models.PaymentStatusPending).
+		Order("created_at DESC").First(&existing).Error
+	if err == nil {
+		response := toPaymentResponse(&existing)
+		response.ClientSecret = existing.ClientSecret
+		return response, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("database error")
+	}
+
+	intent, err := ps.provider.CreateIntent(&order, order.TotalAmount, order.Currency)
+	if err != nil {
+		log.Printf("Failed to create payment intent for order %d: %v", order.ID, err)
+		return nil, errors.New("failed to create payment")
+	}
+
+	payment := models.Payment{
+		OrderID:      order.ID,
+		UserID:       order.UserID,
+		Provider:     ps.provider.Name(),
+		ProviderRef:  intent.ProviderRef,
+		ClientSecret: intent.ClientSecret,
+		Amount:       order.TotalAmount,
+		Currency:     order.Currency,
+		Status:       models.PaymentStatusPending,
+	}
+
+	if err := database.DB.Create(&payment).Error; err != nil {
+		return nil, errors.New("failed to create payment")
+	}
+
+	response := toPaymentResponse(&payment)
+	response.ClientSecret = payment.ClientSecret
+	return response, nil
+}
+
+// GetOrderPayments returns all payment attempts of a user's order
+func (ps *PaymentService) GetOrderPayments(orderID, userID uint) ([]models.PaymentResponse, error) {
+	var payments []models.Payment
+	if err := database.DB.Where("order_id = ? AND user_id = ?", orderID, userID).Order("created_at DESC").Find(&payments).Error; err != nil {
+		return nil, errors.New("failed to get payments")
+	}
+
+	var responses []models.PaymentResponse
+	for i := range payments {
+		responses = append(responses, *toPaymentResponse(&payments[i]))
+	}
+	return responses, nil
+}
+
+// HandleWebhook verifies a provider notification and applies it to the payment and its order
+func (ps *PaymentService) HandleWebhook(payload []byte, signature string) error {
+	event, err := ps.provider.VerifyWebhook(payload, signature)
+	if err != nil {
+		return err
+	}
+
+	// Start transaction
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var payment models.Payment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("provider = ? AND provider_ref = ?", ps.provider.Name(), event.ProviderRef).
+		First(&payment).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("payment not found")
+		}
+		return errors.New("database error")
+	}
+
+	var refunds []models.Refund
+	switch event.Type {
+	case PaymentEventFailed:
+		if payment.Status != models.PaymentStatusPending && payment.Status != models.PaymentStatusAuthorized {
+			tx.Rollback()
+			return nil
+		}
+		payment.Status = models.PaymentStatusFailed
+		payment.FailureReason = event.Reason
+
+	case PaymentEventAuthorized, PaymentEventSucceeded:
+		if payment.Status == models.PaymentStatusCaptured || payment.Status == models.PaymentStatusRefunded {
+			// Duplicate delivery
+			tx.Rollback()
+			return nil
+		}
+		if payment.Status != models.PaymentStatusPending && payment.Status != models.PaymentStatusAuthorized {
+			tx.Rollback()
+			return fmt.Errorf("payment is %s", payment.Status)
+		}
+		if event.Amount != payment.Amount {
+			tx.Rollback()
+			return errors.New("payment amount mismatch")
+		}
+
+		// Authorized funds are captured once the transaction has committed
+		if event.Type == PaymentEventAuthorized {
+			payment.Status = models.PaymentStatusAuthorized
+			break
+		}
+
+		if refunds, err = ps.applyCapture(tx, &payment); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+	default:
+		tx.Rollback()
+		return fmt.Errorf("unsupported event type %s", event.Type)
+	}
+
+	if err := tx.Save(&payment).Error; err != nil {
+		tx.Rollback()
+		return errors.New("failed to update payment")
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return errors.New("failed to commit transaction")
+	}
+
+	log.Printf("Payment %s for order %d is %s", payment.ProviderRef, payment.OrderID, payment.Status)
+
+	if payment.Status == models.PaymentStatusAuthorized {
+		return ps.capturePayment(&payment)
+	}
+	ps.sendRefunds(refunds)
+	return nil
+}
+
+// capturePayment charges an authorized payment and then applies the capture to the payment and its
+// order. A failed capture leaves the payment authorized; a redelivered event retries it.
+func (ps *PaymentService) capturePayment(payment *models.Payment) error {
+	if err := ps.provider.Capture(payment.ProviderRef, payment.Amount, fmt.Sprintf("capture-%d", payment.ID)); err != nil {
+		log.Printf("Failed to capture payment %s: %v", payment.ProviderRef, err)
+		return nil
+	}
+
+	// Start transaction
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(payment, payment.ID).Error; err != nil {
+		tx.Rollback()
+		return errors.New("payment not found")
+	}
+
+	// Applied meanwhile by a concurrent delivery
+	if payment.Status != models.PaymentStatusAuthorized {
+		tx.Rollback()
+		return nil
+	}
+
+	refunds, err := ps.applyCapture(tx, payment)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Save(payment).Error; err != nil {
+		tx.Rollback()
+		return errors.New("failed to update payment")
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return errors.New("failed to commit transaction")
+	}
+
+	log.Printf("Payment %s for order %d is %s", payment.ProviderRef, payment.OrderID, payment.Status)
+	ps.sendRefunds(refunds)
+	return nil
+}
+
+// applyCapture marks a payment captured and its order paid. Money that arrives for an order that is
+// no longer payable is recorded as a pending refund. The caller saves the payment.
+func (ps *PaymentService) applyCapture(tx *gorm.DB, payment *models.Payment) ([]models.Refund, error) {
+	payment.Status = models.PaymentStatusCaptured
+
+	paid, err := markOrderPaid(tx, payment.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	if paid {
+		return nil, nil
+	}
+
+	// The order was cancelled or expired before the money arrived: give it back
+	refund, err := refundPayment(tx, payment, payment.Amount, nil, "order is no longer payable")
+	if err != nil {
+		return nil, err
+	}
+	return []models.Refund{*refund}, nil
+}
+
+// SimulateSandboxPayment sends a signed sandbox webhook for a payment
+func (ps *PaymentService) SimulateSandboxPayment(providerRef string, req *models.SandboxPaymentRequest) (*models.PaymentResponse, error) {
+	sandbox, ok := ps.provider.(*SandboxPaymentProvider)
+	if !ok {
+		return nil, errors.New("sandbox payments are disabled")
+	}
+
+	var payment models.Payment
+	if err := database.DB.Where("provider_ref = ?", providerRef).First(&payment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("payment not found")
+		}
+		return nil, errors.New("database error")
+	}
+
+	event := PaymentEvent{
+		Type:        "payment." + req.Outcome,
+		ProviderRef: payment.ProviderRef,
+		Amount:      payment.Amount,
+	}
+	if req.Outcome == "failed" {
+		event.Reason = "declined by sandbox"
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, errors.New("failed to build sandbox event")
+	}
+
+	if err := ps.HandleWebhook(payload, sandbox.Sign(payload)); err != nil {
+		return nil, err
+	}
+
+	if err := database.DB.First(&payment, payment.ID).Error; err != nil {
+		return nil, errors.New("database error")
+	}
+	return toPaymentResponse(&payment), nil
+}
+
+func toPaymentResponse(payment *models.Payment) *models.PaymentResponse {
+	return &models.PaymentResponse{
+		ID:             payment.ID,
+		OrderID:        payment.OrderID,
+		Provider:       payment.Provider,
+		ProviderRef:    payment.ProviderRef,
+		Amount:         payment.Amount,
+		RefundedAmount: payment.RefundedAmount,
+		Currency:       payment.Currency,
+		Status:         payment.Status,
+		FailureReason:  payment.FailureReason,
+		CreatedAt:      payment.CreatedAt,
+		UpdatedAt:      payment.UpdatedAt,
+	}
+}
+
+// refundOrder records refunds of amount from the order's captured payments. Any part that cannot be
+// matched to an online payment is recorded for manual processing. Nothing is sent to the provider here:
+// the caller passes the returned pending refunds to sendRefunds once the transaction has committed.
+func (ps *PaymentService) refundOrder(tx *gorm.DB, orderID uint, amount models.Money, returnID *uint, reason string) ([]models.Refund, error) {
+	var payments []models.Payment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_id = ? AND provider = ? AND status = ?", orderID, ps.provider.Name(), models.PaymentStatusCaptured).
+		Order("created_at ASC").Find(&payments).Error; err != nil {
+		return nil, errors.New("failed to get order payments")
+	}
+
+	var refunds []models.Refund
+	remaining := amount
+	for i := range payments {
+		payment := &payments[i]
+		refundable := payment.Amount - payment.RefundedAmount
+		if remaining <= 0 || refundable <= 0 {
+			continue
+		}
+
+		part := min(remaining, refundable)
+		refund, err := refundPayment(tx, payment, part, returnID, reason)
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Save(payment).Error; err != nil {
+			return nil, errors.New("failed to update payment")
+		}
+
+		refunds = append(refunds, *refund)
+		remaining -= part
+	}
+
+	if remaining > 0 {
+		refund := models.Refund{
+			OrderID:  orderID,
+			ReturnID: returnID,
+			Amount:   remaining,
+			Status:   models.RefundStatusManual,
+			Reason:   reason,
+		}
+		if err := tx.Create(&refund).Error; err != nil {
+			return nil, errors.New("failed to record refund")
+		}
+		log.Printf("Refund of %s for order %d requires manual processing", remaining, orderID)
+	}
+
+	return refunds, nil
+}
+
+// refundPayment reserves amount of a captured payment for refund and records it as a pending refund.
+// The caller saves the payment.
+func refundPayment(tx *gorm.DB, payment *models.Payment, amount models.Money, returnID *uint, reason string) (*models.Refund, error) {
+	payment.RefundedAmount += amount
+	if payment.RefundedAmount >= payment.Amount {
+		payment.Status = models.PaymentStatusRefunded
+	}
+
+	refund := models.Refund{
+		OrderID:     payment.OrderID,
+		PaymentID:   &payment.ID,
+		ReturnID:    returnID,
+		Amount:      amount,
+		Status:      models.RefundStatusPending,
+		Reason:      reason,
+		ProviderRef: payment.ProviderRef,
+	}
+	if err := tx.Create(&refund).Error; err != nil {
+		return nil, errors.New("failed to record refund")
+	}
+	return &refund, nil
+}
+
+// sendRefunds sends committed pending refunds to the provider. A refund the provider rejects stays
+// pending and is retried by the refund worker under the same idempotency key.
+func (ps *PaymentService) sendRefunds(refunds []models.Refund) {
+	for _, refund := range refunds {
+		if err := ps.provider.Refund(refund.ProviderRef, refund.Amount, fmt.Sprintf("refund-%d", refund.ID)); err != nil {
+			log.Printf("Failed to refund payment %s (refund %d): %v", refund.ProviderRef, refund.ID, err)
+			continue
+		}
+		if err := database.DB.Model(&models.Refund{}).Where("id = ? AND status = ?", refund.ID, models.RefundStatusPending).
+			Update("status", models.RefundStatusSucceeded).Error; err != nil {
+			log.Printf("Failed to mark refund %d as succeeded: %v", refund.ID, err)
+		}
+	}
+}
+
+// RetryPendingRefunds sends refunds that are still pending, e.g. after a provider outage
+func (ps *PaymentService) RetryPendingRefunds() (int, error) {
+	var refunds []models.Refund
+	if err := database.DB.Where("status = ? AND payment_id IS NOT NULL", models.RefundStatusPending).
+		Order("created_at ASC").Limit(100).Find(&refunds).Error; err != nil {
+		return 0, errors.New("failed to get pending refunds")
+	}
+
+	ps.sendRefunds(refunds)
+	return len(refunds), nil
+}
+
+// StartRefundWorker runs RetryPendingRefunds in the background at the configured interval
+func (ps *PaymentService) StartRefundWorker() {
+	interval := time.Duration(ps.config.Payment.RefundRetrySeconds) * time.Second
+	if interval <= 0 {
+		log.Println("Refund worker disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if _, err := ps.RetryPendingRefunds(); err != nil {
+				log.Printf("Refund worker: %v", err)
+			}
+		}
+	}()
+
+	log.Printf("Refund worker started (interval %s)", interval)
+}
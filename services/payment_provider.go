@@ -0,0 +1,67 @@
+package services
+
+import (
+	"errors"
+	"log"
+
+	"go-shop/config"
+	"go-shop/models"
+)
+
+// Payment event types reported by providers through webhooks
+const (
+	PaymentEventSucceeded  = "payment.succeeded"
+	PaymentEventAuthorized = "payment.authorized"
+	PaymentEventFailed     = "payment.failed"
+)
+
+var ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+
+// webhookSecretPlaceholder is the example secret older configurations shipped with
+const webhookSecretPlaceholder = "your-payment-webhook-secret"
+
+// PaymentIntent is the provider-side handle of a payment
+type PaymentIntent struct {
+	ProviderRef  string
+	ClientSecret string
+}
+
+// PaymentEvent is a verified webhook notification from a provider
+type PaymentEvent struct {
+	Type        string       `json:"type"`
+	ProviderRef string       `json:"reference"`
+	Amount      models.Money `json:"amount"`
+	Reason      string       `json:"reason,omitempty"`
+}
+
+// PaymentProvider is implemented by every payment gateway integration
+type PaymentProvider interface {
+	// Name returns the provider identifier stored on payments
+	Name() string
+	// CreateIntent registers a payment for the order amount with the provider
+	CreateIntent(order *models.Order, amount models.Money, currency string) (*PaymentIntent, error)
+	// Capture charges previously authorized funds. Calls repeated with the same idempotency key
+	// must not charge twice.
+	Capture(providerRef string, amount models.Money, idempotencyKey string) error
+	// Refund returns captured funds to the buyer. Calls repeated with the same idempotency key
+	// must not refund twice.
+	Refund(providerRef string, amount models.Money, idempotencyKey string) error
+	// VerifyWebhook checks the webhook signature and decodes the event
+	VerifyWebhook(payload []byte, signature string) (*PaymentEvent, error)
+}
+
+// NewPaymentProvider builds the provider selected in configuration. Anyone knowing the webhook
+// secret can mark orders paid, so it must be set to a secret value.
+func NewPaymentProvider(cfg *config.Config) PaymentProvider {
+	if cfg.Payment.WebhookSecret == "" || cfg.Payment.WebhookSecret == webhookSecretPlaceholder {
+		log.Fatal("PAYMENT_WEBHOOK_SECRET must be set to a secret value")
+	}
+
+	switch cfg.Payment.Provider {
+	case SandboxProviderName:
+		return NewSandboxPaymentProvider(cfg.Payment.WebhookSecret)
+	default:
+		log.Fatalf("Unknown payment provider: %s", cfg.Payment.Provider)
+		return nil
+	}
+}
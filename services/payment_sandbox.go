@@ -0,0 +1,78 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"go-shop/models"
+	"go-shop/utils"
+)
+
+const SandboxProviderName = "sandbox"
+
+// SandboxPaymentProvider accepts every operation locally and signs webhooks with a shared secret.
+// It is meant for local development and tests.
+type SandboxPaymentProvider struct {
+	webhookSecret string
+}
+
+func NewSandboxPaymentProvider(webhookSecret string) *SandboxPaymentProvider {
+	return &SandboxPaymentProvider{
+		webhookSecret: webhookSecret,
+	}
+}
+
+func (sp *SandboxPaymentProvider) Name() string {
+	return SandboxProviderName
+}
+
+func (sp *SandboxPaymentProvider) CreateIntent(order *models.Order, amount models.Money, currency string) (*PaymentIntent, error) {
+	ref, err := utils.GenerateRandomToken(12)
+	if err != nil {
+		return nil, errors.New("failed to generate payment reference")
+	}
+	secret, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return nil, errors.New("failed to generate client secret")
+	}
+
+	log.Printf("Sandbox payment created for order %s: %s %s", order.OrderNumber, amount, currency)
+
+	return &PaymentIntent{
+		ProviderRef:  "sbx_" + ref,
+		ClientSecret: secret,
+	}, nil
+}
+
+func (sp *SandboxPaymentProvider) Capture(providerRef string, amount models.Money, idempotencyKey string) error {
+	log.Printf("Sandbox payment %s captured: %s (%s)", providerRef, amount, idempotencyKey)
+	return nil
+}
+
+func (sp *SandboxPaymentProvider) Refund(providerRef string, amount models.Money, idempotencyKey string) error {
+	log.Printf("Sandbox payment %s refunded: %s (%s)", providerRef, amount, idempotencyKey)
+	return nil
+}
+
+func (sp *SandboxPaymentProvider) VerifyWebhook(payload []byte, signature string) (*PaymentEvent, error) {
+	if !hmac.Equal([]byte(sp.Sign(payload)), []byte(signature)) {
+		return nil, ErrInvalidWebhookSignature
+	}
+
+	var event PaymentEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, errors.New("invalid webhook payload")
+	}
+	return &event, nil
+}
+
+// Sign returns the hex HMAC-SHA256 signature the sandbox expects for a webhook payload
+func (sp *SandboxPaymentProvider) Sign(payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(sp.webhookSecret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
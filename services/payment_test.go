@@ -0,0 +1,245 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"go-shop/database"
+	"go-shop/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func signedEvent(t *testing.T, provider *SandboxPaymentProvider, event PaymentEvent) ([]byte, string) {
+	t.Helper()
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payload, provider.Sign(payload)
+}
+
+func TestSandboxVerifyWebhook(t *testing.T) {
+	provider := NewSandboxPaymentProvider("test-webhook-secret")
+	payload, signature := signedEvent(t, provider, PaymentEvent{Type: PaymentEventSucceeded, ProviderRef: "sbx_1", Amount: 1250})
+	otherSignature := NewSandboxPaymentProvider("other-secret").Sign(payload)
+
+	tests := []struct {
+		name      string
+		payload   []byte
+		signature string
+		wantErr   bool
+	}{
+		{"valid signature", payload, signature, false},
+		{"tampered payload", []byte(`{"type":"payment.succeeded","reference":"sbx_1","amount":1}`), signature, true},
+		{"signed with another secret", payload, otherSignature, true},
+		{"missing signature", payload, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := provider.VerifyWebhook(tt.payload, tt.signature)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidWebhookSignature) {
+					t.Fatalf("expected ErrInvalidWebhookSignature, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if event.Type != PaymentEventSucceeded || event.ProviderRef != "sbx_1" || event.Amount != 1250 {
+				t.Fatalf("unexpected event %+v", event)
+			}
+		})
+	}
+}
+
+func TestHandleWebhookRejectsInvalidSignature(t *testing.T) {
+	ps := NewPaymentService(testConfig(), NewSandboxPaymentProvider("test-webhook-secret"))
+	payload, signature := signedEvent(t, NewSandboxPaymentProvider("forged"), PaymentEvent{Type: PaymentEventSucceeded, ProviderRef: "sbx_1", Amount: 1250})
+
+	if err := ps.HandleWebhook(payload, signature); !errors.Is(err, ErrInvalidWebhookSignature) {
+		t.Fatalf("expected ErrInvalidWebhookSignature, got %v", err)
+	}
+}
+
+func createTestPayment(t *testing.T, order *models.Order) *models.Payment {
+	t.Helper()
+	payment := models.Payment{
+		OrderID:     order.ID,
+		UserID:      order.UserID,
+		Provider:    SandboxProviderName,
+		ProviderRef: "sbx_" + testToken(t),
+		Amount:      order.TotalAmount,
+		Currency:    "USD",
+		Status:      models.PaymentStatusPending,
+	}
+	if err := database.DB.Create(&payment).Error; err != nil {
+		t.Fatalf("failed to create payment: %v", err)
+	}
+	return &payment
+}
+
+func TestHandleWebhookAmountMismatch(t *testing.T) {
+	requireDatabase(t)
+	provider := NewSandboxPaymentProvider("test-webhook-secret")
+	ps := NewPaymentService(testConfig(), provider)
+
+	order := createTestOrder(t, createTestUser(t), models.OrderStatusPending, 1250)
+	payment := createTestPayment(t, order)
+
+	payload, signature := signedEvent(t, provider, PaymentEvent{Type: PaymentEventSucceeded, ProviderRef: payment.ProviderRef, Amount: 1})
+	if err := ps.HandleWebhook(payload, signature); err == nil {
+		t.Fatal("expected an amount mismatch error")
+	}
+
+	database.DB.First(payment, payment.ID)
+	database.DB.First(order, order.ID)
+	if payment.Status != models.PaymentStatusPending || order.Status != models.OrderStatusPending {
+		t.Fatalf("mismatched amount changed payment to %s and order to %s", payment.Status, order.Status)
+	}
+
+	payload, signature = signedEvent(t, provider, PaymentEvent{Type: PaymentEventSucceeded, ProviderRef: payment.ProviderRef, Amount: 1250})
+	if err := ps.HandleWebhook(payload, signature); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	database.DB.First(payment, payment.ID)
+	database.DB.First(order, order.ID)
+	if payment.Status != models.PaymentStatusCaptured || order.Status != models.OrderStatusPaid {
+		t.Fatalf("expected captured payment and paid order, got %s and %s", payment.Status, order.Status)
+	}
+}
+
+func TestHandleWebhookRefundsCancelledOrder(t *testing.T) {
+	requireDatabase(t)
+	provider := NewSandboxPaymentProvider("test-webhook-secret")
+	ps := NewPaymentService(testConfig(), provider)
+
+	order := createTestOrder(t, createTestUser(t), models.OrderStatusPending, 1250)
+	payment := createTestPayment(t, order)
+	if err := database.DB.Model(order).Update("status", models.OrderStatusCancelled).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	payload, signature := signedEvent(t, provider, PaymentEvent{Type: PaymentEventSucceeded, ProviderRef: payment.ProviderRef, Amount: 1250})
+	if err := ps.HandleWebhook(payload, signature); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	database.DB.First(payment, payment.ID)
+	database.DB.First(order, order.ID)
+	if payment.Status != models.PaymentStatusRefunded || payment.RefundedAmount != 1250 {
+		t.Fatalf("expected the payment to be refunded in full, got %s with %s refunded", payment.Status, payment.RefundedAmount)
+	}
+	if order.Status != models.OrderStatusCancelled {
+		t.Fatalf("expected the order to stay cancelled, got %s", order.Status)
+	}
+
+	var refunds []models.Refund
+	database.DB.Where("payment_id = ?", payment.ID).Find(&refunds)
+	if len(refunds) != 1 || refunds[0].Amount != 1250 || refunds[0].Status != models.RefundStatusSucceeded {
+		t.Fatalf("expected one succeeded refund of 12.50, got %+v", refunds)
+	}
+
+	// A repeated delivery of the event changes nothing
+	if err := ps.HandleWebhook(payload, signature); err != nil {
+		t.Fatalf("unexpected error on redelivery: %v", err)
+	}
+	var count int64
+	database.DB.Model(&models.Refund{}).Where("payment_id = ?", payment.ID).Count(&count)
+	if count != 1 {
+		t.Fatalf("expected redelivery not to refund again, got %d refunds", count)
+	}
+}
+
+// recordingProvider is a sandbox provider that remembers the refunds sent to it
+type recordingProvider struct {
+	*SandboxPaymentProvider
+	refundKeys []string
+}
+
+func (rp *recordingProvider) Refund(providerRef string, amount models.Money, idempotencyKey string) error {
+	rp.refundKeys = append(rp.refundKeys, idempotencyKey)
+	return nil
+}
+
+func mockDatabase(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := database.DB
+	database.DB = gormDB
+	t.Cleanup(func() {
+		database.DB = previous
+		db.Close()
+	})
+	return mock
+}
+
+func TestRefundOrderSendsNothingUntilCommit(t *testing.T) {
+	paymentColumns := []string{"id", "order_id", "user_id", "provider", "provider_ref", "amount", "refunded_amount", "currency", "status"}
+
+	t.Run("rolled back", func(t *testing.T) {
+		mock := mockDatabase(t)
+		provider := &recordingProvider{SandboxPaymentProvider: NewSandboxPaymentProvider("test-webhook-secret")}
+		ps := NewPaymentService(testConfig(), provider)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT \* FROM "payments"`).
+			WillReturnRows(sqlmock.NewRows(paymentColumns).AddRow(7, 3, 1, SandboxProviderName, "sbx_1", 1250, 0, "USD", models.PaymentStatusCaptured))
+		mock.ExpectQuery(`INSERT INTO "refunds"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+		mock.ExpectExec(`UPDATE "payments"`).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectRollback()
+
+		tx := database.DB.Begin()
+		refunds, err := ps.refundOrder(tx, 3, 1250, nil, "order cancelled")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(refunds) != 1 || refunds[0].Status != models.RefundStatusPending {
+			t.Fatalf("expected one pending refund, got %+v", refunds)
+		}
+		tx.Rollback()
+
+		if len(provider.refundKeys) != 0 {
+			t.Fatalf("expected no provider refund for a rolled back transaction, got %v", provider.refundKeys)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("committed", func(t *testing.T) {
+		mock := mockDatabase(t)
+		provider := &recordingProvider{SandboxPaymentProvider: NewSandboxPaymentProvider("test-webhook-secret")}
+		ps := NewPaymentService(testConfig(), provider)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "refunds" SET "status"=\$1 WHERE id = \$2 AND status = \$3`).
+			WithArgs(models.RefundStatusSucceeded, 11, models.RefundStatusPending).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		ps.sendRefunds([]models.Refund{{ID: 11, Amount: 1250, Status: models.RefundStatusPending, ProviderRef: "sbx_1"}})
+
+		if len(provider.refundKeys) != 1 || provider.refundKeys[0] != "refund-11" {
+			t.Fatalf("expected one provider refund keyed by the refund ID, got %v", provider.refundKeys)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"go-shop/database"
+	"go-shop/models"
+
+	"gorm.io/gorm"
+)
+
+// GetProductPrices returns the list prices of a product; sellers (sellerID set) only see their own products
+func (ps *ProductService) GetProductPrices(productID uint, sellerID *uint) ([]models.ProductPriceResponse, error) {
+	product, err := findOwnedProduct(productID, sellerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var prices []models.ProductPrice
+	if err := database.DB.Where("product_id = ?", product.ID).Order("currency ASC, variant_id ASC").Find(&prices).Error; err != nil {
+		return nil, errors.New("failed to get product prices")
+	}
+
+	responses := []models.ProductPriceResponse{}
+	for i := range prices {
+		responses = append(responses, toProductPriceResponse(&prices[i]))
+	}
+	return responses, nil
+}
+
+// SetProductPrices replaces the list prices of a product in currencies other than the base currency.
+// Products and variants without a list price in a currency are converted at its exchange rate.
+func (ps *ProductService) SetProductPrices(productID uint, sellerID *uint, req *models.ProductPricesRequest) ([]models.ProductPriceResponse, error) {
+	product, err := findOwnedProduct(productID, sellerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var variantIDs []uint
+	if err := database.DB.Model(&models.ProductVariant{}).Where("product_id = ?", product.ID).Pluck("id", &variantIDs).Error; err != nil {
+		return nil, errors.New("failed to get product variants")
+	}
+	ownVariants := uniqueIDs(variantIDs)
+
+	var rates []models.ExchangeRate
+	if err := database.DB.Find(&rates).Error; err != nil {
+		return nil, errors.New("failed to get exchange rates")
+	}
+	supported := make(map[string]bool, len(rates))
+	for _, rate := range rates {
+		supported[rate.Currency] = true
+	}
+
+	type listedPrice struct {
+		variantID uint
+		currency  string
+	}
+	prices := make([]models.ProductPrice, 0, len(req.Prices))
+	seen := make(map[listedPrice]bool)
+	for _, item := range req.Prices {
+		currency := models.NormalizeCurrency(item.Currency)
+		if currency == ps.cfg.Currency.Base {
+			return nil, errors.New("prices in the base currency are set on the product and its variants")
+		}
+		if !supported[currency] {
+			return nil, fmt.Errorf("%w: %s has no exchange rate", ErrUnsupportedCurrency, item.Currency)
+		}
+
+		key := listedPrice{currency: currency}
+		if item.VariantID != nil {
+			if !ownVariants[*item.VariantID] {
+				return nil, errors.New("product variant not found")
+			}
+			key.variantID = *item.VariantID
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("price in %s is listed twice", currency)
+		}
+		seen[key] = true
+
+		prices = append(prices, models.ProductPrice{
+			ProductID: product.ID,
+			VariantID: key.variantID,
+			Currency:  currency,
+			Price:     item.Price,
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("product_id = ?", product.ID).Delete(&models.ProductPrice{}).Error; err != nil {
+			return err
+		}
+		if len(prices) == 0 {
+			return nil
+		}
+		return tx.Create(&prices).Error
+	})
+	if err != nil {
+		return nil, errors.New("failed to save product prices")
+	}
+
+	return ps.GetProductPrices(product.ID, sellerID)
+}
+
+func toProductPriceResponse(price *models.ProductPrice) models.ProductPriceResponse {
+	response := models.ProductPriceResponse{
+		Currency: price.Currency,
+		Price:    price.Price,
+	}
+	if price.VariantID != 0 {
+		variantID := price.VariantID
+		response.VariantID = &variantID
+	}
+	return response
+}
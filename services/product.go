@@ -2,20 +2,29 @@ package services
 
 import (
 	"errors"
+	"strings"
+	"unicode"
 
+	"go-shop/config"
 	"go-shop/database"
 	"go-shop/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-type ProductService struct{}
+type ProductService struct {
+	cfg *config.Config
+}
 
-func NewProductService() *ProductService {
-	return &ProductService{}
+func NewProductService(cfg *config.Config) *ProductService {
+	return &ProductService{
+		cfg: cfg,
+	}
 }
 
-func (ps *ProductService) CreateProduct(req *models.ProductCreateRequest) (*models.ProductResponse, error) {
+// CreateProduct creates a product; sellerID is set when a seller creates it and always becomes the owner
+func (ps *ProductService) CreateProduct(req *models.ProductCreateRequest, sellerID *uint) (*models.ProductResponse, error) {
 	// Check if category exists
 	var category models.Category
 	if err := database.DB.First(&category, req.CategoryID).Error; err != nil {
@@ -25,8 +34,18 @@ func (ps *ProductService) CreateProduct(req *models.ProductCreateRequest) (*mode
 		return nil, errors.New("database error")
 	}
 
+	owner := sellerID
+	if owner == nil && req.SellerID != nil {
+		// Super admin assigns the product to a seller
+		if err := checkSeller(*req.SellerID); err != nil {
+			return nil, err
+		}
+		owner = req.SellerID
+	}
+
 	product := models.Product{
 		CategoryID:  &req.CategoryID,
+		SellerID:    owner,
 		Title:       req.Title,
 		Description: req.Description,
 		Images:      models.StringArray(req.Images),
@@ -43,10 +62,12 @@ func (ps *ProductService) CreateProduct(req *models.ProductCreateRequest) (*mode
 	return &models.ProductResponse{
 		ID:          product.ID,
 		CategoryID:  product.CategoryID,
+		SellerID:    product.SellerID,
 		Title:       product.Title,
 		Description: product.Description,
 		Images:      []string(product.Images),
 		Price:       product.Price,
+		Currency:    ps.cfg.Currency.Base,
 		Model:       product.Model,
 		ExtraInfo:   product.ExtraInfo,
 		Stock:       product.Stock,
@@ -55,7 +76,9 @@ func (ps *ProductService) CreateProduct(req *models.ProductCreateRequest) (*mode
 	}, nil
 }
 
-func (ps *ProductService) GetProducts(categoryID *uint, limit, offset int) ([]models.ProductResponse, error) {
+// GetProducts lists products priced in currency ("" for the base currency), limited to one seller's
+// catalogue when sellerID is set
+func (ps *ProductService) GetProducts(categoryID, sellerID *uint, currency string, limit, offset int) ([]models.ProductResponse, error) {
 	var products []models.Product
 	query := database.DB
 
@@ -63,19 +86,30 @@ func (ps *ProductService) GetProducts(categoryID *uint, limit, offset int) ([]mo
 		query = query.Where("category_id = ?", *categoryID)
 	}
 
+	if sellerID != nil {
+		query = query.Where("seller_id = ?", *sellerID)
+	}
+
 	if err := query.Limit(limit).Offset(offset).Find(&products).Error; err != nil {
 		return nil, errors.New("failed to get products")
 	}
 
+	prices, err := loadPriceList(database.DB, ps.cfg, currency, productIDs(products))
+	if err != nil {
+		return nil, err
+	}
+
 	var productResponses []models.ProductResponse
 	for _, product := range products {
 		productResponses = append(productResponses, models.ProductResponse{
 			ID:          product.ID,
 			CategoryID:  product.CategoryID,
+			SellerID:    product.SellerID,
 			Title:       product.Title,
 			Description: product.Description,
 			Images:      []string(product.Images),
-			Price:       product.Price,
+			Price:       prices.Price(&product, nil),
+			Currency:    prices.currency,
 			Model:       product.Model,
 			ExtraInfo:   product.ExtraInfo,
 			Stock:       product.Stock,
@@ -87,15 +121,23 @@ func (ps *ProductService) GetProducts(categoryID *uint, limit, offset int) ([]mo
 	return productResponses, nil
 }
 
-func (ps *ProductService) GetProductByID(productID uint) (*models.ProductResponse, error) {
+// GetProductByID returns a product with its variants priced in currency ("" for the base currency)
+func (ps *ProductService) GetProductByID(productID uint, currency string) (*models.ProductResponse, error) {
 	var product models.Product
-	if err := database.DB.Preload("Category").First(&product, productID).Error; err != nil {
+	if err := database.DB.Preload("Category").Preload("Variants", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).First(&product, productID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("product not found")
 		}
 		return nil, errors.New("database error")
 	}
 
+	prices, err := loadPriceList(database.DB, ps.cfg, currency, []uint{product.ID})
+	if err != nil {
+		return nil, err
+	}
+
 	categoryResponse := &models.CategoryResponse{
 		ID:          product.Category.ID,
 		Name:        product.Category.Name,
@@ -104,25 +146,36 @@ func (ps *ProductService) GetProductByID(productID uint) (*models.ProductRespons
 		UpdatedAt:   product.Category.UpdatedAt,
 	}
 
-	return &models.ProductResponse{
+	response := &models.ProductResponse{
 		ID:          product.ID,
 		CategoryID:  product.CategoryID,
+		SellerID:    product.SellerID,
 		Title:       product.Title,
 		Description: product.Description,
 		Images:      []string(product.Images),
-		Price:       product.Price,
+		Price:       prices.Price(&product, nil),
+		Currency:    prices.currency,
 		Model:       product.Model,
 		ExtraInfo:   product.ExtraInfo,
 		Stock:       product.Stock,
 		CreatedAt:   product.CreatedAt,
 		UpdatedAt:   product.UpdatedAt,
 		Category:    categoryResponse,
-	}, nil
+	}
+
+	withVariants(response, &product, product.Variants, prices)
+	return response, nil
 }
 
-func (ps *ProductService) UpdateProduct(productID uint, req *models.ProductUpdateRequest) (*models.ProductResponse, error) {
+// UpdateProduct updates a product; sellers (sellerID set) can only update their own products
+func (ps *ProductService) UpdateProduct(productID uint, sellerID *uint, req *models.ProductUpdateRequest) (*models.ProductResponse, error) {
+	query := database.DB.Where("id = ?", productID)
+	if sellerID != nil {
+		query = query.Where("seller_id = ?", *sellerID)
+	}
+
 	var product models.Product
-	if err := database.DB.First(&product, productID).Error; err != nil {
+	if err := query.First(&product).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("product not found")
 		}
@@ -171,10 +224,12 @@ func (ps *ProductService) UpdateProduct(productID uint, req *models.ProductUpdat
 	return &models.ProductResponse{
 		ID:          product.ID,
 		CategoryID:  product.CategoryID,
+		SellerID:    product.SellerID,
 		Title:       product.Title,
 		Description: product.Description,
 		Images:      []string(product.Images),
 		Price:       product.Price,
+		Currency:    ps.cfg.Currency.Base,
 		Model:       product.Model,
 		ExtraInfo:   product.ExtraInfo,
 		Stock:       product.Stock,
@@ -230,6 +285,18 @@ func (ps *ProductService) UpdateStock(productID uint, quantity int) error {
 	return nil
 }
 
+// checkSeller verifies that a user exists and one of their roles may sell products
+func checkSeller(userID uint) error {
+	access, err := database.LoadUserAccess(userID)
+	if err != nil {
+		return errors.New("database error")
+	}
+	if !access.Has(models.PermissionProductsWrite) {
+		return errors.New("seller not found")
+	}
+	return nil
+}
+
 // SearchProducts searches products with filters and sorting
 func (ps *ProductService) SearchProducts(req *models.ProductSearchRequest) ([]models.ProductResponse, int64, error) {
 	// Set default values
@@ -239,26 +306,13 @@ func (ps *ProductService) SearchProducts(req *models.ProductSearchRequest) ([]mo
 	if req.Offset < 0 {
 		req.Offset = 0
 	}
-
-	// Build query
-	query := database.DB.Model(&models.Product{}).Preload("Category")
-
-	// Apply filters
-	if req.Title != "" {
-		query = query.Where("title ILIKE ?", "%"+req.Title+"%")
-	}
-
-	if req.CategoryID != nil {
-		query = query.Where("category_id = ?", *req.CategoryID)
-	}
-
-	if req.MinPrice != nil {
-		query = query.Where("price >= ?", *req.MinPrice)
+	if req.Query == "" {
+		req.Query = req.Title
 	}
 
-	if req.MaxPrice != nil {
-		query = query.Where("price <= ?", *req.MaxPrice)
-	}
+	// Build query
+	tsQuery := productTSQuery(req.Query)
+	query := applySearchFilters(database.DB.Model(&models.Product{}).Preload("Category"), req, tsQuery, "")
 
 	// Apply sorting
 	switch req.SortBy {
@@ -275,9 +329,13 @@ func (ps *ProductService) SearchProducts(req *models.ProductSearchRequest) ([]mo
 	case "created_at_desc":
 		query = query.Order("created_at DESC")
 	default:
-		// Default sorting by relevance (title match + popularity)
-		if req.Title != "" {
-			query = query.Order("order_count DESC, title ASC")
+		// Relevance (text rank, then popularity) when there is a query, popularity otherwise
+		if tsQuery != "" {
+			query = query.Clauses(clause.OrderBy{Expression: clause.Expr{
+				SQL:                "ts_rank(search_vector, to_tsquery(?, ?)) DESC, order_count DESC, id ASC",
+				Vars:               []interface{}{database.ProductSearchConfig, tsQuery},
+				WithoutParentheses: true,
+			}})
 		} else {
 			query = query.Order("order_count DESC, created_at DESC")
 		}
@@ -295,22 +353,39 @@ func (ps *ProductService) SearchProducts(req *models.ProductSearchRequest) ([]mo
 		return nil, 0, errors.New("failed to search products")
 	}
 
+	prices, err := loadPriceList(database.DB, ps.cfg, req.Currency, productIDs(products))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Snippets are only built for the returned page
+	var highlights map[uint]*models.ProductHighlight
+	if tsQuery != "" && len(products) > 0 {
+		var err error
+		if highlights, err = productHighlights(products, tsQuery); err != nil {
+			return nil, 0, err
+		}
+	}
+
 	// Convert to response format
 	var responses []models.ProductResponse
 	for _, product := range products {
 		response := models.ProductResponse{
 			ID:          product.ID,
 			CategoryID:  product.CategoryID,
+			SellerID:    product.SellerID,
 			Title:       product.Title,
 			Description: product.Description,
 			Images:      []string(product.Images),
-			Price:       product.Price,
+			Price:       prices.Price(&product, nil),
+			Currency:    prices.currency,
 			Model:       product.Model,
 			ExtraInfo:   product.ExtraInfo,
 			Stock:       product.Stock,
 			OrderCount:  product.OrderCount,
 			CreatedAt:   product.CreatedAt,
 			UpdatedAt:   product.UpdatedAt,
+			Highlight:   highlights[product.ID],
 		}
 
 		if product.Category != nil {
@@ -329,6 +404,95 @@ func (ps *ProductService) SearchProducts(req *models.ProductSearchRequest) ([]mo
 	return responses, total, nil
 }
 
+// applySearchFilters applies the search filters of req, except the one named by skip
+// ("category", "price" or "attr.<name>") which is left out when counting its facet
+func applySearchFilters(query *gorm.DB, req *models.ProductSearchRequest, tsQuery, skip string) *gorm.DB {
+	if tsQuery != "" {
+		query = query.Where("products.search_vector @@ to_tsquery(?, ?)", database.ProductSearchConfig, tsQuery)
+	}
+
+	if req.CategoryID != nil && skip != "category" {
+		query = query.Where("products.category_id = ?", *req.CategoryID)
+	}
+
+	if skip != "price" {
+		if req.MinPrice != nil {
+			query = query.Where("products.price >= ?", models.MoneyFromFloat(*req.MinPrice))
+		}
+		if req.MaxPrice != nil {
+			query = query.Where("products.price <= ?", models.MoneyFromFloat(*req.MaxPrice))
+		}
+	}
+
+	for name, values := range req.Attributes {
+		if skip != "attr."+name {
+			query = query.Where("products.extra_info ->> ? IN ?", name, values)
+		}
+	}
+
+	return query
+}
+
+// productTSQuery turns user input into a prefix tsquery ("red sho" -> "red:* & sho:*").
+// Only letters and digits are kept, so the result is always valid to_tsquery syntax.
+func productTSQuery(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) > maxSearchTerms {
+		words = words[:maxSearchTerms]
+	}
+
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, word+":*")
+	}
+	return strings.Join(terms, " & ")
+}
+
+// maxSearchTerms bounds the size of the generated tsquery
+const maxSearchTerms = 16
+
+const productHeadlineOptions = "StartSel=<mark>, StopSel=</mark>"
+
+// productHighlights builds title/description snippets and the text rank of the given products
+func productHighlights(products []models.Product, tsQuery string) (map[uint]*models.ProductHighlight, error) {
+	ids := make([]uint, 0, len(products))
+	for _, product := range products {
+		ids = append(ids, product.ID)
+	}
+
+	var rows []struct {
+		ID          uint
+		Title       string
+		Description string
+		Rank        float64
+	}
+	if err := database.DB.Raw(`
+		SELECT p.id,
+			ts_headline(?, p.title, q.query, ?) AS title,
+			ts_headline(?, coalesce(p.description, ''), q.query, ?) AS description,
+			ts_rank(p.search_vector, q.query) AS rank
+		FROM products p, to_tsquery(?, ?) AS q(query)
+		WHERE p.id IN ?`,
+		database.ProductSearchConfig, productHeadlineOptions+", HighlightAll=true",
+		database.ProductSearchConfig, productHeadlineOptions+", MinWords=15, MaxWords=35",
+		database.ProductSearchConfig, tsQuery, ids,
+	).Scan(&rows).Error; err != nil {
+		return nil, errors.New("failed to highlight search results")
+	}
+
+	highlights := make(map[uint]*models.ProductHighlight, len(rows))
+	for _, row := range rows {
+		highlights[row.ID] = &models.ProductHighlight{
+			Title:       row.Title,
+			Description: row.Description,
+			Rank:        row.Rank,
+		}
+	}
+	return highlights, nil
+}
+
 // LogSearch logs search queries for analytics
 func (ps *ProductService) LogSearch(userID *uint, query string, filters models.JSONB, results int) error {
 	searchLog := models.SearchLog{
@@ -344,3 +508,11 @@ func (ps *ProductService) LogSearch(userID *uint, query string, filters models.J
 
 	return nil
 }
+
+func productIDs(products []models.Product) []uint {
+	ids := make([]uint, 0, len(products))
+	for _, product := range products {
+		ids = append(ids, product.ID)
+	}
+	return ids
+}
@@ -0,0 +1,498 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"go-shop/database"
+	"go-shop/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PromotionService struct{}
+
+func NewPromotionService() *PromotionService {
+	return &PromotionService{}
+}
+
+// CreatePromotion creates a coupon, or an automatic promotion when no code is given
+func (ps *PromotionService) CreatePromotion(req *models.PromotionRequest) (*models.PromotionResponse, error) {
+	var promotion models.Promotion
+	if err := fillPromotion(&promotion, req); err != nil {
+		return nil, err
+	}
+
+	if err := database.DB.Create(&promotion).Error; err != nil {
+		return nil, errors.New("failed to create promotion")
+	}
+
+	return toPromotionResponse(&promotion, 0), nil
+}
+
+// GetPromotions returns promotions, newest first
+func (ps *PromotionService) GetPromotions(limit, offset int) ([]models.PromotionResponse, error) {
+	var promotions []models.Promotion
+	if err := database.DB.Preload("Products").Preload("Categories").
+		Order("created_at DESC").Limit(limit).Offset(offset).Find(&promotions).Error; err != nil {
+		return nil, errors.New("failed to get promotions")
+	}
+
+	responses := []models.PromotionResponse{}
+	for i := range promotions {
+		used, err := promotionUsage(database.DB, promotions[i].ID, nil)
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, *toPromotionResponse(&promotions[i], used))
+	}
+	return responses, nil
+}
+
+func (ps *PromotionService) GetPromotionByID(promotionID uint) (*models.PromotionResponse, error) {
+	promotion, err := findPromotion(promotionID)
+	if err != nil {
+		return nil, err
+	}
+
+	used, err := promotionUsage(database.DB, promotion.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+	return toPromotionResponse(promotion, used), nil
+}
+
+// UpdatePromotion replaces all settings of a promotion; orders already placed keep their discounts
+func (ps *PromotionService) UpdatePromotion(promotionID uint, req *models.PromotionRequest) (*models.PromotionResponse, error) {
+	promotion, err := findPromotion(promotionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := fillPromotion(promotion, req); err != nil {
+		return nil, err
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(promotion).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(promotion).Association("Products").Replace(promotion.Products); err != nil {
+			return err
+		}
+		return tx.Model(promotion).Association("Categories").Replace(promotion.Categories)
+	})
+	if err != nil {
+		return nil, errors.New("failed to update promotion")
+	}
+
+	used, err := promotionUsage(database.DB, promotion.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+	return toPromotionResponse(promotion, used), nil
+}
+
+// DeletePromotion removes a promotion; orders already placed keep their discounts
+func (ps *PromotionService) DeletePromotion(promotionID uint) error {
+	promotion, err := findPromotion(promotionID)
+	if err != nil {
+		return err
+	}
+
+	if err := database.DB.Delete(promotion).Error; err != nil {
+		return errors.New("failed to delete promotion")
+	}
+	return nil
+}
+
+func findPromotion(promotionID uint) (*models.Promotion, error) {
+	var promotion models.Promotion
+	if err := database.DB.Preload("Products").Preload("Categories").First(&promotion, promotionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("promotion not found")
+		}
+		return nil, errors.New("database error")
+	}
+	return &promotion, nil
+}
+
+// fillPromotion validates a request and copies it onto the promotion
+func fillPromotion(promotion *models.Promotion, req *models.PromotionRequest) error {
+	switch req.Type {
+	case models.PromotionTypePercent:
+		if req.Value <= 0 || req.Value > 100 {
+			return errors.New("percent discount must be between 0 and 100")
+		}
+	case models.PromotionTypeFixed:
+		if req.Amount <= 0 {
+			return errors.New("fixed discount must be greater than 0")
+		}
+		req.Value = 0
+	case models.PromotionTypeBuyXGetY:
+		if req.BuyQuantity < 1 || req.GetQuantity < 1 {
+			return errors.New("buy_x_get_y needs buy_quantity and get_quantity of at least 1")
+		}
+		if req.Value == 0 {
+			req.Value = 100
+		}
+		if req.Value > 100 {
+			return errors.New("discount of the free units must be between 0 and 100 percent")
+		}
+	}
+	if req.Type != models.PromotionTypeFixed {
+		req.Amount = 0
+	}
+	if req.StartsAt != nil && req.EndsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+		return errors.New("ends_at must be after starts_at")
+	}
+
+	var code *string
+	if normalized := normalizeCouponCode(req.Code); normalized != "" {
+		// Codes of deleted promotions stay reserved by the unique index
+		var count int64
+		if err := database.DB.Unscoped().Model(&models.Promotion{}).
+			Where("code = ? AND id <> ?", normalized, promotion.ID).Count(&count).Error; err != nil {
+			return errors.New("database error")
+		}
+		if count > 0 {
+			return errors.New("coupon code already exists")
+		}
+		code = &normalized
+	}
+
+	products := []models.Product{}
+	if len(req.ProductIDs) > 0 {
+		if err := database.DB.Where("id IN ?", req.ProductIDs).Find(&products).Error; err != nil {
+			return errors.New("database error")
+		}
+		if len(products) != len(uniqueIDs(req.ProductIDs)) {
+			return errors.New("product not found")
+		}
+	}
+
+	categories := []models.Category{}
+	if len(req.CategoryIDs) > 0 {
+		if err := database.DB.Where("id IN ?", req.CategoryIDs).Find(&categories).Error; err != nil {
+			return errors.New("database error")
+		}
+		if len(categories) != len(uniqueIDs(req.CategoryIDs)) {
+			return errors.New("category not found")
+		}
+	}
+
+	promotion.Name = req.Name
+	promotion.Code = code
+	promotion.Type = req.Type
+	promotion.Value = req.Value
+	promotion.Amount = req.Amount
+	promotion.MinSubtotal = req.MinSubtotal
+	promotion.BuyQuantity = req.BuyQuantity
+	promotion.GetQuantity = req.GetQuantity
+	promotion.UsageLimit = req.UsageLimit
+	promotion.PerUserLimit = req.PerUserLimit
+	promotion.StartsAt = req.StartsAt
+	promotion.EndsAt = req.EndsAt
+	promotion.IsActive = req.IsActive == nil || *req.IsActive
+	promotion.Products = products
+	promotion.Categories = categories
+	return nil
+}
+
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func uniqueIDs(ids []uint) map[uint]bool {
+	unique := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		unique[id] = true
+	}
+	return unique
+}
+
+func toPromotionResponse(promotion *models.Promotion, used int64) *models.PromotionResponse {
+	response := &models.PromotionResponse{
+		ID:           promotion.ID,
+		Name:         promotion.Name,
+		Automatic:    promotion.Code == nil,
+		Type:         promotion.Type,
+		Value:        promotion.Value,
+		Amount:       promotion.Amount,
+		MinSubtotal:  promotion.MinSubtotal,
+		BuyQuantity:  promotion.BuyQuantity,
+		GetQuantity:  promotion.GetQuantity,
+		ProductIDs:   []uint{},
+		CategoryIDs:  []uint{},
+		UsageLimit:   promotion.UsageLimit,
+		PerUserLimit: promotion.PerUserLimit,
+		UsedCount:    used,
+		StartsAt:     promotion.StartsAt,
+		EndsAt:       promotion.EndsAt,
+		IsActive:     promotion.IsActive,
+		CreatedAt:    promotion.CreatedAt,
+		UpdatedAt:    promotion.UpdatedAt,
+	}
+	if promotion.Code != nil {
+		response.Code = *promotion.Code
+	}
+	for _, product := range promotion.Products {
+		response.ProductIDs = append(response.ProductIDs, product.ID)
+	}
+	for _, category := range promotion.Categories {
+		response.CategoryIDs = append(response.CategoryIDs, category.ID)
+	}
+	return response
+}
+
+// promotionUsage counts the orders using a promotion, of one buyer when userID is set. Cancelled
+// (and expired) orders give their use back.
+func promotionUsage(db *gorm.DB, promotionID uint, userID *uint) (int64, error) {
+	query := db.Model(&models.OrderDiscount{}).
+		Joins("JOIN orders ON orders.id = order_discounts.order_id").
+		Where("order_discounts.promotion_id = ? AND orders.status <> ?", promotionID, models.OrderStatusCancelled)
+	if userID != nil {
+		query = query.Where("order_discounts.user_id = ?", *userID)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return 0, errors.New("failed to count promotion usage")
+	}
+	return count, nil
+}
+
+// discountLine is an order line as promotions see it
+type discountLine struct {
+	ProductID  uint
+	CategoryID *uint
+	Quantity   int
+	Amount     models.Money // Line amount less the discounts applied so far
+	Discount   models.Money // Discounts applied so far
+}
+
+// applyPromotions applies every automatic promotion the order qualifies for, then the coupon if one
+// is given, each to what the previous ones left. The discounts are added to the lines and returned
+// for the order. A coupon that does not apply fails the order; automatic promotions are skipped.
+// Promotions with usage limits are locked so concurrent orders cannot exceed them. Amounts of the
+// promotions are converted to the order currency with the price list.
+func applyPromotions(tx *gorm.DB, userID uint, couponCode string, lines []*discountLine, prices *priceList) ([]models.OrderDiscount, error) {
+	now := time.Now()
+	active := func(db *gorm.DB) *gorm.DB {
+		return db.Preload("Products").Preload("Categories").
+			Where("is_active = ? AND (starts_at IS NULL OR starts_at <= ?) AND (ends_at IS NULL OR ends_at > ?)", true, now, now)
+	}
+
+	var promotions []models.Promotion
+	if err := tx.Scopes(active).Where("code IS NULL").Order("id").Find(&promotions).Error; err != nil {
+		return nil, errors.New("failed to get promotions")
+	}
+
+	var coupon *models.Promotion
+	if code := normalizeCouponCode(couponCode); code != "" {
+		var promotion models.Promotion
+		if err := tx.Preload("Products").Preload("Categories").Where("code = ? AND is_active = ?", code, true).First(&promotion).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("invalid coupon code")
+			}
+			return nil, errors.New("database error")
+		}
+		if promotion.StartsAt != nil && now.Before(*promotion.StartsAt) {
+			return nil, errors.New("coupon is not valid yet")
+		}
+		if promotion.EndsAt != nil && !now.Before(*promotion.EndsAt) {
+			return nil, errors.New("coupon has expired")
+		}
+		coupon = &promotion
+		promotions = append(promotions, promotion)
+	}
+
+	if err := lockLimitedPromotions(tx, promotions); err != nil {
+		return nil, err
+	}
+
+	var discounts []models.OrderDiscount
+	for i := range promotions {
+		promotion := &promotions[i]
+		isCoupon := coupon != nil && promotion.ID == coupon.ID
+
+		amount, err := applyPromotion(tx, promotion, userID, lines, prices)
+		if err != nil {
+			if isCoupon {
+				return nil, err
+			}
+			continue
+		}
+
+		discount := models.OrderDiscount{
+			PromotionID: promotion.ID,
+			UserID:      userID,
+			Name:        promotion.Name,
+			Amount:      amount,
+		}
+		if promotion.Code != nil {
+			discount.Code = *promotion.Code
+		}
+		discounts = append(discounts, discount)
+	}
+	return discounts, nil
+}
+
+// lockLimitedPromotions locks the promotions with usage limits in ID order
+func lockLimitedPromotions(tx *gorm.DB, promotions []models.Promotion) error {
+	var ids []uint
+	for _, promotion := range promotions {
+		if promotion.UsageLimit > 0 || promotion.PerUserLimit > 0 {
+			ids = append(ids, promotion.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var locked []models.Promotion
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", ids).Order("id").Find(&locked).Error; err != nil {
+		return errors.New("failed to lock promotions")
+	}
+	return nil
+}
+
+// applyPromotion checks that a promotion applies to the lines and takes its discount off them
+func applyPromotion(tx *gorm.DB, promotion *models.Promotion, userID uint, lines []*discountLine, prices *priceList) (models.Money, error) {
+	var eligible []*discountLine
+	var eligibleAmount models.Money
+	for _, line := range lines {
+		if promotionCovers(promotion, line) && line.Amount > 0 {
+			eligible = append(eligible, line)
+			eligibleAmount += line.Amount
+		}
+	}
+	if len(eligible) == 0 {
+		return 0, errors.New("coupon does not apply to these items")
+	}
+	if minSubtotal := prices.Convert(promotion.MinSubtotal); eligibleAmount < minSubtotal {
+		return 0, fmt.Errorf("coupon requires at least %s %s of eligible items", minSubtotal, prices.currency)
+	}
+
+	if promotion.UsageLimit > 0 {
+		used, err := promotionUsage(tx, promotion.ID, nil)
+		if err != nil {
+			return 0, err
+		}
+		if used >= int64(promotion.UsageLimit) {
+			return 0, errors.New("coupon usage limit reached")
+		}
+	}
+	if promotion.PerUserLimit > 0 {
+		used, err := promotionUsage(tx, promotion.ID, &userID)
+		if err != nil {
+			return 0, err
+		}
+		if used >= int64(promotion.PerUserLimit) {
+			return 0, errors.New("you have already used this coupon")
+		}
+	}
+
+	shares := promotionShares(promotion, eligible, eligibleAmount, prices)
+	var total models.Money
+	for i, line := range eligible {
+		share := min(shares[i], line.Amount)
+		line.Amount -= share
+		line.Discount += share
+		total += share
+	}
+	if total <= 0 {
+		if promotion.Type == models.PromotionTypeBuyXGetY {
+			return 0, fmt.Errorf("coupon requires at least %d eligible items", promotion.BuyQuantity+promotion.GetQuantity)
+		}
+		return 0, errors.New("coupon does not apply to these items")
+	}
+	return total, nil
+}
+
+// promotionShares computes the discount on each eligible line, each rounded once to cents
+func promotionShares(promotion *models.Promotion, eligible []*discountLine, eligibleAmount models.Money, prices *priceList) []models.Money {
+	shares := make([]models.Money, len(eligible))
+	switch promotion.Type {
+	case models.PromotionTypePercent:
+		for i, line := range eligible {
+			shares[i] = line.Amount.Percent(promotion.Value)
+		}
+
+	case models.PromotionTypeFixed:
+		// Split proportionally; rounding leftovers go to the last line
+		discount := min(prices.Convert(promotion.Amount), eligibleAmount)
+		remaining := discount
+		for i, line := range eligible {
+			if i == len(eligible)-1 {
+				shares[i] = remaining
+				break
+			}
+			shares[i] = discount.Share(int64(line.Amount), int64(eligibleAmount))
+			remaining -= shares[i]
+		}
+
+	case models.PromotionTypeBuyXGetY:
+		// The cheapest units of every group of buy+get units are discounted
+		order := make([]int, len(eligible))
+		units := 0
+		for i, line := range eligible {
+			order[i] = i
+			units += line.Quantity
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			// Compare unit prices without dividing: amount_a / quantity_a < amount_b / quantity_b
+			lineA, lineB := eligible[order[a]], eligible[order[b]]
+			return int64(lineA.Amount)*int64(lineB.Quantity) < int64(lineB.Amount)*int64(lineA.Quantity)
+		})
+
+		free := units / (promotion.BuyQuantity + promotion.GetQuantity) * promotion.GetQuantity
+		percent := new(big.Rat).Quo(models.DecimalRat(promotion.Value), big.NewRat(100, 1))
+		for _, i := range order {
+			if free == 0 {
+				break
+			}
+			line := eligible[i]
+			discounted := min(free, line.Quantity)
+			free -= discounted
+
+			// discounted/quantity of the line at percent off
+			shares[i] = line.Amount.MulRat(new(big.Rat).Mul(big.NewRat(int64(discounted), int64(line.Quantity)), percent))
+		}
+	}
+	return shares
+}
+
+// promotionCovers reports whether a line is in the promotion's product and category scope
+func promotionCovers(promotion *models.Promotion, line *discountLine) bool {
+	if len(promotion.Products) == 0 && len(promotion.Categories) == 0 {
+		return true
+	}
+	for _, product := range promotion.Products {
+		if product.ID == line.ProductID {
+			return true
+		}
+	}
+	if line.CategoryID != nil {
+		for _, category := range promotion.Categories {
+			if category.ID == *line.CategoryID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func toOrderDiscountResponse(discount *models.OrderDiscount) models.OrderDiscountResponse {
+	return models.OrderDiscountResponse{
+		PromotionID: discount.PromotionID,
+		Code:        discount.Code,
+		Name:        discount.Name,
+		Amount:      discount.Amount,
+	}
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"math/big"
+	"testing"
+
+	"go-shop/models"
+)
+
+func TestPromotionShares(t *testing.T) {
+	tests := []struct {
+		name      string
+		promotion models.Promotion
+		rate      *big.Rat // Exchange rate of the order currency, nil for the base currency
+		lines     []discountLine
+		want      []models.Money
+	}{
+		{
+			name:      "percent",
+			promotion: models.Promotion{Type: models.PromotionTypePercent, Value: 10},
+			lines:     []discountLine{{Quantity: 1, Amount: 10000}, {Quantity: 2, Amount: 5000}},
+			want:      []models.Money{1000, 500},
+		},
+		{
+			name:      "fixed split proportionally",
+			promotion: models.Promotion{Type: models.PromotionTypeFixed, Amount: 3000},
+			lines:     []discountLine{{Quantity: 1, Amount: 10000}, {Quantity: 1, Amount: 5000}},
+			want:      []models.Money{2000, 1000},
+		},
+		{
+			name:      "fixed converted to the order currency",
+			promotion: models.Promotion{Type: models.PromotionTypeFixed, Amount: 3000},
+			rate:      big.NewRat(1, 2),
+			lines:     []discountLine{{Quantity: 1, Amount: 5000}, {Quantity: 1, Amount: 2500}},
+			want:      []models.Money{1000, 500},
+		},
+		{
+			name:      "fixed rounding leftover goes to the last line",
+			promotion: models.Promotion{Type: models.PromotionTypeFixed, Amount: 1000},
+			lines:     []discountLine{{Quantity: 1, Amount: 1000}, {Quantity: 1, Amount: 1000}, {Quantity: 1, Amount: 1000}},
+			want:      []models.Money{333, 333, 334},
+		},
+		{
+			name:      "fixed above the eligible amount",
+			promotion: models.Promotion{Type: models.PromotionTypeFixed, Amount: 20000},
+			lines:     []discountLine{{Quantity: 1, Amount: 10000}, {Quantity: 1, Amount: 5000}},
+			want:      []models.Money{10000, 5000},
+		},
+		{
+			name:      "buy two get the cheapest free",
+			promotion: models.Promotion{Type: models.PromotionTypeBuyXGetY, Value: 100, BuyQuantity: 2, GetQuantity: 1},
+			lines:     []discountLine{{Quantity: 2, Amount: 4000}, {Quantity: 1, Amount: 1000}},
+			want:      []models.Money{0, 1000},
+		},
+		{
+			name:      "buy one get one half price",
+			promotion: models.Promotion{Type: models.PromotionTypeBuyXGetY, Value: 50, BuyQuantity: 1, GetQuantity: 1},
+			lines:     []discountLine{{Quantity: 4, Amount: 4000}},
+			want:      []models.Money{1000},
+		},
+		{
+			name:      "buy x get y with too few units",
+			promotion: models.Promotion{Type: models.PromotionTypeBuyXGetY, Value: 100, BuyQuantity: 2, GetQuantity: 1},
+			lines:     []discountLine{{Quantity: 2, Amount: 4000}},
+			want:      []models.Money{0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prices := &priceList{currency: "USD"}
+			if tt.rate != nil {
+				prices = &priceList{currency: "EUR", rate: tt.rate}
+			}
+
+			var eligible []*discountLine
+			var eligibleAmount models.Money
+			for i := range tt.lines {
+				eligible = append(eligible, &tt.lines[i])
+				eligibleAmount += tt.lines[i].Amount
+			}
+
+			shares := promotionShares(&tt.promotion, eligible, eligibleAmount, prices)
+			if len(shares) != len(tt.want) {
+				t.Fatalf("expected %d shares, got %v", len(tt.want), shares)
+			}
+			for i := range shares {
+				if shares[i] != tt.want[i] {
+					t.Fatalf("expected shares %v, got %v", tt.want, shares)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyPromotion(t *testing.T) {
+	category := uint(5)
+
+	tests := []struct {
+		name        string
+		promotion   models.Promotion
+		lines       []discountLine
+		wantErr     bool
+		wantTotal   models.Money
+		wantAmounts []models.Money
+	}{
+		{
+			name:        "whole order",
+			promotion:   models.Promotion{Type: models.PromotionTypePercent, Value: 10},
+			lines:       []discountLine{{ProductID: 1, Quantity: 1, Amount: 10000}, {ProductID: 2, Quantity: 1, Amount: 5000}},
+			wantTotal:   1500,
+			wantAmounts: []models.Money{9000, 4500},
+		},
+		{
+			name:        "scoped to a product",
+			promotion:   models.Promotion{Type: models.PromotionTypePercent, Value: 10, Products: []models.Product{{ID: 1}}},
+			lines:       []discountLine{{ProductID: 1, Quantity: 1, Amount: 10000}, {ProductID: 2, Quantity: 1, Amount: 5000}},
+			wantTotal:   1000,
+			wantAmounts: []models.Money{9000, 5000},
+		},
+		{
+			name:        "scoped to a category",
+			promotion:   models.Promotion{Type: models.PromotionTypeFixed, Amount: 2000, Categories: []models.Category{{ID: category}}},
+			lines:       []discountLine{{ProductID: 1, Quantity: 1, Amount: 10000}, {ProductID: 2, CategoryID: &category, Quantity: 1, Amount: 5000}},
+			wantTotal:   2000,
+			wantAmounts: []models.Money{10000, 3000},
+		},
+		{
+			name:        "fixed discount capped at the lines",
+			promotion:   models.Promotion{Type: models.PromotionTypeFixed, Amount: 8000},
+			lines:       []discountLine{{ProductID: 1, Quantity: 1, Amount: 3000}, {ProductID: 2, Quantity: 1, Amount: 2000}},
+			wantTotal:   5000,
+			wantAmounts: []models.Money{0, 0},
+		},
+		{
+			name:      "lines already fully discounted",
+			promotion: models.Promotion{Type: models.PromotionTypePercent, Value: 10},
+			lines:     []discountLine{{ProductID: 1, Quantity: 1, Amount: 0, Discount: 3000}},
+			wantErr:   true,
+		},
+		{
+			name:      "no line in scope",
+			promotion: models.Promotion{Type: models.PromotionTypePercent, Value: 10, Products: []models.Product{{ID: 3}}},
+			lines:     []discountLine{{ProductID: 1, Quantity: 1, Amount: 10000}},
+			wantErr:   true,
+		},
+		{
+			name:      "below the minimum subtotal",
+			promotion: models.Promotion{Type: models.PromotionTypePercent, Value: 10, MinSubtotal: 15000},
+			lines:     []discountLine{{ProductID: 1, Quantity: 1, Amount: 10000}},
+			wantErr:   true,
+		},
+		{
+			name:      "too few units for buy x get y",
+			promotion: models.Promotion{Type: models.PromotionTypeBuyXGetY, Value: 100, BuyQuantity: 2, GetQuantity: 1},
+			lines:     []discountLine{{ProductID: 1, Quantity: 2, Amount: 4000}},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []*discountLine
+			before := make([]models.Money, len(tt.lines))
+			for i := range tt.lines {
+				lines = append(lines, &tt.lines[i])
+				before[i] = tt.lines[i].Amount
+			}
+
+			// Promotions without usage limits do not touch the database
+			prices := &priceList{currency: "USD"}
+			total, err := applyPromotion(nil, &tt.promotion, 1, lines, prices)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got a discount of %s", total)
+				}
+				for i, line := range lines {
+					if line.Amount != before[i] {
+						t.Fatalf("expected a failed promotion to leave the lines unchanged, got %+v", *line)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if total != tt.wantTotal {
+				t.Fatalf("expected a discount of %s, got %s", tt.wantTotal, total)
+			}
+			for i, line := range lines {
+				if line.Amount != tt.wantAmounts[i] || line.Amount+line.Discount != before[i] {
+					t.Fatalf("expected line amounts %v with the rest as discount, got %+v", tt.wantAmounts, *line)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,370 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go-shop/config"
+	"go-shop/database"
+	"go-shop/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReturnService struct {
+	config         *config.Config
+	paymentService *PaymentService
+}
+
+func NewReturnService(cfg *config.Config, paymentService *PaymentService) *ReturnService {
+	return &ReturnService{
+		config:         cfg,
+		paymentService: paymentService,
+	}
+}
+
+// CreateReturn registers a buyer's return request for items of a delivered order
+func (rs *ReturnService) CreateReturn(orderID, userID uint, req *models.ReturnCreateRequest) (*models.ReturnResponse, error) {
+	// Start transaction
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var order models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("OrderItems").
+		Where("id = ? AND user_id = ?", orderID, userID).First(&order).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("order not found")
+		}
+		return nil, errors.New("database error")
+	}
+
+	// Only delivered orders can be returned
+	if order.Status != models.OrderStatusDelivered && order.Status != models.OrderStatusPartiallyRefunded {
+		tx.Rollback()
+		return nil, errors.New("only delivered orders can be returned")
+	}
+
+	deliveredAt := order.UpdatedAt
+	if order.DeliveredAt != nil {
+		deliveredAt = *order.DeliveredAt
+	}
+	if time.Now().After(deliveredAt.AddDate(0, 0, rs.config.Return.WindowDays)) {
+		tx.Rollback()
+		return nil, errors.New("return window has expired")
+	}
+
+	returned, err := returnedQuantities(tx, order.ID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	orderItems := make(map[uint]models.OrderItem, len(order.OrderItems))
+	for _, item := range order.OrderItems {
+		orderItems[item.ID] = item
+	}
+
+	// Merge duplicate lines of the request
+	requested := make(map[uint]int)
+	var orderItemIDs []uint
+	for _, item := range req.Items {
+		if _, ok := requested[item.OrderItemID]; !ok {
+			orderItemIDs = append(orderItemIDs, item.OrderItemID)
+		}
+		requested[item.OrderItemID] += item.Quantity
+	}
+
+	orderReturn := models.OrderReturn{
+		OrderID: order.ID,
+		UserID:  userID,
+		Status:  models.ReturnStatusRequested,
+		Reason:  req.Reason,
+	}
+
+	for _, orderItemID := range orderItemIDs {
+		orderItem, ok := orderItems[orderItemID]
+		if !ok {
+			tx.Rollback()
+			return nil, fmt.Errorf("order item %d not found in order", orderItemID)
+		}
+
+		quantity := requested[orderItemID]
+		if quantity > orderItem.Quantity-returned[orderItemID] {
+			tx.Rollback()
+			return nil, fmt.Errorf("cannot return more than %d units of order item %d", orderItem.Quantity-returned[orderItemID], orderItemID)
+		}
+
+		// Discounts on the line are refunded less and added tax with it, in proportion to the returned units
+		amount := orderItem.Total().Share(int64(quantity), int64(orderItem.Quantity))
+		orderReturn.RefundAmount += amount
+		orderReturn.Items = append(orderReturn.Items, models.ReturnItem{
+			OrderItemID: orderItem.ID,
+			ProductID:   orderItem.ProductID,
+			VariantID:   orderItem.VariantID,
+			Quantity:    quantity,
+			Amount:      amount,
+		})
+	}
+
+	if err := tx.Create(&orderReturn).Error; err != nil {
+		tx.Rollback()
+		return nil, errors.New("failed to create return")
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return nil, errors.New("failed to commit transaction")
+	}
+
+	return toReturnResponse(&orderReturn), nil
+}
+
+// GetOrderReturns returns the return requests of a user's order
+func (rs *ReturnService) GetOrderReturns(orderID, userID uint) ([]models.ReturnResponse, error) {
+	var returns []models.OrderReturn
+	if err := database.DB.Preload("Items").Where("order_id = ? AND user_id = ?", orderID, userID).
+		Order("created_at DESC").Find(&returns).Error; err != nil {
+		return nil, errors.New("failed to get returns")
+	}
+
+	var responses []models.ReturnResponse
+	for i := range returns {
+		responses = append(responses, *toReturnResponse(&returns[i]))
+	}
+	return responses, nil
+}
+
+// GetReturns returns return requests for review, optionally filtered by status (Admin only)
+func (rs *ReturnService) GetReturns(status string, limit, offset int) ([]models.ReturnResponse, error) {
+	query := database.DB.Preload("Items")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var returns []models.OrderReturn
+	if err := query.Limit(limit).Offset(offset).Order("created_at DESC").Find(&returns).Error; err != nil {
+		return nil, errors.New("failed to get returns")
+	}
+
+	var responses []models.ReturnResponse
+	for i := range returns {
+		responses = append(responses, *toReturnResponse(&returns[i]))
+	}
+	return responses, nil
+}
+
+// ApproveReturn restocks returned items and refunds the buyer (Admin only)
+func (rs *ReturnService) ApproveReturn(returnID, adminID uint, req *models.ReturnApproveRequest) (*models.ReturnResponse, error) {
+	// Start transaction
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	orderReturn, err := lockReturn(tx, returnID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	var order models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderReturn.OrderID).Error; err != nil {
+		tx.Rollback()
+		return nil, errors.New("order not found")
+	}
+
+	refundAmount := orderReturn.RefundAmount
+	if req.RefundAmount != nil {
+		if *req.RefundAmount > orderReturn.RefundAmount {
+			tx.Rollback()
+			return nil, fmt.Errorf("refund amount cannot exceed %s %s", orderReturn.RefundAmount, order.Currency)
+		}
+		refundAmount = *req.RefundAmount
+	}
+	// Shipping is not refunded on returns
+	if refundable := max(order.TotalAmount-order.ShippingCost-order.Refunded, 0); refundAmount > refundable {
+		refundAmount = refundable
+	}
+
+	// Put returned items back on the shelf
+	restock := make(map[stockKey]int)
+	for _, item := range orderReturn.Items {
+		restock[newStockKey(item.ProductID, item.VariantID)] += item.Quantity
+	}
+	for _, key := range sortedStockKeys(restock) {
+		if err := stockRow(tx, key.ProductID, key.variantID()).Update("stock", gorm.Expr("stock + ?", restock[key])).Error; err != nil {
+			tx.Rollback()
+			return nil, errors.New("failed to restock product")
+		}
+	}
+
+	var refunds []models.Refund
+	if refundAmount > 0 {
+		reason := fmt.Sprintf("return #%d", orderReturn.ID)
+		if refunds, err = rs.paymentService.refundOrder(tx, order.ID, refundAmount, &orderReturn.ID, reason); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		order.Refunded += refundAmount
+		status := models.OrderStatusPartiallyRefunded
+		if order.Refunded >= order.TotalAmount-order.ShippingCost {
+			status = models.OrderStatusRefunded
+		}
+		if order.Status == models.OrderStatusRefunded {
+			// Already refunded in full, e.g. by a manual refund; only the amount changes
+			if err := tx.Model(&order).Update("refunded", order.Refunded).Error; err != nil {
+				tx.Rollback()
+				return nil, errors.New("failed to update order")
+			}
+		} else {
+			admin := OrderActor{UserID: &adminID, Role: models.ROLE_SUPER_ADMIN}
+			if err := transitionOrder(tx, &order, status, admin, reason); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+	}
+
+	now := time.Now()
+	orderReturn.Status = models.ReturnStatusApproved
+	orderReturn.RefundAmount = refundAmount
+	orderReturn.AdminNote = req.Note
+	orderReturn.ReviewedBy = &adminID
+	orderReturn.ReviewedAt = &now
+
+	if err := tx.Omit("Items").Save(orderReturn).Error; err != nil {
+		tx.Rollback()
+		return nil, errors.New("failed to update return")
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return nil, errors.New("failed to commit transaction")
+	}
+
+	rs.paymentService.sendRefunds(refunds)
+
+	log.Printf("Return %d for order %d approved by user %d, refunded %s %s", orderReturn.ID, order.ID, adminID, refundAmount, order.Currency)
+
+	return toReturnResponse(orderReturn), nil
+}
+
+// RejectReturn declines a return request (Admin only)
+func (rs *ReturnService) RejectReturn(returnID, adminID uint, req *models.ReturnRejectRequest) (*models.ReturnResponse, error) {
+	// Start transaction
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	orderReturn, err := lockReturn(tx, returnID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	now := time.Now()
+	orderReturn.Status = models.ReturnStatusRejected
+	orderReturn.AdminNote = req.Note
+	orderReturn.ReviewedBy = &adminID
+	orderReturn.ReviewedAt = &now
+
+	if err := tx.Omit("Items").Save(orderReturn).Error; err != nil {
+		tx.Rollback()
+		return nil, errors.New("failed to update return")
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return nil, errors.New("failed to commit transaction")
+	}
+
+	return toReturnResponse(orderReturn), nil
+}
+
+// lockReturn loads a return request awaiting review with its items
+func lockReturn(tx *gorm.DB, returnID uint) (*models.OrderReturn, error) {
+	var orderReturn models.OrderReturn
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&orderReturn, returnID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("return not found")
+		}
+		return nil, errors.New("database error")
+	}
+
+	if orderReturn.Status != models.ReturnStatusRequested {
+		return nil, errors.New("return has already been reviewed")
+	}
+
+	if err := tx.Where("return_id = ?", orderReturn.ID).Find(&orderReturn.Items).Error; err != nil {
+		return nil, errors.New("failed to get return items")
+	}
+
+	return &orderReturn, nil
+}
+
+// returnedQuantities sums quantities per order item in pending and approved returns of an order
+func returnedQuantities(tx *gorm.DB, orderID uint) (map[uint]int, error) {
+	var rows []struct {
+		OrderItemID uint
+		Quantity    int
+	}
+	if err := tx.Model(&models.ReturnItem{}).
+		Select("return_items.order_item_id, SUM(return_items.quantity) AS quantity").
+		Joins("JOIN order_returns ON order_returns.id = return_items.return_id").
+		Where("order_returns.order_id = ? AND order_returns.status IN ?", orderID,
+			[]models.ReturnStatus{models.ReturnStatusRequested, models.ReturnStatusApproved}).
+		Group("return_items.order_item_id").
+		Scan(&rows).Error; err != nil {
+		return nil, errors.New("failed to get returned quantities")
+	}
+
+	returned := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		returned[row.OrderItemID] = row.Quantity
+	}
+	return returned, nil
+}
+
+func toReturnResponse(orderReturn *models.OrderReturn) *models.ReturnResponse {
+	response := &models.ReturnResponse{
+		ID:           orderReturn.ID,
+		OrderID:      orderReturn.OrderID,
+		UserID:       orderReturn.UserID,
+		Status:       orderReturn.Status,
+		Reason:       orderReturn.Reason,
+		AdminNote:    orderReturn.AdminNote,
+		RefundAmount: orderReturn.RefundAmount,
+		ReviewedBy:   orderReturn.ReviewedBy,
+		ReviewedAt:   orderReturn.ReviewedAt,
+		CreatedAt:    orderReturn.CreatedAt,
+		UpdatedAt:    orderReturn.UpdatedAt,
+		Items:        []models.ReturnItemResponse{},
+	}
+
+	for _, item := range orderReturn.Items {
+		response.Items = append(response.Items, models.ReturnItemResponse{
+			ID:          item.ID,
+			OrderItemID: item.OrderItemID,
+			ProductID:   item.ProductID,
+			VariantID:   item.VariantID,
+			Quantity:    item.Quantity,
+			Amount:      item.Amount,
+		})
+	}
+
+	return response
+}
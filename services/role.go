@@ -1,19 +1,27 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"log"
+	"slices"
+	"time"
 
+	"go-shop/config"
 	"go-shop/database"
 	"go-shop/models"
 
 	"gorm.io/gorm"
 )
 
-type RoleService struct{}
+type RoleService struct {
+	config *config.Config
+}
 
-func NewRoleService() *RoleService {
-	return &RoleService{}
+func NewRoleService(cfg *config.Config) *RoleService {
+	return &RoleService{
+		config: cfg,
+	}
 }
 
 // GetUserRole возвращает роль пользователя
@@ -29,23 +37,55 @@ func (rs *RoleService) GetUserRole(userID uint) (string, error) {
 	return userRole.Role.Name, nil
 }
 
-// AssignRole назначает роль пользователю (только для super_admin)
-func (rs *RoleService) AssignRole(userID uint, roleName string, assignedBy uint) error {
-	// Проверяем, что назначающий имеет роль super_admin
-	assignerRole, err := rs.GetUserRole(assignedBy)
+// userAccess returns the roles and permissions of a user through the permission cache
+func (rs *RoleService) userAccess(userID uint) (*database.UserAccess, error) {
+	ttl := time.Duration(rs.config.RBAC.PermissionCacheSeconds) * time.Second
+	return database.GetUserAccess(context.Background(), userID, ttl)
+}
+
+// requirePermission fails unless one of the user's roles grants the permission
+func (rs *RoleService) requirePermission(userID uint, permission string) error {
+	access, err := rs.userAccess(userID)
 	if err != nil {
-		return errors.New("failed to verify assigner role")
+		return errors.New("failed to verify permissions")
+	}
+	if !access.Has(permission) {
+		return errors.New("permission " + permission + " required")
+	}
+	return nil
+}
+
+// invalidatePermissions makes role changes apply to cached permissions at once
+func invalidatePermissions() {
+	if err := database.InvalidatePermissions(context.Background()); err != nil {
+		log.Printf("Warning: Failed to invalidate cached permissions: %v", err)
 	}
-	if assignerRole != models.ROLE_SUPER_ADMIN {
-		return errors.New("only super admin can assign roles")
+}
+
+// AssignRole назначает роль пользователю (требуется право roles.assign)
+func (rs *RoleService) AssignRole(userID uint, roleName string, assignedBy uint) error {
+	// Проверяем, что назначающий имеет право назначать роли
+	if err := rs.requirePermission(assignedBy, models.PermissionRolesAssign); err != nil {
+		return err
 	}
 
 	// Получаем роль из базы данных
 	var role models.Role
-	if err := database.DB.Where("name = ?", roleName).First(&role).Error; err != nil {
+	if err := database.DB.Preload("Permissions").Where("name = ?", roleName).First(&role).Error; err != nil {
 		return errors.New("role not found")
 	}
 
+	// Нельзя выдать больше прав, чем есть у самого назначающего
+	access, err := rs.userAccess(assignedBy)
+	if err != nil {
+		return errors.New("failed to verify permissions")
+	}
+	for _, permission := range role.Permissions {
+		if !access.Has(permission.Name) {
+			return errors.New("cannot assign a role with permission " + permission.Name + " you do not have")
+		}
+	}
+
 	// Проверяем, существует ли пользователь
 	var user models.User
 	if err := database.DB.First(&user, userID).Error; err != nil {
@@ -82,19 +122,21 @@ func (rs *RoleService) AssignRole(userID uint, roleName string, assignedBy uint)
 
 	// Логируем операцию
 	log.Printf("Role assigned: User %d assigned role %s by user %d", userID, roleName, assignedBy)
+	invalidatePermissions()
+
+	// Токены с прежней ролью больше не действуют
+	if err := revokeUserSessions(rs.config, userID); err != nil {
+		log.Printf("Warning: Failed to revoke sessions of user %d after role change: %v", userID, err)
+	}
 
 	return nil
 }
 
-// RemoveRole удаляет роль у пользователя (только для super_admin)
+// RemoveRole удаляет роль у пользователя (требуется право roles.assign)
 func (rs *RoleService) RemoveRole(userID uint, removedBy uint) error {
-	// Проверяем, что удаляющий имеет роль super_admin
-	removerRole, err := rs.GetUserRole(removedBy)
-	if err != nil {
-		return errors.New("failed to verify remover role")
-	}
-	if removerRole != models.ROLE_SUPER_ADMIN {
-		return errors.New("only super admin can remove roles")
+	// Проверяем, что удаляющий имеет право назначать роли
+	if err := rs.requirePermission(removedBy, models.PermissionRolesAssign); err != nil {
+		return err
 	}
 
 	// Проверяем, что пользователь не пытается удалить роль самому себе
@@ -136,6 +178,12 @@ func (rs *RoleService) RemoveRole(userID uint, removedBy uint) error {
 
 	// Логируем операцию
 	log.Printf("Role removed: User %d role removed by user %d", userID, removedBy)
+	invalidatePermissions()
+
+	// Токены с прежней ролью больше не действуют
+	if err := revokeUserSessions(rs.config, userID); err != nil {
+		log.Printf("Warning: Failed to revoke sessions of user %d after role change: %v", userID, err)
+	}
 
 	return nil
 }
@@ -149,27 +197,56 @@ func (rs *RoleService) GetUsersByRole(roleName string) ([]models.User, error) {
 	return users, err
 }
 
-// GetAllRoles возвращает все роли
+// GetAllRoles возвращает все роли с их правами
 func (rs *RoleService) GetAllRoles() ([]models.Role, error) {
 	var roles []models.Role
-	err := database.DB.Find(&roles).Error
+	err := database.DB.Preload("Permissions").Order("id").Find(&roles).Error
 	return roles, err
 }
 
-// CreateRole создает новую роль (только для super_admin)
+// GetAllPermissions возвращает все права, которые можно выдать ролям
+func (rs *RoleService) GetAllPermissions() ([]models.Permission, error) {
+	var permissions []models.Permission
+	err := database.DB.Order("name").Find(&permissions).Error
+	return permissions, err
+}
+
+// GetUserPermissions возвращает права пользователя, выданные всеми его ролями
+func (rs *RoleService) GetUserPermissions(userID uint) ([]string, error) {
+	access, err := rs.userAccess(userID)
+	if err != nil {
+		return nil, err
+	}
+	return access.Permissions, nil
+}
+
+// CreateRole создает новую роль с правами (требуется право roles.manage)
 func (rs *RoleService) CreateRole(req *models.RoleCreateRequest, createdBy uint) (*models.Role, error) {
-	// Проверяем, что создающий имеет роль super_admin
-	creatorRole, err := rs.GetUserRole(createdBy)
+	// Проверяем, что создающий имеет право управлять ролями
+	if err := rs.requirePermission(createdBy, models.PermissionRolesManage); err != nil {
+		return nil, err
+	}
+
+	permissions, err := findPermissions(req.Permissions)
 	if err != nil {
-		return nil, errors.New("failed to verify creator role")
+		return nil, err
 	}
-	if creatorRole != models.ROLE_SUPER_ADMIN {
-		return nil, errors.New("only super admin can create roles")
+
+	// Нельзя выдать роли больше прав, чем есть у самого создающего
+	access, err := rs.userAccess(createdBy)
+	if err != nil {
+		return nil, errors.New("failed to verify permissions")
+	}
+	for _, name := range req.Permissions {
+		if !access.Has(name) {
+			return nil, errors.New("cannot grant permission " + name + " you do not have")
+		}
 	}
 
 	role := models.Role{
 		Name:        req.Name,
 		Description: req.Description,
+		Permissions: permissions,
 	}
 
 	if err := database.DB.Create(&role).Error; err != nil {
@@ -177,28 +254,99 @@ func (rs *RoleService) CreateRole(req *models.RoleCreateRequest, createdBy uint)
 	}
 
 	// Логируем операцию
-	log.Printf("Role created: Role %s created by user %d", req.Name, createdBy)
+	log.Printf("Role created: Role %s created by user %d with permissions %v", req.Name, createdBy, req.Permissions)
 
 	return &role, nil
 }
 
-// HasRole проверяет, имеет ли пользователь определенную роль
-func (rs *RoleService) HasRole(userID uint, roleName string) bool {
-	userRole, err := rs.GetUserRole(userID)
+// SetRolePermissions заменяет права роли (требуется право roles.manage)
+func (rs *RoleService) SetRolePermissions(roleID uint, names []string, updatedBy uint) (*models.Role, error) {
+	if err := rs.requirePermission(updatedBy, models.PermissionRolesManage); err != nil {
+		return nil, err
+	}
+
+	var role models.Role
+	if err := database.DB.First(&role, roleID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("role not found")
+		}
+		return nil, errors.New("database error")
+	}
+
+	permissions, err := findPermissions(names)
 	if err != nil {
-		return false
+		return nil, err
 	}
-	return userRole == roleName
+
+	access, err := rs.userAccess(updatedBy)
+	if err != nil {
+		return nil, errors.New("failed to verify permissions")
+	}
+	if err := checkRolePermissionChange(&role, names, access); err != nil {
+		return nil, err
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if len(permissions) == 0 {
+			return tx.Model(&role).Association("Permissions").Clear()
+		}
+		return tx.Model(&role).Association("Permissions").Replace(permissions)
+	})
+	if err != nil {
+		return nil, errors.New("failed to update role permissions")
+	}
+
+	log.Printf("Role permissions updated: Role %s given %v by user %d", role.Name, names, updatedBy)
+	invalidatePermissions()
+
+	if err := database.DB.Preload("Permissions").First(&role, role.ID).Error; err != nil {
+		return nil, errors.New("failed to load role")
+	}
+	return &role, nil
 }
 
-// IsSuperAdmin проверяет, является ли пользователь супер-админом
-func (rs *RoleService) IsSuperAdmin(userID uint) bool {
-	return rs.HasRole(userID, models.ROLE_SUPER_ADMIN)
+// checkRolePermissionChange fails unless a user with the given access may set the permissions of the role
+func checkRolePermissionChange(role *models.Role, names []string, access *database.UserAccess) error {
+	// Права супер-админа не меняются, иначе систему может стать некому администрировать
+	if role.Name == models.ROLE_SUPER_ADMIN {
+		return errors.New("permissions of the " + models.ROLE_SUPER_ADMIN + " role cannot be changed")
+	}
+
+	// Нельзя менять права своей роли: ни расширить их себе, ни лишить себя доступа
+	if slices.Contains(access.Roles, role.Name) {
+		return errors.New("cannot change permissions of your own role")
+	}
+
+	// Нельзя выдать роли больше прав, чем есть у самого изменяющего
+	for _, name := range names {
+		if !access.Has(name) {
+			return errors.New("cannot grant permission " + name + " you do not have")
+		}
+	}
+	return nil
+}
+
+// findPermissions loads permissions by name and fails on an unknown name
+func findPermissions(names []string) ([]models.Permission, error) {
+	permissions := []models.Permission{}
+	if len(names) == 0 {
+		return permissions, nil
+	}
+
+	if err := database.DB.Where("name IN ?", names).Find(&permissions).Error; err != nil {
+		return nil, errors.New("database error")
+	}
+	for _, name := range names {
+		if !slices.ContainsFunc(permissions, func(p models.Permission) bool { return p.Name == name }) {
+			return nil, errors.New("unknown permission: " + name)
+		}
+	}
+	return permissions, nil
 }
 
-// IsSeller проверяет, является ли пользователь продавцом
-func (rs *RoleService) IsSeller(userID uint) bool {
-	return rs.HasRole(userID, models.ROLE_SELLER)
+// HasPermission проверяет, дает ли одна из ролей пользователя указанное право
+func (rs *RoleService) HasPermission(userID uint, permission string) bool {
+	return rs.requirePermission(userID, permission) == nil
 }
 
 // GetAllUsersWithRoles возвращает всех пользователей с их ролями
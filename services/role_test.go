@@ -0,0 +1,49 @@
+package services
+
+import (
+	"testing"
+
+	"go-shop/database"
+	"go-shop/models"
+)
+
+func TestCheckRolePermissionChange(t *testing.T) {
+	superAdmin := &database.UserAccess{
+		Roles:       []string{models.ROLE_SUPER_ADMIN},
+		Permissions: []string{models.PermissionRolesManage, models.PermissionOrdersShip, models.PermissionOrdersManage},
+	}
+	roleManager := &database.UserAccess{
+		Roles:       []string{"role_manager"},
+		Permissions: []string{models.PermissionRolesManage, models.PermissionOrdersShip},
+	}
+
+	tests := []struct {
+		name    string
+		role    string
+		names   []string
+		access  *database.UserAccess
+		wantErr bool
+	}{
+		{"grant held permission to another role", "support", []string{models.PermissionOrdersShip}, roleManager, false},
+		{"empty another role", "support", nil, roleManager, false},
+		{"grant permission not held", "support", []string{models.PermissionOrdersManage}, roleManager, true},
+		{"change own role", "role_manager", []string{models.PermissionRolesManage, models.PermissionOrdersShip}, roleManager, true},
+		{"empty own role", "role_manager", nil, roleManager, true},
+		{"super admin changes seller role", models.ROLE_SELLER, []string{models.PermissionOrdersShip}, superAdmin, false},
+		{"super admin empties super admin role", models.ROLE_SUPER_ADMIN, nil, superAdmin, true},
+		{"super admin changes super admin role", models.ROLE_SUPER_ADMIN, []string{models.PermissionRolesManage}, superAdmin, true},
+		{"custom role changes super admin role", models.ROLE_SUPER_ADMIN, []string{models.PermissionRolesManage}, roleManager, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRolePermissionChange(&models.Role{Name: tt.role}, tt.names, tt.access)
+			if tt.wantErr && err == nil {
+				t.Fatal("expected the change to be refused")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
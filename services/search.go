@@ -0,0 +1,269 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go-shop/database"
+	"go-shop/models"
+
+	"gorm.io/gorm"
+)
+
+// maxFacetValues bounds the number of values returned per attribute facet
+const maxFacetValues = 50
+
+// SearchFacets counts search results per category, price bucket and ExtraInfo attribute value
+func (ps *ProductService) SearchFacets(req *models.ProductSearchRequest) (*models.SearchFacets, error) {
+	if req.Query == "" {
+		req.Query = req.Title
+	}
+	tsQuery := productTSQuery(req.Query)
+
+	facets := &models.SearchFacets{
+		Categories: []models.CategoryFacet{},
+		Prices:     []models.PriceFacet{},
+		Attributes: make(map[string][]models.FacetValue),
+		Currency:   ps.cfg.Currency.Base,
+	}
+
+	if err := ps.categoryFacets(req, tsQuery, facets); err != nil {
+		return nil, err
+	}
+	if err := ps.priceFacets(req, tsQuery, facets); err != nil {
+		return nil, err
+	}
+
+	// Attributes without a filter share one query; each filtered attribute is counted without its own filter
+	if err := ps.attributeFacets(req, tsQuery, "", facets); err != nil {
+		return nil, err
+	}
+	for name := range req.Attributes {
+		if err := ps.attributeFacets(req, tsQuery, name, facets); err != nil {
+			return nil, err
+		}
+	}
+
+	return facets, nil
+}
+
+func (ps *ProductService) categoryFacets(req *models.ProductSearchRequest, tsQuery string, facets *models.SearchFacets) error {
+	query := database.DB.Model(&models.Product{}).
+		Select("categories.id AS id, categories.name AS name, COUNT(*) AS count").
+		Joins("JOIN categories ON categories.id = products.category_id AND categories.deleted_at IS NULL")
+	query = applySearchFilters(query, req, tsQuery, "category")
+
+	if err := query.Group("categories.id, categories.name").
+		Order("count DESC, categories.name ASC").
+		Scan(&facets.Categories).Error; err != nil {
+		return errors.New("failed to count category facets")
+	}
+	return nil
+}
+
+func (ps *ProductService) priceFacets(req *models.ProductSearchRequest, tsQuery string, facets *models.SearchFacets) error {
+	if len(ps.cfg.Search.PriceBuckets) == 0 {
+		return nil
+	}
+
+	// Bounds come from configuration and are formatted as integers, so they are safe to inline
+	bounds := make([]models.Money, len(ps.cfg.Search.PriceBuckets))
+	literals := make([]string, len(bounds))
+	for i, bound := range ps.cfg.Search.PriceBuckets {
+		bounds[i] = models.MoneyFromFloat(bound)
+		literals[i] = strconv.FormatInt(int64(bounds[i]), 10)
+	}
+	bucket := "width_bucket(products.price, ARRAY[" + strings.Join(literals, ",") + "]::bigint[])"
+
+	var rows []struct {
+		Bucket int
+		Count  int64
+	}
+	query := database.DB.Model(&models.Product{}).Select(bucket + " AS bucket, COUNT(*) AS count")
+	query = applySearchFilters(query, req, tsQuery, "price")
+	if err := query.Group("bucket").Order("bucket ASC").Scan(&rows).Error; err != nil {
+		return errors.New("failed to count price facets")
+	}
+
+	// Bucket 0 is below the first bound, bucket len(bounds) is at or above the last one
+	for _, row := range rows {
+		facet := models.PriceFacet{Count: row.Count}
+		if row.Bucket > 0 {
+			facet.Min = &bounds[row.Bucket-1]
+		}
+		if row.Bucket < len(bounds) {
+			facet.Max = &bounds[row.Bucket]
+		}
+		facets.Prices = append(facets.Prices, facet)
+	}
+	return nil
+}
+
+// attributeFacets counts values of one filtered attribute (name set) or of every unfiltered attribute
+func (ps *ProductService) attributeFacets(req *models.ProductSearchRequest, tsQuery, name string, facets *models.SearchFacets) error {
+	var rows []struct {
+		Name  string
+		Value string
+		Count int64
+	}
+
+	// Only scalar values are facets; nested objects and arrays are skipped
+	query := database.DB.Model(&models.Product{}).
+		Select("attr.key AS name, attr.value #>> '{}' AS value, COUNT(*) AS count").
+		Joins("CROSS JOIN LATERAL jsonb_each(CASE WHEN jsonb_typeof(products.extra_info) = 'object' THEN products.extra_info ELSE '{}'::jsonb END) AS attr").
+		Where("jsonb_typeof(attr.value) IN ('string', 'number', 'boolean')")
+
+	skip := ""
+	if name != "" {
+		skip = "attr." + name
+		query = query.Where("attr.key = ?", name)
+	} else if len(req.Attributes) > 0 {
+		filtered := make([]string, 0, len(req.Attributes))
+		for attribute := range req.Attributes {
+			filtered = append(filtered, attribute)
+		}
+		query = query.Where("attr.key NOT IN ?", filtered)
+	}
+	query = applySearchFilters(query, req, tsQuery, skip)
+
+	if err := query.Group("attr.key, attr.value #>> '{}'").Scan(&rows).Error; err != nil {
+		return errors.New("failed to count attribute facets")
+	}
+
+	values := make(map[string][]models.FacetValue)
+	for _, row := range rows {
+		values[row.Name] = append(values[row.Name], models.FacetValue{Value: row.Value, Count: row.Count})
+	}
+	for attribute, list := range values {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Count != list[j].Count {
+				return list[i].Count > list[j].Count
+			}
+			return list[i].Value < list[j].Value
+		})
+		if len(list) > maxFacetValues {
+			list = list[:maxFacetValues]
+		}
+		facets.Attributes[attribute] = list
+	}
+
+	// A filtered attribute keeps its facet even when nothing else matches
+	if name != "" && facets.Attributes[name] == nil {
+		facets.Attributes[name] = []models.FacetValue{}
+	}
+	return nil
+}
+
+// maxSuggestions bounds "did you mean" corrections of a query
+const maxSuggestions = 5
+
+// didYouMeanThreshold is the minimum pg_trgm word similarity of a correction.
+// It is below the extension default (0.6) so that transposed letters ("iphnoe") still match.
+const didYouMeanThreshold = "0.3"
+
+// Suggest returns product title and category completions of a partially typed query, most ordered first
+func (ps *ProductService) Suggest(req *models.SearchSuggestRequest) (*models.SearchSuggestions, error) {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 8
+	}
+	pattern := "%" + escapeLike(strings.ToLower(strings.TrimSpace(req.Query))) + "%"
+
+	suggestions := &models.SearchSuggestions{
+		Products:   []models.ProductSuggestion{},
+		Categories: []models.CategorySuggestion{},
+	}
+
+	if err := database.DB.Model(&models.Product{}).
+		Select("id, title, category_id, order_count").
+		Where("lower(title) LIKE ?", pattern).
+		Order("order_count DESC, title ASC").
+		Limit(limit).
+		Scan(&suggestions.Products).Error; err != nil {
+		return nil, errors.New("failed to get product suggestions")
+	}
+
+	if err := database.DB.Model(&models.Category{}).
+		Select("categories.id, categories.name, COALESCE(SUM(products.order_count), 0) AS order_count").
+		Joins("LEFT JOIN products ON products.category_id = categories.id AND products.deleted_at IS NULL").
+		Where("lower(categories.name) LIKE ?", pattern).
+		Group("categories.id, categories.name").
+		Order("order_count DESC, categories.name ASC").
+		Limit(limit).
+		Scan(&suggestions.Categories).Error; err != nil {
+		return nil, errors.New("failed to get category suggestions")
+	}
+
+	return suggestions, nil
+}
+
+// DidYouMean suggests corrections for a query that found no products, using trigram similarity
+// against past queries that had results and product titles
+func (ps *ProductService) DidYouMean(query string) ([]models.SpellingSuggestion, error) {
+	text := strings.ToLower(strings.TrimSpace(query))
+	if text == "" {
+		return nil, nil
+	}
+
+	var candidates []struct {
+		Text       string
+		Source     string
+		Similarity float64
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Lets the <% operator use the trigram indexes with our threshold
+		if err := tx.Exec("SELECT set_config('pg_trgm.word_similarity_threshold', ?, true)", didYouMeanThreshold).Error; err != nil {
+			return err
+		}
+
+		return tx.Raw(`
+			SELECT text, source, similarity FROM (
+				SELECT lower(btrim(l.query)) AS text, 'query' AS source,
+					word_similarity(?, lower(btrim(l.query))) AS similarity, COUNT(*) AS popularity
+				FROM search_logs l
+				WHERE l.results > 0 AND ? <% lower(btrim(l.query))
+				GROUP BY 1
+				UNION ALL
+				SELECT s.query, 'query', word_similarity(?, s.query), SUM(s.searches - s.zero_results)
+				FROM search_query_stats s
+				WHERE s.searches > s.zero_results AND ? <% s.query
+				GROUP BY s.query
+				UNION ALL
+				SELECT p.title, 'product', word_similarity(?, lower(p.title)), p.order_count
+				FROM products p
+				WHERE p.deleted_at IS NULL AND ? <% lower(p.title)
+			) candidates
+			WHERE lower(text) <> ?
+			ORDER BY similarity DESC, popularity DESC
+			LIMIT ?`,
+			text, text, text, text, text, text, text, maxSuggestions*4,
+		).Scan(&candidates).Error
+	})
+	if err != nil {
+		return nil, errors.New("failed to get search suggestions")
+	}
+
+	// The same text may come from several sources; keep its best match
+	seen := make(map[string]bool)
+	suggestions := []models.SpellingSuggestion{}
+	for _, candidate := range candidates {
+		key := strings.ToLower(candidate.Text)
+		if seen[key] || len(suggestions) == maxSuggestions {
+			continue
+		}
+		seen[key] = true
+		suggestions = append(suggestions, models.SpellingSuggestion{
+			Text:       candidate.Text,
+			Source:     candidate.Source,
+			Similarity: candidate.Similarity,
+		})
+	}
+	return suggestions, nil
+}
+
+// escapeLike escapes LIKE wildcards so user input is matched literally
+func escapeLike(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(text)
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"go-shop/config"
+	"go-shop/database"
+	"go-shop/models"
+
+	"gorm.io/gorm"
+)
+
+// searchQueryExpr normalizes logged queries so "Phone " and "phone" are reported together
+const searchQueryExpr = "lower(btrim(l.query))"
+
+// searchConvertedExpr is true when the user behind a search log placed an order within the conversion window
+const searchConvertedExpr = `(l.user_id IS NOT NULL AND EXISTS (
+	SELECT 1 FROM orders o
+	WHERE o.user_id = l.user_id AND o.deleted_at IS NULL
+		AND o.created_at > l.created_at AND o.created_at <= l.created_at + make_interval(hours => ?)
+))`
+
+type SearchAnalyticsService struct {
+	config *config.Config
+}
+
+func NewSearchAnalyticsService(cfg *config.Config) *SearchAnalyticsService {
+	return &SearchAnalyticsService{
+		config: cfg,
+	}
+}
+
+// searchPeriod is the [From, To) range of a report
+type searchPeriod struct {
+	From time.Time
+	To   time.Time
+}
+
+// parseSearchPeriod reads the inclusive from/to dates of a report request, defaulting to the last 30 days
+func parseSearchPeriod(req *models.SearchAnalyticsRequest) (searchPeriod, error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	period := searchPeriod{From: today.AddDate(0, 0, -29), To: today.AddDate(0, 0, 1)}
+
+	if req.From != "" {
+		from, err := time.Parse("2006-01-02", req.From)
+		if err != nil {
+			return period, errors.New("invalid from date, expected YYYY-MM-DD")
+		}
+		period.From = from
+	}
+	if req.To != "" {
+		to, err := time.Parse("2006-01-02", req.To)
+		if err != nil {
+			return period, errors.New("invalid to date, expected YYYY-MM-DD")
+		}
+		period.To = to.AddDate(0, 0, 1)
+	}
+	if !period.From.Before(period.To) {
+		return period, errors.New("from date must not be after to date")
+	}
+	return period, nil
+}
+
+func searchReportLimit(req *models.SearchAnalyticsRequest) int {
+	if req.Limit <= 0 {
+		return 20
+	}
+	return req.Limit
+}
+
+// GetTopQueries reports the most frequent queries of the period
+func (sas *SearchAnalyticsService) GetTopQueries(req *models.SearchAnalyticsRequest) ([]models.SearchQueryReport, error) {
+	return sas.queryReport(req, false)
+}
+
+// GetZeroResultQueries reports the queries that most often returned nothing
+func (sas *SearchAnalyticsService) GetZeroResultQueries(req *models.SearchAnalyticsRequest) ([]models.SearchQueryReport, error) {
+	return sas.queryReport(req, true)
+}
+
+// queryReport counts searches per query over raw logs and the daily aggregates of pruned logs
+func (sas *SearchAnalyticsService) queryReport(req *models.SearchAnalyticsRequest, zeroResults bool) ([]models.SearchQueryReport, error) {
+	period, err := parseSearchPeriod(req)
+	if err != nil {
+		return nil, err
+	}
+
+	having, order := "", "searches DESC"
+	if zeroResults {
+		having, order = "HAVING SUM(zero_results) > 0", "zero_results DESC, searches DESC"
+	}
+
+	reports := []models.SearchQueryReport{}
+	if err := database.DB.Raw(`
+		WITH combined AS (
+			SELECT `+searchQueryExpr+` AS query, COUNT(*) AS searches, COUNT(*) FILTER (WHERE l.results = 0) AS zero_results
+			FROM search_logs l
+			WHERE l.created_at >= ? AND l.created_at < ? AND btrim(l.query) <> ''
+			GROUP BY 1
+			UNION ALL
+			SELECT s.query, s.searches, s.zero_results
+			FROM search_query_stats s
+			WHERE s.day >= ? AND s.day < ? AND s.query <> ''
+		)
+		SELECT query, SUM(searches) AS searches, SUM(zero_results) AS zero_results
+		FROM combined
+		GROUP BY query `+having+`
+		ORDER BY `+order+`, query ASC
+		LIMIT ?`,
+		period.From, period.To, period.From, period.To, searchReportLimit(req),
+	).Scan(&reports).Error; err != nil {
+		return nil, errors.New("failed to get search queries")
+	}
+
+	return reports, nil
+}
+
+// GetConversion reports how many searches of signed-in users were followed by an order of the same user
+func (sas *SearchAnalyticsService) GetConversion(req *models.SearchAnalyticsRequest) (*models.SearchConversionReport, error) {
+	period, err := parseSearchPeriod(req)
+	if err != nil {
+		return nil, err
+	}
+
+	queries := []models.SearchQueryConversionReport{}
+	if err := database.DB.Raw(`
+		WITH combined AS (
+			SELECT `+searchQueryExpr+` AS query, COUNT(*) AS user_searches, COUNT(*) FILTER (WHERE `+searchConvertedExpr+`) AS conversions
+			FROM search_logs l
+			WHERE l.created_at >= ? AND l.created_at < ? AND l.user_id IS NOT NULL
+			GROUP BY 1
+			UNION ALL
+			SELECT s.query, s.user_searches, s.conversions
+			FROM search_query_stats s
+			WHERE s.day >= ? AND s.day < ? AND s.user_searches > 0
+		)
+		SELECT query, SUM(user_searches) AS user_searches, SUM(conversions) AS conversions
+		FROM combined
+		GROUP BY query
+		ORDER BY conversions DESC, user_searches DESC, query ASC`,
+		sas.config.Search.ConversionWindowHours, period.From, period.To, period.From, period.To,
+	).Scan(&queries).Error; err != nil {
+		return nil, errors.New("failed to get search conversion")
+	}
+
+	report := &models.SearchConversionReport{
+		WindowHours: sas.config.Search.ConversionWindowHours,
+		Queries:     []models.SearchQueryConversionReport{},
+	}
+	limit := searchReportLimit(req)
+	for i := range queries {
+		report.UserSearches += queries[i].UserSearches
+		report.Conversions += queries[i].Conversions
+		queries[i].ConversionRate = conversionRate(queries[i].Conversions, queries[i].UserSearches)
+
+		// Filter-only searches count towards the totals but are not a query of their own
+		if queries[i].Query != "" && len(report.Queries) < limit {
+			report.Queries = append(report.Queries, queries[i])
+		}
+	}
+	report.ConversionRate = conversionRate(report.Conversions, report.UserSearches)
+
+	return report, nil
+}
+
+func conversionRate(conversions, searches int64) float64 {
+	if searches == 0 {
+		return 0
+	}
+	return float64(conversions) / float64(searches)
+}
+
+// GetTrends reports search volume per day or ISO week
+func (sas *SearchAnalyticsService) GetTrends(req *models.SearchAnalyticsRequest) ([]models.SearchTrendReport, error) {
+	period, err := parseSearchPeriod(req)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := req.Interval
+	if interval == "" {
+		interval = "day"
+	}
+
+	trends := []models.SearchTrendReport{}
+	if err := database.DB.Raw(`
+		WITH combined AS (
+			SELECT l.created_at::date AS day, `+searchQueryExpr+` AS query, COUNT(*) AS searches, COUNT(*) FILTER (WHERE l.results = 0) AS zero_results
+			FROM search_logs l
+			WHERE l.created_at >= ? AND l.created_at < ?
+			GROUP BY 1, 2
+			UNION ALL
+			SELECT s.day, s.query, s.searches, s.zero_results
+			FROM search_query_stats s
+			WHERE s.day >= ? AND s.day < ?
+		)
+		SELECT date_trunc(?, day) AS period, SUM(searches) AS searches, SUM(zero_results) AS zero_results,
+			COUNT(DISTINCT query) FILTER (WHERE query <> '') AS queries
+		FROM combined
+		GROUP BY 1
+		ORDER BY 1 ASC`,
+		period.From, period.To, period.From, period.To, interval,
+	).Scan(&trends).Error; err != nil {
+		return nil, errors.New("failed to get search trends")
+	}
+
+	return trends, nil
+}
+
+// AggregateSearchLogs folds raw search_logs older than the retention period into daily
+// search_query_stats rows and deletes them. Only whole days are aggregated.
+func (sas *SearchAnalyticsService) AggregateSearchLogs() (int64, error) {
+	if sas.config.Search.LogRetentionDays <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -sas.config.Search.LogRetentionDays)
+
+	var pruned int64
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`
+			INSERT INTO search_query_stats (day, query, searches, zero_results, user_searches, conversions, created_at, updated_at)
+			SELECT l.created_at::date, `+searchQueryExpr+`, COUNT(*),
+				COUNT(*) FILTER (WHERE l.results = 0),
+				COUNT(l.user_id),
+				COUNT(*) FILTER (WHERE `+searchConvertedExpr+`),
+				now(), now()
+			FROM search_logs l
+			WHERE l.created_at < ?
+			GROUP BY 1, 2
+			ON CONFLICT (day, query) DO UPDATE SET
+				searches = search_query_stats.searches + EXCLUDED.searches,
+				zero_results = search_query_stats.zero_results + EXCLUDED.zero_results,
+				user_searches = search_query_stats.user_searches + EXCLUDED.user_searches,
+				conversions = search_query_stats.conversions + EXCLUDED.conversions,
+				updated_at = now()`,
+			sas.config.Search.ConversionWindowHours, cutoff,
+		).Error; err != nil {
+			return errors.New("failed to aggregate search logs")
+		}
+
+		result := tx.Where("created_at < ?", cutoff).Delete(&models.SearchLog{})
+		if result.Error != nil {
+			return errors.New("failed to prune search logs")
+		}
+		pruned = result.RowsAffected
+		return nil
+	})
+
+	return pruned, err
+}
+
+// StartRetentionWorker runs AggregateSearchLogs in the background at the configured interval
+func (sas *SearchAnalyticsService) StartRetentionWorker() {
+	interval := time.Duration(sas.config.Search.RetentionCheckHours) * time.Hour
+	if interval <= 0 || sas.config.Search.LogRetentionDays <= 0 {
+		log.Println("Search log retention worker disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			pruned, err := sas.AggregateSearchLogs()
+			if err != nil {
+				log.Printf("Search log retention worker: %v", err)
+				continue
+			}
+			if pruned > 0 {
+				log.Printf("Search log retention worker: aggregated and pruned %d search logs", pruned)
+			}
+		}
+	}()
+
+	log.Printf("Search log retention worker started (interval %s)", interval)
+}
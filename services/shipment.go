@@ -0,0 +1,488 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go-shop/database"
+	"go-shop/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// shipmentTransitions declares every legal shipment status change and the roles allowed to make it
+var shipmentTransitions = map[models.ShipmentStatus]map[models.ShipmentStatus][]string{
+	models.ShipmentStatusPending: {
+		models.ShipmentStatusConfirmed: {models.ROLE_SUPER_ADMIN},
+	},
+	models.ShipmentStatusConfirmed: {
+		models.ShipmentStatusShipped: {models.ROLE_SELLER, models.ROLE_SUPER_ADMIN},
+	},
+	models.ShipmentStatusShipped: {
+		models.ShipmentStatusDelivered: {models.ROLE_SUPER_ADMIN},
+	},
+	models.ShipmentStatusDelivered: {},
+	models.ShipmentStatusCancelled: {},
+}
+
+// shipmentOrderStatus is the order status reached once every active shipment reaches a shipment status
+var shipmentOrderStatus = map[models.ShipmentStatus]models.OrderStatus{
+	models.ShipmentStatusPending:   models.OrderStatusPaid,
+	models.ShipmentStatusConfirmed: models.OrderStatusConfirmed,
+	models.ShipmentStatusShipped:   models.OrderStatusShipped,
+	models.ShipmentStatusDelivered: models.OrderStatusDelivered,
+}
+
+// fulfilmentPath is the order of statuses a paid order goes through while its shipments progress
+var fulfilmentPath = []models.OrderStatus{
+	models.OrderStatusPaid,
+	models.OrderStatusConfirmed,
+	models.OrderStatusShipped,
+	models.OrderStatusDelivered,
+}
+
+// CanTransitionShipment reports whether the actor role may move a shipment from one status to another
+func CanTransitionShipment(from, to models.ShipmentStatus, role string) error {
+	roles, ok := shipmentTransitions[from][to]
+	if !ok {
+		return fmt.Errorf("cannot change shipment status from %s to %s", from, to)
+	}
+
+	for _, allowed := range roles {
+		if allowed == role {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s cannot change shipment status from %s to %s", role, from, to)
+}
+
+// ConfirmShipment confirms one shipment of a paid order (Admin only)
+func (os *OrderService) ConfirmShipment(shipmentID uint, actor OrderActor) (*models.ShipmentResponse, error) {
+	return os.changeShipmentStatus(shipmentID, models.ShipmentStatusConfirmed, actor, nil)
+}
+
+// ShipShipment marks one shipment as shipped (Admin/Seller only); sellers can only ship their own shipments
+func (os *OrderService) ShipShipment(shipmentID uint, actor OrderActor, req *models.ShipmentShipRequest) (*models.ShipmentResponse, error) {
+	return os.changeShipmentStatus(shipmentID, models.ShipmentStatusShipped, actor, req)
+}
+
+// DeliverShipment marks one shipment as delivered (Admin only)
+func (os *OrderService) DeliverShipment(shipmentID uint, actor OrderActor) (*models.ShipmentResponse, error) {
+	return os.changeShipmentStatus(shipmentID, models.ShipmentStatusDelivered, actor, nil)
+}
+
+// GetShipments returns shipments for fulfilment, optionally limited to one seller and filtered by status
+func (os *OrderService) GetShipments(sellerID *uint, status string, limit, offset int) ([]models.ShipmentResponse, error) {
+	query := database.DB.Preload("Items")
+	if sellerID != nil {
+		query = query.Where("seller_id = ?", *sellerID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var shipments []models.Shipment
+	if err := query.Limit(limit).Offset(offset).Order("created_at DESC").Find(&shipments).Error; err != nil {
+		return nil, errors.New("failed to get shipments")
+	}
+
+	responses := []models.ShipmentResponse{}
+	for i := range shipments {
+		responses = append(responses, *toShipmentResponse(&shipments[i]))
+	}
+	return responses, nil
+}
+
+func (os *OrderService) changeShipmentStatus(shipmentID uint, to models.ShipmentStatus, actor OrderActor, req *models.ShipmentShipRequest) (*models.ShipmentResponse, error) {
+	// Start transaction
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var shipment models.Shipment
+	query := tx.Where("id = ?", shipmentID)
+	if actor.Role == models.ROLE_SELLER && actor.UserID != nil {
+		query = query.Where("seller_id = ?", *actor.UserID)
+	}
+	if err := query.First(&shipment).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("shipment not found")
+		}
+		return nil, errors.New("database error")
+	}
+
+	// Lock the parent order first so shipment changes of one order are serialized
+	order, err := lockOrder(tx, shipment.OrderID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := checkFulfilment(order, to, actor); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	shipments, err := loadShipments(tx, order)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	var current *models.Shipment
+	for i := range shipments {
+		if shipments[i].ID == shipment.ID {
+			current = &shipments[i]
+		}
+	}
+	if current == nil {
+		tx.Rollback()
+		return nil, errors.New("shipment not found")
+	}
+
+	if err := os.transitionShipment(tx, order, current, to, actor, req); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	reason := fmt.Sprintf("shipment #%d %s", current.ID, to)
+	if err := syncOrderStatus(tx, order, shipments, actor, reason); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return nil, errors.New("failed to commit transaction")
+	}
+
+	return toShipmentResponse(current), nil
+}
+
+// advanceShipments moves every shipment of an order the actor may handle to the next fulfilment status;
+// shipments being shipped are sent as req says (nil for the carrier of the order's shipping method)
+func (os *OrderService) advanceShipments(orderID uint, to models.ShipmentStatus, actor OrderActor, reason string, req *models.ShipmentShipRequest, scopes ...func(*gorm.DB) *gorm.DB) (*models.OrderResponse, error) {
+	// Start transaction
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	order, err := lockOrder(tx, orderID, scopes...)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := checkFulfilment(order, to, actor); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	shipments, err := loadShipments(tx, order)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	advanced := 0
+	for i := range shipments {
+		shipment := &shipments[i]
+		if actor.Role == models.ROLE_SELLER && !ownsShipment(shipment, actor) {
+			continue
+		}
+		if _, ok := shipmentTransitions[shipment.Status][to]; !ok {
+			continue
+		}
+
+		if err := os.transitionShipment(tx, order, shipment, to, actor, req); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		advanced++
+	}
+
+	if advanced == 0 {
+		tx.Rollback()
+		return nil, fmt.Errorf("order has no shipments that can be %s", to)
+	}
+
+	if err := syncOrderStatus(tx, order, shipments, actor, reason); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return nil, errors.New("failed to commit transaction")
+	}
+
+	order.Shipments = shipments
+	return toOrderResponse(order), nil
+}
+
+// checkFulfilment rejects shipment changes of orders that are not being fulfilled
+func checkFulfilment(order *models.Order, to models.ShipmentStatus, actor OrderActor) error {
+	switch order.Status {
+	case models.OrderStatusPaid, models.OrderStatusConfirmed, models.OrderStatusShipped:
+		return nil
+	}
+	// Report it the way the order state machine does
+	if err := CanTransitionOrder(order.Status, shipmentOrderStatus[to], actor.Role); err != nil {
+		return err
+	}
+	return fmt.Errorf("order is %s", order.Status)
+}
+
+func ownsShipment(shipment *models.Shipment, actor OrderActor) bool {
+	return actor.UserID != nil && shipment.SellerID != nil && *shipment.SellerID == *actor.UserID
+}
+
+// transitionShipment validates and applies a shipment status change with its stock side effects.
+// Shipped shipments are handed to their carrier.
+func (os *OrderService) transitionShipment(tx *gorm.DB, order *models.Order, shipment *models.Shipment, to models.ShipmentStatus, actor OrderActor, req *models.ShipmentShipRequest) error {
+	if err := CanTransitionShipment(shipment.Status, to, actor.Role); err != nil {
+		return err
+	}
+	if actor.Role == models.ROLE_SELLER && !ownsShipment(shipment, actor) {
+		return errors.New("shipment not found")
+	}
+
+	now := time.Now()
+	switch to {
+	case models.ShipmentStatusConfirmed:
+		var items []models.OrderItem
+		if err := tx.Where("shipment_id = ?", shipment.ID).Find(&items).Error; err != nil {
+			return errors.New("failed to get shipment items")
+		}
+
+		// Convert reservations into stock decrements
+		if err := consumeReservations(tx, order.ID, items); err != nil {
+			return err
+		}
+
+		// Update order_count (increment by 1 for each confirmed order)
+		for _, item := range items {
+			if err := tx.Model(&models.Product{}).Where("id = ?", item.ProductID).Update("order_count", gorm.Expr("order_count + 1")).Error; err != nil {
+				return errors.New("failed to update product order count")
+			}
+		}
+	case models.ShipmentStatusShipped:
+		tracking, err := os.carriers.trackShipment(order, shipment, req)
+		if err != nil {
+			return err
+		}
+		shipment.ShippedAt = &now
+		shipment.Carrier = tracking.Carrier
+		shipment.TrackingURL = tracking.TrackingURL
+		if tracking.TrackingNumber != "" {
+			shipment.TrackingNumber = tracking.TrackingNumber
+		}
+	case models.ShipmentStatusDelivered:
+		shipment.DeliveredAt = &now
+	}
+
+	shipment.Status = to
+	if err := tx.Omit(clause.Associations).Save(shipment).Error; err != nil {
+		return errors.New("failed to update shipment")
+	}
+	return nil
+}
+
+// syncOrderStatus moves the order along the fulfilment path as far as all its active shipments have reached
+func syncOrderStatus(tx *gorm.DB, order *models.Order, shipments []models.Shipment, actor OrderActor, reason string) error {
+	target := -1
+	for _, shipment := range shipments {
+		if shipment.Status == models.ShipmentStatusCancelled {
+			continue
+		}
+		index := fulfilmentIndex(shipmentOrderStatus[shipment.Status])
+		if target == -1 || index < target {
+			target = index
+		}
+	}
+
+	current := fulfilmentIndex(order.Status)
+	if target == -1 || current == -1 {
+		return nil
+	}
+
+	for current < target {
+		current++
+		next := fulfilmentPath[current]
+		if next == models.OrderStatusDelivered {
+			now := time.Now()
+			order.DeliveredAt = &now
+		}
+		if err := transitionOrder(tx, order, next, actor, reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fulfilmentIndex(status models.OrderStatus) int {
+	for i, s := range fulfilmentPath {
+		if s == status {
+			return i
+		}
+	}
+	return -1
+}
+
+// createShipments splits order items into one shipment per seller
+func createShipments(tx *gorm.DB, order *models.Order, items []models.OrderItem, sellers map[uint]*uint, status models.ShipmentStatus) ([]models.Shipment, error) {
+	// Items of the shop itself are grouped under seller key 0
+	sellerKey := func(item *models.OrderItem) uint {
+		if seller := sellers[item.ProductID]; seller != nil {
+			return *seller
+		}
+		return 0
+	}
+
+	groups := make(map[uint][]uint)
+	var sellerKeys []uint
+	for i := range items {
+		key := sellerKey(&items[i])
+		if _, ok := groups[key]; !ok {
+			sellerKeys = append(sellerKeys, key)
+		}
+		groups[key] = append(groups[key], items[i].ID)
+	}
+	sort.Slice(sellerKeys, func(i, j int) bool { return sellerKeys[i] < sellerKeys[j] })
+
+	var shipments []models.Shipment
+	for _, key := range sellerKeys {
+		shipment := models.Shipment{
+			OrderID: order.ID,
+			Status:  status,
+		}
+		if key != 0 {
+			sellerID := key
+			shipment.SellerID = &sellerID
+		}
+		if err := tx.Create(&shipment).Error; err != nil {
+			return nil, errors.New("failed to create shipment")
+		}
+
+		if err := tx.Model(&models.OrderItem{}).Where("id IN ?", groups[key]).Update("shipment_id", shipment.ID).Error; err != nil {
+			return nil, errors.New("failed to assign items to shipment")
+		}
+		for i := range items {
+			if sellerKey(&items[i]) == key {
+				items[i].ShipmentID = &shipment.ID
+			}
+		}
+		shipments = append(shipments, shipment)
+	}
+	return shipments, nil
+}
+
+// loadShipments returns the shipments of an order, splitting orders placed before shipments existed
+func loadShipments(tx *gorm.DB, order *models.Order) ([]models.Shipment, error) {
+	var shipments []models.Shipment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("order_id = ?", order.ID).Order("id ASC").Find(&shipments).Error; err != nil {
+		return nil, errors.New("failed to get shipments")
+	}
+	if len(shipments) > 0 {
+		return shipments, nil
+	}
+
+	var items []models.OrderItem
+	if err := tx.Where("order_id = ?", order.ID).Find(&items).Error; err != nil {
+		return nil, errors.New("failed to get order items")
+	}
+
+	sellers, err := productSellers(tx, items)
+	if err != nil {
+		return nil, err
+	}
+
+	return createShipments(tx, order, items, sellers, legacyShipmentStatus(order.Status))
+}
+
+// productSellers maps the products of order items to their sellers
+func productSellers(tx *gorm.DB, items []models.OrderItem) (map[uint]*uint, error) {
+	var productIDs []uint
+	for _, item := range items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+
+	var products []models.Product
+	if err := tx.Unscoped().Select("id", "seller_id").Where("id IN ?", productIDs).Find(&products).Error; err != nil {
+		return nil, errors.New("failed to get products")
+	}
+
+	sellers := make(map[uint]*uint, len(products))
+	for _, product := range products {
+		sellers[product.ID] = product.SellerID
+	}
+	return sellers, nil
+}
+
+// legacyShipmentStatus maps the status of an order without shipments to the status of its shipments
+func legacyShipmentStatus(status models.OrderStatus) models.ShipmentStatus {
+	switch status {
+	case models.OrderStatusConfirmed:
+		return models.ShipmentStatusConfirmed
+	case models.OrderStatusShipped:
+		return models.ShipmentStatusShipped
+	case models.OrderStatusDelivered, models.OrderStatusPartiallyRefunded, models.OrderStatusRefunded:
+		return models.ShipmentStatusDelivered
+	case models.OrderStatusCancelled:
+		return models.ShipmentStatusCancelled
+	}
+	return models.ShipmentStatusPending
+}
+
+// checkCancellable refuses to cancel an order once any of its shipments is delivered: delivered
+// goods are neither restocked nor refunded by a cancellation, they come back through returns
+func checkCancellable(shipments []models.Shipment) error {
+	for _, shipment := range shipments {
+		if shipment.Status == models.ShipmentStatusDelivered {
+			return errors.New("order has delivered shipments; request a return instead")
+		}
+	}
+	return nil
+}
+
+// cancelShipments cancels every shipment of an order that has not been delivered yet
+func cancelShipments(tx *gorm.DB, orderID uint) error {
+	if err := tx.Model(&models.Shipment{}).
+		Where("order_id = ? AND status <> ?", orderID, models.ShipmentStatusDelivered).
+		Update("status", models.ShipmentStatusCancelled).Error; err != nil {
+		return errors.New("failed to cancel shipments")
+	}
+	return nil
+}
+
+func toShipmentResponse(shipment *models.Shipment) *models.ShipmentResponse {
+	response := &models.ShipmentResponse{
+		ID:             shipment.ID,
+		OrderID:        shipment.OrderID,
+		SellerID:       shipment.SellerID,
+		Status:         shipment.Status,
+		Carrier:        shipment.Carrier,
+		TrackingNumber: shipment.TrackingNumber,
+		TrackingURL:    shipment.TrackingURL,
+		ShippedAt:      shipment.ShippedAt,
+		DeliveredAt:    shipment.DeliveredAt,
+		CreatedAt:      shipment.CreatedAt,
+		UpdatedAt:      shipment.UpdatedAt,
+	}
+
+	for _, item := range shipment.Items {
+		response.Items = append(response.Items, toOrderItemResponse(&item))
+	}
+
+	return response
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"testing"
+
+	"go-shop/models"
+)
+
+func TestCheckCancellable(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []models.ShipmentStatus
+		wantErr  bool
+	}{
+		{"no shipments", nil, false},
+		{"nothing shipped", []models.ShipmentStatus{models.ShipmentStatusPending, models.ShipmentStatusConfirmed}, false},
+		{"shipped but not delivered", []models.ShipmentStatus{models.ShipmentStatusShipped, models.ShipmentStatusConfirmed}, false},
+		{"partially delivered", []models.ShipmentStatus{models.ShipmentStatusDelivered, models.ShipmentStatusShipped}, true},
+		{"delivered next to a cancelled shipment", []models.ShipmentStatus{models.ShipmentStatusCancelled, models.ShipmentStatusDelivered}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var shipments []models.Shipment
+			for _, status := range tt.statuses {
+				shipments = append(shipments, models.Shipment{Status: status})
+			}
+
+			err := checkCancellable(shipments)
+			if tt.wantErr && err == nil {
+				t.Fatal("expected the cancellation to be refused")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
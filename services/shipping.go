@@ -0,0 +1,238 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	"go-shop/config"
+	"go-shop/database"
+	"go-shop/models"
+
+	"gorm.io/gorm"
+)
+
+type ShippingService struct {
+	cfg      *config.Config
+	carriers Carriers
+}
+
+func NewShippingService(cfg *config.Config, carriers Carriers) *ShippingService {
+	return &ShippingService{
+		cfg:      cfg,
+		carriers: carriers,
+	}
+}
+
+// GetShippingMethods returns the active methods shoppers can choose, optionally only those delivering
+// to a country, priced in the requested currency ("" for the base currency)
+func (ss *ShippingService) GetShippingMethods(currency, country string) ([]models.ShippingMethodResponse, error) {
+	prices, err := loadPriceList(database.DB, ss.cfg, currency, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var methods []models.ShippingMethod
+	if err := database.DB.Where("is_active = ?", true).Order("price ASC, id ASC").Find(&methods).Error; err != nil {
+		return nil, errors.New("failed to get shipping methods")
+	}
+
+	country = models.NormalizeCountry(country)
+	responses := []models.ShippingMethodResponse{}
+	for i := range methods {
+		if country != "" && !methods[i].Serves(country) {
+			continue
+		}
+		responses = append(responses, *toShippingMethodResponse(&methods[i], prices))
+	}
+	return responses, nil
+}
+
+// GetAllShippingMethods returns every shipping method, inactive ones included, in the base currency
+func (ss *ShippingService) GetAllShippingMethods() ([]models.ShippingMethodResponse, error) {
+	var methods []models.ShippingMethod
+	if err := database.DB.Order("id ASC").Find(&methods).Error; err != nil {
+		return nil, errors.New("failed to get shipping methods")
+	}
+
+	base := &priceList{currency: ss.cfg.Currency.Base}
+	responses := []models.ShippingMethodResponse{}
+	for i := range methods {
+		responses = append(responses, *toShippingMethodResponse(&methods[i], base))
+	}
+	return responses, nil
+}
+
+func (ss *ShippingService) GetShippingMethodByID(methodID uint) (*models.ShippingMethodResponse, error) {
+	method, err := findShippingMethod(methodID)
+	if err != nil {
+		return nil, err
+	}
+	return toShippingMethodResponse(method, &priceList{currency: ss.cfg.Currency.Base}), nil
+}
+
+func (ss *ShippingService) CreateShippingMethod(req *models.ShippingMethodRequest) (*models.ShippingMethodResponse, error) {
+	var method models.ShippingMethod
+	if err := ss.fillShippingMethod(&method, req); err != nil {
+		return nil, err
+	}
+
+	if err := database.DB.Create(&method).Error; err != nil {
+		return nil, errors.New("failed to create shipping method")
+	}
+	return toShippingMethodResponse(&method, &priceList{currency: ss.cfg.Currency.Base}), nil
+}
+
+// UpdateShippingMethod replaces all settings of a shipping method; orders already placed keep their shipping cost
+func (ss *ShippingService) UpdateShippingMethod(methodID uint, req *models.ShippingMethodRequest) (*models.ShippingMethodResponse, error) {
+	method, err := findShippingMethod(methodID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ss.fillShippingMethod(method, req); err != nil {
+		return nil, err
+	}
+
+	if err := database.DB.Save(method).Error; err != nil {
+		return nil, errors.New("failed to update shipping method")
+	}
+	return toShippingMethodResponse(method, &priceList{currency: ss.cfg.Currency.Base}), nil
+}
+
+// DeleteShippingMethod removes a shipping method; orders already placed keep their shipping cost and carrier
+func (ss *ShippingService) DeleteShippingMethod(methodID uint) error {
+	method, err := findShippingMethod(methodID)
+	if err != nil {
+		return err
+	}
+
+	if err := database.DB.Delete(method).Error; err != nil {
+		return errors.New("failed to delete shipping method")
+	}
+	return nil
+}
+
+func findShippingMethod(methodID uint) (*models.ShippingMethod, error) {
+	var method models.ShippingMethod
+	if err := database.DB.First(&method, methodID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("shipping method not found")
+		}
+		return nil, errors.New("database error")
+	}
+	return &method, nil
+}
+
+// fillShippingMethod validates a request and copies it onto the method
+func (ss *ShippingService) fillShippingMethod(method *models.ShippingMethod, req *models.ShippingMethodRequest) error {
+	carrier, err := ss.carriers.Get(req.Carrier)
+	if err != nil {
+		return err
+	}
+	if req.Type != models.ShippingRateWeight {
+		req.PricePerKg = 0
+	}
+
+	countries := models.StringArray{}
+	for _, code := range req.Countries {
+		country := models.NormalizeCountry(code)
+		if country == "" {
+			return errors.New("countries must be ISO 3166-1 alpha-2 codes")
+		}
+		countries = append(countries, country)
+	}
+
+	method.Name = req.Name
+	method.Description = req.Description
+	method.Carrier = carrier.Name()
+	method.Type = req.Type
+	method.Price = req.Price
+	method.PricePerKg = req.PricePerKg
+	method.FreeOver = req.FreeOver
+	method.Countries = countries
+	method.IsActive = req.IsActive == nil || *req.IsActive
+	return nil
+}
+
+func toShippingMethodResponse(method *models.ShippingMethod, prices *priceList) *models.ShippingMethodResponse {
+	countries := []string{}
+	countries = append(countries, method.Countries...)
+
+	return &models.ShippingMethodResponse{
+		ID:          method.ID,
+		Name:        method.Name,
+		Description: method.Description,
+		Carrier:     method.Carrier,
+		Type:        method.Type,
+		Currency:    prices.currency,
+		Price:       prices.Convert(method.Price),
+		PricePerKg:  prices.Convert(method.PricePerKg),
+		FreeOver:    prices.Convert(method.FreeOver),
+		Countries:   countries,
+		IsActive:    method.IsActive,
+		CreatedAt:   method.CreatedAt,
+		UpdatedAt:   method.UpdatedAt,
+	}
+}
+
+// orderShippingMethod returns the active method an order is shipped with. Shops without shipping
+// methods ship for free, so nil is returned when none is chosen and none exists.
+func orderShippingMethod(tx *gorm.DB, methodID *uint, country string) (*models.ShippingMethod, error) {
+	if methodID == nil {
+		var count int64
+		if err := tx.Model(&models.ShippingMethod{}).Where("is_active = ?", true).Count(&count).Error; err != nil {
+			return nil, errors.New("database error")
+		}
+		if count > 0 {
+			return nil, errors.New("choose a shipping method")
+		}
+		return nil, nil
+	}
+
+	var method models.ShippingMethod
+	if err := tx.Where("id = ? AND is_active = ?", *methodID, true).First(&method).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("shipping method not found")
+		}
+		return nil, errors.New("database error")
+	}
+	if !method.Serves(country) {
+		return nil, errors.New("shipping method does not deliver to the shipping address")
+	}
+	return &method, nil
+}
+
+// shippingCost prices a method for items weighing grams and worth goods after discounts, in the
+// currency of the price list
+func shippingCost(method *models.ShippingMethod, grams int64, goods models.Money, prices *priceList) models.Money {
+	if method.FreeOver > 0 && goods >= prices.Convert(method.FreeOver) {
+		return 0
+	}
+
+	cost := method.Price
+	if method.Type == models.ShippingRateWeight {
+		cost += method.PricePerKg.Share(grams, 1000)
+	}
+	return prices.Convert(cost)
+}
+
+// productWeight returns the weight of a product in grams from its weight attribute in kg, given as
+// a number or a string; products without a valid weight weigh nothing
+func productWeight(product *models.Product, attribute string) int64 {
+	var kg float64
+	switch value := product.ExtraInfo[attribute].(type) {
+	case float64:
+		kg = value
+	case string:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return 0
+		}
+		kg = parsed
+	}
+	if kg <= 0 || math.IsInf(kg, 0) || math.IsNaN(kg) {
+		return 0
+	}
+	return int64(math.Round(kg * 1000))
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"go-shop/models"
+)
+
+func TestShippingCost(t *testing.T) {
+	base := &priceList{currency: "USD"}
+	euros := &priceList{currency: "EUR", rate: big.NewRat(1, 2)}
+
+	flat := &models.ShippingMethod{Type: models.ShippingRateFlat, Price: 500}
+	weight := &models.ShippingMethod{Type: models.ShippingRateWeight, Price: 300, PricePerKg: 200}
+	freeOver := &models.ShippingMethod{Type: models.ShippingRateFlat, Price: 500, FreeOver: 5000}
+
+	tests := []struct {
+		name   string
+		method *models.ShippingMethod
+		grams  int64
+		goods  models.Money
+		prices *priceList
+		want   models.Money
+	}{
+		{"flat", flat, 0, 1000, base, 500},
+		{"flat ignores weight", flat, 12500, 1000, base, 500},
+		{"flat without free-over is never free", flat, 0, 1000000, base, 500},
+		{"weight without items", weight, 0, 1000, base, 300},
+		{"weight per kg", weight, 2000, 1000, base, 700},
+		{"weight rounds part kg", weight, 1255, 1000, base, 551},
+		{"free-over not reached", freeOver, 0, 4999, base, 500},
+		{"free-over reached", freeOver, 0, 5000, base, 0},
+		{"free-over exceeded", freeOver, 0, 7500, base, 0},
+		{"converted rate", weight, 1000, 1000, euros, 250},
+		{"free-over in converted currency", freeOver, 0, 2500, euros, 0},
+		{"free-over below converted threshold", freeOver, 0, 2499, euros, 250},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shippingCost(tt.method, tt.grams, tt.goods, tt.prices); got != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestProductWeight(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  int64
+	}{
+		{"number", 1.25, 1250},
+		{"string", " 0.5 ", 500},
+		{"rounds to grams", 0.0004, 0},
+		{"invalid string", "heavy", 0},
+		{"negative", -2.0, 0},
+		{"zero", 0.0, 0},
+		{"other type", true, 0},
+		{"missing", nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			product := &models.Product{ExtraInfo: map[string]interface{}{}}
+			if tt.value != nil {
+				product.ExtraInfo["weight"] = tt.value
+			}
+			if got := productWeight(product, "weight"); got != tt.want {
+				t.Fatalf("expected %d grams, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTrackShipment(t *testing.T) {
+	carriers := Carriers{FakeCarrierName: NewFakeCarrier()}
+
+	tests := []struct {
+		name         string
+		orderCarrier string
+		req          *models.ShipmentShipRequest
+		wantCarrier  string
+		wantNumber   string // "FAKE*" for a generated one
+		wantErr      bool
+		wantIs       error
+	}{
+		{"generated by the order's carrier", FakeCarrierName, nil, FakeCarrierName, "FAKE*", false, nil},
+		{"generated by the requested carrier", "", &models.ShipmentShipRequest{Carrier: "Fake"}, FakeCarrierName, "FAKE*", false, nil},
+		{"entered by hand", FakeCarrierName, &models.ShipmentShipRequest{TrackingNumber: " AB123456 "}, FakeCarrierName, "AB123456", false, nil},
+		{"invalid tracking number", FakeCarrierName, &models.ShipmentShipRequest{TrackingNumber: "ab-123456"}, "", "", true, nil},
+		{"too short tracking number", FakeCarrierName, &models.ShipmentShipRequest{TrackingNumber: "AB12"}, "", "", true, nil},
+		{"unknown carrier", FakeCarrierName, &models.ShipmentShipRequest{Carrier: "pigeon"}, "", "", true, ErrUnknownCarrier},
+		{"without a carrier", "", &models.ShipmentShipRequest{TrackingNumber: "any number"}, "", "any number", false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := &models.Order{OrderNumber: "TEST-1", ShippingCarrier: tt.orderCarrier}
+			tracking, err := carriers.trackShipment(order, &models.Shipment{ID: 1}, tt.req)
+			if tt.wantErr {
+				if err == nil || (tt.wantIs != nil && !errors.Is(err, tt.wantIs)) {
+					t.Fatalf("expected an error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tracking.Carrier != tt.wantCarrier {
+				t.Fatalf("expected carrier %q, got %q", tt.wantCarrier, tracking.Carrier)
+			}
+			if prefix, generated := strings.CutSuffix(tt.wantNumber, "*"); generated {
+				if !strings.HasPrefix(tracking.TrackingNumber, prefix) || tracking.TrackingNumber == prefix {
+					t.Fatalf("expected a generated %s tracking number, got %q", prefix, tracking.TrackingNumber)
+				}
+				if err := carriers[FakeCarrierName].ValidateTrackingNumber(tracking.TrackingNumber); err != nil {
+					t.Fatalf("generated tracking number %q does not validate: %v", tracking.TrackingNumber, err)
+				}
+			} else if tracking.TrackingNumber != tt.wantNumber {
+				t.Fatalf("expected tracking number %q, got %q", tt.wantNumber, tracking.TrackingNumber)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	"go-shop/database"
+	"go-shop/models"
+
+	"gorm.io/gorm"
+)
+
+type TaxService struct{}
+
+func NewTaxService() *TaxService {
+	return &TaxService{}
+}
+
+func (ts *TaxService) CreateTaxRate(req *models.TaxRateRequest) (*models.TaxRateResponse, error) {
+	var rate models.TaxRate
+	if err := fillTaxRate(&rate, req); err != nil {
+		return nil, err
+	}
+
+	if err := database.DB.Create(&rate).Error; err != nil {
+		return nil, errors.New("failed to create tax rate")
+	}
+
+	return toTaxRateResponse(&rate), nil
+}
+
+// GetTaxRates returns the tax rates of a country, or of every country when country is empty
+func (ts *TaxService) GetTaxRates(country string) ([]models.TaxRateResponse, error) {
+	query := database.DB.Order("country ASC, region ASC, category_id ASC NULLS FIRST")
+	if country != "" {
+		query = query.Where("country = ?", models.NormalizeCountry(country))
+	}
+
+	var rates []models.TaxRate
+	if err := query.Find(&rates).Error; err != nil {
+		return nil, errors.New("failed to get tax rates")
+	}
+
+	responses := []models.TaxRateResponse{}
+	for i := range rates {
+		responses = append(responses, *toTaxRateResponse(&rates[i]))
+	}
+	return responses, nil
+}
+
+func (ts *TaxService) GetTaxRateByID(rateID uint) (*models.TaxRateResponse, error) {
+	rate, err := findTaxRate(rateID)
+	if err != nil {
+		return nil, err
+	}
+	return toTaxRateResponse(rate), nil
+}
+
+// UpdateTaxRate replaces all settings of a tax rate; orders already placed keep their taxes
+func (ts *TaxService) UpdateTaxRate(rateID uint, req *models.TaxRateRequest) (*models.TaxRateResponse, error) {
+	rate, err := findTaxRate(rateID)
+	if err != nil {
+		return nil, err
+	}
+	if err := fillTaxRate(rate, req); err != nil {
+		return nil, err
+	}
+
+	if err := database.DB.Save(rate).Error; err != nil {
+		return nil, errors.New("failed to update tax rate")
+	}
+	return toTaxRateResponse(rate), nil
+}
+
+// DeleteTaxRate removes a tax rate; orders already placed keep their taxes
+func (ts *TaxService) DeleteTaxRate(rateID uint) error {
+	result := database.DB.Delete(&models.TaxRate{}, rateID)
+	if result.Error != nil {
+		return errors.New("failed to delete tax rate")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("tax rate not found")
+	}
+	return nil
+}
+
+func findTaxRate(rateID uint) (*models.TaxRate, error) {
+	var rate models.TaxRate
+	if err := database.DB.First(&rate, rateID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("tax rate not found")
+		}
+		return nil, errors.New("database error")
+	}
+	return &rate, nil
+}
+
+// fillTaxRate validates a request and copies it onto the rate. Only one active rate may exist per
+// country, region and category, so every order line has a single rate.
+func fillTaxRate(rate *models.TaxRate, req *models.TaxRateRequest) error {
+	country := models.NormalizeCountry(req.Country)
+	if country == "" {
+		return errors.New("country must be an ISO 3166-1 alpha-2 code")
+	}
+	region := strings.TrimSpace(req.Region)
+	isActive := req.IsActive == nil || *req.IsActive
+
+	if req.CategoryID != nil {
+		var count int64
+		if err := database.DB.Model(&models.Category{}).Where("id = ?", *req.CategoryID).Count(&count).Error; err != nil {
+			return errors.New("database error")
+		}
+		if count == 0 {
+			return errors.New("category not found")
+		}
+	}
+
+	if isActive {
+		query := database.DB.Model(&models.TaxRate{}).
+			Where("country = ? AND LOWER(region) = LOWER(?) AND is_active = ? AND id <> ?", country, region, true, rate.ID)
+		if req.CategoryID != nil {
+			query = query.Where("category_id = ?", *req.CategoryID)
+		} else {
+			query = query.Where("category_id IS NULL")
+		}
+
+		var count int64
+		if err := query.Count(&count).Error; err != nil {
+			return errors.New("database error")
+		}
+		if count > 0 {
+			return errors.New("an active tax rate for this country, region and category already exists")
+		}
+	}
+
+	rate.Name = req.Name
+	rate.Country = country
+	rate.Region = region
+	rate.CategoryID = req.CategoryID
+	rate.Rate = req.Rate
+	rate.Inclusive = req.Inclusive
+	rate.IsActive = isActive
+	return nil
+}
+
+func toTaxRateResponse(rate *models.TaxRate) *models.TaxRateResponse {
+	return &models.TaxRateResponse{
+		ID:         rate.ID,
+		Name:       rate.Name,
+		Country:    rate.Country,
+		Region:     rate.Region,
+		CategoryID: rate.CategoryID,
+		Rate:       rate.Rate,
+		Inclusive:  rate.Inclusive,
+		IsActive:   rate.IsActive,
+		CreatedAt:  rate.CreatedAt,
+		UpdatedAt:  rate.UpdatedAt,
+	}
+}
+
+func toOrderTaxResponse(tax *models.OrderTax) models.OrderTaxResponse {
+	return models.OrderTaxResponse{
+		TaxRateID: tax.TaxRateID,
+		Name:      tax.Name,
+		Rate:      tax.Rate,
+		Inclusive: tax.Inclusive,
+		Taxable:   tax.Taxable,
+		Amount:    tax.Amount,
+	}
+}
+
+// taxRules are the active tax rates of a shipping destination
+type taxRules struct {
+	rates []models.TaxRate
+}
+
+// loadTaxRules loads the active rates of a country and region; an empty country has no taxes
+func loadTaxRules(db *gorm.DB, country, region string) (*taxRules, error) {
+	rules := &taxRules{}
+	if country == "" {
+		return rules, nil
+	}
+
+	if err := db.Where("country = ? AND is_active = ? AND (region = '' OR LOWER(region) = LOWER(?))", country, true, strings.TrimSpace(region)).
+		Find(&rules.rates).Error; err != nil {
+		return nil, errors.New("failed to get tax rates")
+	}
+	return rules, nil
+}
+
+// rateFor returns the most specific rate for products of a category, or nil when none applies
+func (tr *taxRules) rateFor(categoryID *uint) *models.TaxRate {
+	var best *models.TaxRate
+	bestScore := -1
+	for i := range tr.rates {
+		rate := &tr.rates[i]
+		score := 0
+		if rate.CategoryID != nil {
+			if categoryID == nil || *rate.CategoryID != *categoryID {
+				continue
+			}
+			score += 2
+		}
+		if rate.Region != "" {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = rate, score
+		}
+	}
+	return best
+}
+
+// applyTaxes taxes the order items, after their discounts, at the rate of their product category
+// and returns the taxes by rate. Inclusive taxes are the part of the amount that is tax; exclusive
+// taxes are added to it.
+func applyTaxes(items []models.OrderItem, categoryIDs []*uint, rules *taxRules, currency string) []models.OrderTax {
+	var taxes []models.OrderTax
+	byRate := make(map[uint]int)
+	for i := range items {
+		item := &items[i]
+		rate := rules.rateFor(categoryIDs[i])
+		if rate == nil {
+			continue
+		}
+
+		taxable := item.PriceAtMoment.Mul(item.Quantity) - item.Discount
+		share := new(big.Rat).Quo(models.DecimalRat(rate.Rate), big.NewRat(100, 1))
+		if rate.Inclusive {
+			// rate / (100 + rate) of a gross amount is tax
+			share.Quo(share, new(big.Rat).Add(share, big.NewRat(1, 1)))
+		}
+
+		item.TaxRate = rate.Rate
+		item.TaxInclusive = rate.Inclusive
+		item.Tax = taxable.MulRat(share).RoundTo(currency)
+
+		index, ok := byRate[rate.ID]
+		if !ok {
+			index = len(taxes)
+			byRate[rate.ID] = index
+			taxes = append(taxes, models.OrderTax{
+				TaxRateID: rate.ID,
+				Name:      rate.Name,
+				Rate:      rate.Rate,
+				Inclusive: rate.Inclusive,
+			})
+		}
+		taxes[index].Taxable += taxable
+		taxes[index].Amount += item.Tax
+	}
+	return taxes
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"testing"
+
+	"go-shop/models"
+)
+
+func TestTaxRulesRateFor(t *testing.T) {
+	books, food := uint(1), uint(2)
+	rules := &taxRules{rates: []models.TaxRate{
+		{ID: 1, Country: "DE", Rate: 19},
+		{ID: 2, Country: "DE", CategoryID: &books, Rate: 7},
+		{ID: 3, Country: "DE", Region: "Bavaria", Rate: 20},
+		{ID: 4, Country: "DE", Region: "Bavaria", CategoryID: &books, Rate: 5},
+	}}
+
+	tests := []struct {
+		name       string
+		rules      *taxRules
+		categoryID *uint
+		wantID     uint // 0 when no rate applies
+	}{
+		{"category and region", rules, &books, 4},
+		{"region beats the country", rules, &food, 3},
+		{"no category", rules, nil, 3},
+		{"category beats the region", &taxRules{rates: rules.rates[:3]}, &books, 2},
+		{"country only", &taxRules{rates: rules.rates[:2]}, &food, 1},
+		{"only rates of other categories", &taxRules{rates: rules.rates[1:2]}, &food, 0},
+		{"no rates", &taxRules{}, &books, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate := tt.rules.rateFor(tt.categoryID)
+			if tt.wantID == 0 {
+				if rate != nil {
+					t.Fatalf("expected no rate, got %+v", *rate)
+				}
+				return
+			}
+			if rate == nil || rate.ID != tt.wantID {
+				t.Fatalf("expected rate %d, got %+v", tt.wantID, rate)
+			}
+		})
+	}
+}
+
+func TestApplyTaxes(t *testing.T) {
+	books := uint(1)
+	rules := &taxRules{rates: []models.TaxRate{
+		{ID: 1, Name: "VAT", Country: "DE", Rate: 19},
+		{ID: 2, Name: "Reduced VAT", Country: "DE", CategoryID: &books, Rate: 7},
+	}}
+	inclusive := &taxRules{rates: []models.TaxRate{{ID: 3, Name: "VAT", Country: "DE", Rate: 20, Inclusive: true}}}
+
+	tests := []struct {
+		name        string
+		items       []models.OrderItem
+		categoryIDs []*uint
+		rules       *taxRules
+		currency    string
+		wantTax     []models.Money
+		wantTaxes   []models.OrderTax
+	}{
+		{
+			name:        "exclusive by category",
+			items:       []models.OrderItem{{PriceAtMoment: 1000, Quantity: 2}, {PriceAtMoment: 500, Quantity: 1}, {PriceAtMoment: 300, Quantity: 1}},
+			categoryIDs: []*uint{nil, &books, nil},
+			rules:       rules,
+			currency:    "EUR",
+			wantTax:     []models.Money{380, 35, 57},
+			wantTaxes: []models.OrderTax{
+				{TaxRateID: 1, Name: "VAT", Rate: 19, Taxable: 2300, Amount: 437},
+				{TaxRateID: 2, Name: "Reduced VAT", Rate: 7, Taxable: 500, Amount: 35},
+			},
+		},
+		{
+			name:        "after the discount",
+			items:       []models.OrderItem{{PriceAtMoment: 1000, Quantity: 1, Discount: 250}},
+			categoryIDs: []*uint{nil},
+			rules:       rules,
+			currency:    "EUR",
+			wantTax:     []models.Money{143},
+			wantTaxes:   []models.OrderTax{{TaxRateID: 1, Name: "VAT", Rate: 19, Taxable: 750, Amount: 143}},
+		},
+		{
+			name:        "inclusive",
+			items:       []models.OrderItem{{PriceAtMoment: 1200, Quantity: 1}},
+			categoryIDs: []*uint{nil},
+			rules:       inclusive,
+			currency:    "EUR",
+			wantTax:     []models.Money{200},
+			wantTaxes:   []models.OrderTax{{TaxRateID: 3, Name: "VAT", Rate: 20, Inclusive: true, Taxable: 1200, Amount: 200}},
+		},
+		{
+			name:        "rounded to the currency",
+			items:       []models.OrderItem{{PriceAtMoment: 12300, Quantity: 1}},
+			categoryIDs: []*uint{nil},
+			rules:       rules,
+			currency:    "JPY",
+			wantTax:     []models.Money{2300},
+			wantTaxes:   []models.OrderTax{{TaxRateID: 1, Name: "VAT", Rate: 19, Taxable: 12300, Amount: 2300}},
+		},
+		{
+			name:        "no rates",
+			items:       []models.OrderItem{{PriceAtMoment: 1000, Quantity: 1}},
+			categoryIDs: []*uint{nil},
+			rules:       &taxRules{},
+			currency:    "EUR",
+			wantTax:     []models.Money{0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taxes := applyTaxes(tt.items, tt.categoryIDs, tt.rules, tt.currency)
+
+			for i, item := range tt.items {
+				if item.Tax != tt.wantTax[i] {
+					t.Fatalf("expected item taxes %v, got %s on item %d", tt.wantTax, item.Tax, i)
+				}
+			}
+			if len(taxes) != len(tt.wantTaxes) {
+				t.Fatalf("expected taxes %+v, got %+v", tt.wantTaxes, taxes)
+			}
+			for i := range taxes {
+				if taxes[i] != tt.wantTaxes[i] {
+					t.Fatalf("expected taxes %+v, got %+v", tt.wantTaxes, taxes)
+				}
+			}
+		})
+	}
+}
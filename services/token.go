@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"go-shop/config"
+	"go-shop/database"
+	"go-shop/models"
+	"go-shop/utils"
+)
+
+// maxUserAgentLength bounds the user agent stored with a session
+const maxUserAgentLength = 255
+
+// issueTokens starts a refresh session for the user and returns its first access/refresh token pair.
+// Refresh tokens have the form "<session id>.<secret>"; only a hash of the secret is stored.
+func issueTokens(cfg *config.Config, user *models.User, role string, client models.ClientInfo) (*models.TokenResponse, error) {
+	sessionID, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return nil, errors.New("failed to generate session")
+	}
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, errors.New("failed to generate refresh token")
+	}
+
+	userAgent := client.UserAgent
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	now := time.Now()
+	session := &database.RefreshSession{
+		ID:         sessionID,
+		UserID:     user.ID,
+		UserAgent:  userAgent,
+		IP:         client.IP,
+		LastIP:     client.IP,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+
+	ctx := context.Background()
+	expiration := time.Duration(cfg.JWT.RefreshExpireHours) * time.Hour
+	if err := database.CreateRefreshSession(ctx, session, utils.HashToken(secret), expiration); err != nil {
+		return nil, errors.New("failed to store refresh session")
+	}
+
+	token, claims, err := utils.GenerateToken(user.ID, user.Email, role, sessionID, cfg)
+	if err != nil {
+		return nil, errors.New("failed to generate token")
+	}
+
+	return &models.TokenResponse{
+		Token:        token,
+		RefreshToken: sessionID + "." + secret,
+		ExpiresAt:    claims.ExpiresAt.Time,
+	}, nil
+}
+
+// splitRefreshToken separates the session ID and the secret of a refresh token
+func splitRefreshToken(refreshToken string) (string, string, bool) {
+	sessionID, secret, ok := strings.Cut(refreshToken, ".")
+	if !ok || sessionID == "" || secret == "" {
+		return "", "", false
+	}
+	return sessionID, secret, true
+}
+
+// primaryRole returns the role put into tokens: the user's first role, or user by default
+func primaryRole(user *models.User) string {
+	if len(user.Roles) > 0 {
+		return user.Roles[0].Name
+	}
+	return models.ROLE_USER
+}
+
+// revokeUserSessions logs a user out everywhere: refresh sessions are deleted and
+// access tokens issued until now are rejected by the auth middleware
+func revokeUserSessions(cfg *config.Config, userID uint) error {
+	ctx := context.Background()
+	if err := database.DeleteUserRefreshSessions(ctx, userID); err != nil {
+		log.Printf("Failed to delete refresh sessions of user %d: %v", userID, err)
+		return errors.New("failed to revoke sessions")
+	}
+
+	accessLifetime := time.Duration(cfg.JWT.AccessExpireMinutes) * time.Minute
+	if err := database.SetTokensRevokedAt(ctx, userID, time.Now(), accessLifetime); err != nil {
+		log.Printf("Failed to revoke access tokens of user %d: %v", userID, err)
+		return errors.New("failed to revoke sessions")
+	}
+	return nil
+}
@@ -0,0 +1,376 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go-shop/database"
+	"go-shop/models"
+	"go-shop/utils"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// recoveryCodeCount is the number of recovery codes issued at a time
+const recoveryCodeCount = 10
+
+var errInvalidTwoFactorCode = errors.New("invalid two-factor code")
+
+// requiresTwoFactor reports whether 2FA is mandatory for the user: it is for everyone whose roles grant
+// any permission, built-in or custom. If the permissions cannot be read 2FA is required.
+func requiresTwoFactor(user *models.User) bool {
+	access, err := database.LoadUserAccess(user.ID)
+	if err != nil {
+		log.Printf("Failed to get permissions of user %d: %v", user.ID, err)
+		return true
+	}
+	return len(access.Permissions) > 0
+}
+
+// startTwoFactorChallenge answers a correct password with a short-lived challenge token instead of a session
+func (as *AuthService) startTwoFactorChallenge(user *models.User, cartToken string, client models.ClientInfo) (*models.LoginResponse, error) {
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, errors.New("failed to generate challenge")
+	}
+
+	challenge := &database.TwoFactorChallenge{
+		UserID:    user.ID,
+		CartToken: cartToken,
+		UserAgent: client.UserAgent,
+		IP:        client.IP,
+	}
+	expiration := time.Duration(as.config.TwoFactor.ChallengeExpireMinutes) * time.Minute
+	if err := database.CreateTwoFactorChallenge(context.Background(), utils.HashToken(token), challenge, expiration); err != nil {
+		return nil, errors.New("failed to store challenge")
+	}
+
+	expiresAt := time.Now().Add(expiration)
+	return &models.LoginResponse{
+		TwoFactorRequired:  true,
+		EnrollmentRequired: !user.TOTPEnabled,
+		ChallengeToken:     token,
+		ChallengeExpiresAt: &expiresAt,
+	}, nil
+}
+
+// challengeUser loads the user a login challenge was issued to
+func challengeUser(challengeToken string) (*database.TwoFactorChallenge, *models.User, error) {
+	challenge, err := database.GetTwoFactorChallenge(context.Background(), utils.HashToken(challengeToken))
+	if err != nil {
+		if errors.Is(err, database.ErrTwoFactorChallengeInvalid) {
+			return nil, nil, err
+		}
+		return nil, nil, errors.New("failed to get challenge")
+	}
+
+	var user models.User
+	if err := database.DB.Preload("Roles").First(&user, challenge.UserID).Error; err != nil || !user.IsActive {
+		return nil, nil, database.ErrTwoFactorChallengeInvalid
+	}
+	return challenge, &user, nil
+}
+
+// EnrollTwoFactorChallenge creates a TOTP secret for a user whose role requires 2FA
+// but who has not enrolled yet; the first code is then sent to VerifyTwoFactor
+func (as *AuthService) EnrollTwoFactorChallenge(req *models.TwoFactorChallengeRequest) (*models.TwoFactorEnrollResponse, error) {
+	_, user, err := challengeUser(req.ChallengeToken)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+	return as.beginEnrollment(user)
+}
+
+// VerifyTwoFactor completes a login challenge with a TOTP or recovery code. For a user enrolling
+// during login the code confirms the new secret and the response includes their recovery codes.
+func (as *AuthService) VerifyTwoFactor(req *models.TwoFactorVerifyRequest) (*models.LoginResponse, error) {
+	challenge, user, err := challengeUser(req.ChallengeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// Wrong codes count towards the login lockout as well as the challenge's own attempts
+	if err := as.checkAuthLockout(limitScopeLogin, user.Email, challenge.IP); err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	tokenHash := utils.HashToken(req.ChallengeToken)
+
+	var ok bool
+	var pendingSecret string
+	var pendingStep int64
+	if user.TOTPEnabled {
+		ok, err = checkSecondFactor(user, req.Code, req.RecoveryCode)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		pendingSecret, err = database.GetPendingTOTPSecret(ctx, user.ID)
+		if err != nil {
+			return nil, errors.New("two-factor enrollment has not been started")
+		}
+		pendingStep, ok = utils.ValidateTOTP(pendingSecret, req.Code, time.Now())
+	}
+
+	if !ok {
+		if err := as.recordAuthFailure(limitScopeLogin, user.Email, challenge.IP); err != nil {
+			database.ConsumeTwoFactorChallenge(ctx, tokenHash)
+			return nil, err
+		}
+		left, err := database.FailTwoFactorChallenge(ctx, tokenHash, as.config.TwoFactor.MaxAttempts)
+		if err != nil {
+			return nil, database.ErrTwoFactorChallengeInvalid
+		}
+		if left == 0 {
+			return nil, errors.New("too many invalid codes, please log in again")
+		}
+		return nil, fmt.Errorf("%v, %d attempts left", errInvalidTwoFactorCode, left)
+	}
+
+	// A challenge completes a single login
+	consumed, err := database.ConsumeTwoFactorChallenge(ctx, tokenHash)
+	if err != nil || !consumed {
+		return nil, database.ErrTwoFactorChallengeInvalid
+	}
+
+	var recoveryCodes []string
+	if !user.TOTPEnabled {
+		recoveryCodes, err = enableTwoFactor(user, pendingSecret, pendingStep)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	client := models.ClientInfo{UserAgent: challenge.UserAgent, IP: challenge.IP}
+	response, err := as.completeLogin(user, challenge.CartToken, client)
+	if err != nil {
+		return nil, err
+	}
+	response.RecoveryCodes = recoveryCodes
+	return response, nil
+}
+
+// EnrollTwoFactor starts enrollment of a logged-in user; ConfirmTwoFactor enables it
+func (as *AuthService) EnrollTwoFactor(userID uint) (*models.TwoFactorEnrollResponse, error) {
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+	if user.TOTPEnabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+	return as.beginEnrollment(&user)
+}
+
+// beginEnrollment generates a TOTP secret that is kept aside until the user proves they stored it
+func (as *AuthService) beginEnrollment(user *models.User) (*models.TwoFactorEnrollResponse, error) {
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, errors.New("failed to generate secret")
+	}
+
+	expiration := time.Duration(as.config.TwoFactor.EnrollExpireMinutes) * time.Minute
+	if err := database.SetPendingTOTPSecret(context.Background(), user.ID, secret, expiration); err != nil {
+		return nil, errors.New("failed to store secret")
+	}
+
+	return &models.TwoFactorEnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: utils.TOTPURI(as.config.TwoFactor.Issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTwoFactor enables 2FA with the secret from EnrollTwoFactor and returns the recovery codes
+func (as *AuthService) ConfirmTwoFactor(userID uint, req *models.TwoFactorCodeRequest) (*models.RecoveryCodesResponse, error) {
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+	if user.TOTPEnabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+
+	secret, err := database.GetPendingTOTPSecret(context.Background(), userID)
+	if errors.Is(err, redis.Nil) {
+		return nil, errors.New("two-factor enrollment has not been started or has expired")
+	}
+	if err != nil {
+		return nil, errors.New("failed to get secret")
+	}
+
+	step, ok := utils.ValidateTOTP(secret, req.Code, time.Now())
+	if !ok {
+		return nil, errInvalidTwoFactorCode
+	}
+
+	codes, err := enableTwoFactor(&user, secret, step)
+	if err != nil {
+		return nil, err
+	}
+	return &models.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// enableTwoFactor stores a confirmed secret and issues the first recovery codes
+func enableTwoFactor(user *models.User, secret string, step int64) ([]string, error) {
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).
+			Where("id = ? AND totp_enabled = ?", user.ID, false).
+			Updates(map[string]interface{}{
+				"totp_secret":    secret,
+				"totp_enabled":   true,
+				"totp_last_step": step,
+			})
+		if result.Error != nil {
+			return errors.New("failed to enable two-factor authentication")
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("two-factor authentication is already enabled")
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	database.DeletePendingTOTPSecret(context.Background(), user.ID)
+	user.TOTPSecret, user.TOTPEnabled, user.TOTPLastStep = secret, true, step
+	log.Printf("Two-factor authentication enabled for user %d", user.ID)
+	return codes, nil
+}
+
+// DisableTwoFactor turns 2FA off after checking a current code; not allowed for roles that require it
+func (as *AuthService) DisableTwoFactor(userID uint, req *models.TwoFactorCodeRequest) error {
+	var user models.User
+	if err := database.DB.Preload("Roles").First(&user, userID).Error; err != nil {
+		return errors.New("user not found")
+	}
+	if !user.TOTPEnabled {
+		return errors.New("two-factor authentication is not enabled")
+	}
+	if requiresTwoFactor(&user) {
+		return errors.New("two-factor authentication is mandatory for your role")
+	}
+
+	ok, err := checkSecondFactor(&user, req.Code, req.RecoveryCode)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errInvalidTwoFactorCode
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"totp_secret":    "",
+			"totp_enabled":   false,
+			"totp_last_step": 0,
+		}).Error; err != nil {
+			return errors.New("failed to disable two-factor authentication")
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return errors.New("failed to delete recovery codes")
+		}
+		return nil
+	})
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes of a user after checking a current code
+func (as *AuthService) RegenerateRecoveryCodes(userID uint, req *models.TwoFactorCodeRequest) (*models.RecoveryCodesResponse, error) {
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+	if !user.TOTPEnabled {
+		return nil, errors.New("two-factor authentication is not enabled")
+	}
+
+	ok, err := checkSecondFactor(&user, req.Code, req.RecoveryCode)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errInvalidTwoFactorCode
+	}
+
+	var codes []string
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return &models.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// checkSecondFactor verifies a TOTP code or, failing that, uses up a recovery code.
+// Each TOTP code is accepted once: its time step must be newer than the last accepted one.
+func checkSecondFactor(user *models.User, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+
+		result := database.DB.Model(&models.User{}).
+			Where("id = ? AND totp_last_step < ?", user.ID, step).
+			Update("totp_last_step", step)
+		if result.Error != nil {
+			return false, errors.New("failed to verify code")
+		}
+		return result.RowsAffected == 1, nil
+	}
+
+	if recoveryCode != "" {
+		codeHash := utils.HashToken(utils.NormalizeRecoveryCode(recoveryCode))
+		result := database.DB.Model(&models.RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, codeHash).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return false, errors.New("failed to verify recovery code")
+		}
+		if result.RowsAffected == 1 {
+			log.Printf("Recovery code used by user %d", user.ID)
+		}
+		return result.RowsAffected == 1, nil
+	}
+
+	return false, nil
+}
+
+// replaceRecoveryCodes deletes the recovery codes of a user and creates new ones; only their hashes are stored
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, errors.New("failed to delete recovery codes")
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := utils.GenerateRecoveryCode()
+		if err != nil {
+			return nil, errors.New("failed to generate recovery codes")
+		}
+		codes = append(codes, code)
+		records = append(records, models.RecoveryCode{
+			UserID:   userID,
+			CodeHash: utils.HashToken(utils.NormalizeRecoveryCode(code)),
+		})
+	}
+
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, errors.New("failed to store recovery codes")
+	}
+	return codes, nil
+}
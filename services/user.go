@@ -44,6 +44,8 @@ func (us *UserService) GetUserByID(userID uint) (*models.UserResponse, error) {
 		Roles:     roleResponses,
 		IsActive:  user.IsActive,
 		CreatedAt: user.CreatedAt,
+
+		TwoFactorEnabled: user.TOTPEnabled,
 	}, nil
 }
 
@@ -88,5 +90,7 @@ func (us *UserService) UpdateUser(userID uint, req *models.UserUpdateRequest) (*
 		Roles:     roleResponses,
 		IsActive:  user.IsActive,
 		CreatedAt: user.CreatedAt,
+
+		TwoFactorEnabled: user.TOTPEnabled,
 	}, nil
 }
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"go-shop/database"
+	"go-shop/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// stockKey identifies where the stock of an order or cart line is kept: a product or one of its variants
+type stockKey struct {
+	ProductID uint
+	VariantID uint // 0 when the product is sold without variants
+}
+
+func newStockKey(productID uint, variantID *uint) stockKey {
+	key := stockKey{ProductID: productID}
+	if variantID != nil {
+		key.VariantID = *variantID
+	}
+	return key
+}
+
+func (k stockKey) variantID() *uint {
+	if k.VariantID == 0 {
+		return nil
+	}
+	variantID := k.VariantID
+	return &variantID
+}
+
+func sortedStockKeys(quantities map[stockKey]int) []stockKey {
+	keys := make([]stockKey, 0, len(quantities))
+	for key := range quantities {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].ProductID != keys[j].ProductID {
+			return keys[i].ProductID < keys[j].ProductID
+		}
+		return keys[i].VariantID < keys[j].VariantID
+	})
+	return keys
+}
+
+// stockRow selects the row holding the stock of a product, or of its variant when variantID is set
+func stockRow(tx *gorm.DB, productID uint, variantID *uint) *gorm.DB {
+	if variantID != nil {
+		return tx.Model(&models.ProductVariant{}).Where("id = ?", *variantID)
+	}
+	return tx.Model(&models.Product{}).Where("id = ?", productID)
+}
+
+// lockVariants loads requested variants with SELECT ... FOR UPDATE in ID order
+func lockVariants(tx *gorm.DB, requested map[stockKey]int) (map[uint]*models.ProductVariant, error) {
+	var variantIDs []uint
+	productOf := make(map[uint]uint)
+	for key := range requested {
+		if key.VariantID != 0 {
+			variantIDs = append(variantIDs, key.VariantID)
+			productOf[key.VariantID] = key.ProductID
+		}
+	}
+	sort.Slice(variantIDs, func(i, j int) bool { return variantIDs[i] < variantIDs[j] })
+
+	variants := make(map[uint]*models.ProductVariant, len(variantIDs))
+	for _, variantID := range variantIDs {
+		var variant models.ProductVariant
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&variant, variantID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("product variant not found")
+			}
+			return nil, errors.New("database error")
+		}
+		if variant.ProductID != productOf[variantID] {
+			return nil, errors.New("product variant not found")
+		}
+		variants[variantID] = &variant
+	}
+	return variants, nil
+}
+
+// productsWithVariants reports which of the products are sold through variants
+func productsWithVariants(tx *gorm.DB, productIDs []uint) (map[uint]bool, error) {
+	var ids []uint
+	if err := tx.Model(&models.ProductVariant{}).Where("product_id IN ?", productIDs).
+		Distinct("product_id").Pluck("product_id", &ids).Error; err != nil {
+		return nil, errors.New("failed to get product variants")
+	}
+
+	withVariants := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		withVariants[id] = true
+	}
+	return withVariants, nil
+}
+
+// CreateVariant adds a variant to a product; sellers (sellerID set) can only extend their own products
+func (ps *ProductService) CreateVariant(productID uint, sellerID *uint, req *models.ProductVariantCreateRequest) (*models.ProductVariantResponse, error) {
+	product, err := findOwnedProduct(productID, sellerID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if SKU is already used (including deleted variants, the index is unique)
+	if err := checkSKU(req.SKU, 0); err != nil {
+		return nil, err
+	}
+
+	variant := models.ProductVariant{
+		ProductID: product.ID,
+		SKU:       req.SKU,
+		Options:   variantOptions(req.Options),
+		Price:     req.Price,
+		Stock:     req.Stock,
+	}
+
+	if err := database.DB.Create(&variant).Error; err != nil {
+		return nil, errors.New("failed to create product variant")
+	}
+
+	return toVariantResponse(&variant, variant.EffectivePrice(product.Price)), nil
+}
+
+// UpdateVariant updates a product variant; sellers (sellerID set) can only update variants of their own products
+func (ps *ProductService) UpdateVariant(productID, variantID uint, sellerID *uint, req *models.ProductVariantUpdateRequest) (*models.ProductVariantResponse, error) {
+	product, err := findOwnedProduct(productID, sellerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var variant models.ProductVariant
+	if err := database.DB.Where("id = ? AND product_id = ?", variantID, product.ID).First(&variant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("product variant not found")
+		}
+		return nil, errors.New("database error")
+	}
+
+	// Update fields
+	if req.SKU != "" && req.SKU != variant.SKU {
+		if err := checkSKU(req.SKU, variant.ID); err != nil {
+			return nil, err
+		}
+		variant.SKU = req.SKU
+	}
+	if len(req.Options) > 0 {
+		variant.Options = variantOptions(req.Options)
+	}
+	if req.Price != nil {
+		variant.Price = req.Price
+	}
+	if req.ClearPrice {
+		variant.Price = nil
+	}
+	if req.Stock != nil {
+		if *req.Stock < variant.Reserved {
+			return nil, fmt.Errorf("stock cannot be lower than %d units reserved by orders", variant.Reserved)
+		}
+		variant.Stock = *req.Stock
+	}
+
+	if err := database.DB.Save(&variant).Error; err != nil {
+		return nil, errors.New("failed to update product variant")
+	}
+
+	return toVariantResponse(&variant, variant.EffectivePrice(product.Price)), nil
+}
+
+// DeleteVariant deletes a product variant that was never ordered (Admin only)
+func (ps *ProductService) DeleteVariant(productID, variantID uint) error {
+	var variant models.ProductVariant
+	if err := database.DB.Where("id = ? AND product_id = ?", variantID, productID).First(&variant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("product variant not found")
+		}
+		return errors.New("database error")
+	}
+
+	// Check if variant has order items
+	var count int64
+	if err := database.DB.Model(&models.OrderItem{}).Where("variant_id = ?", variantID).Count(&count).Error; err != nil {
+		return errors.New("failed to check variant orders")
+	}
+
+	if count > 0 {
+		return errors.New("cannot delete variant with existing orders")
+	}
+
+	if err := database.DB.Delete(&variant).Error; err != nil {
+		return errors.New("failed to delete product variant")
+	}
+
+	return nil
+}
+
+// findOwnedProduct loads a product, limited to the seller's own products when sellerID is set
+func findOwnedProduct(productID uint, sellerID *uint) (*models.Product, error) {
+	query := database.DB.Where("id = ?", productID)
+	if sellerID != nil {
+		query = query.Where("seller_id = ?", *sellerID)
+	}
+
+	var product models.Product
+	if err := query.First(&product).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("product not found")
+		}
+		return nil, errors.New("database error")
+	}
+	return &product, nil
+}
+
+// checkSKU rejects a SKU already used by another variant
+func checkSKU(sku string, exceptID uint) error {
+	var existing models.ProductVariant
+	if err := database.DB.Unscoped().Where("sku = ? AND id <> ?", sku, exceptID).First(&existing).Error; err == nil {
+		return fmt.Errorf("variant with sku %s already exists", sku)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return errors.New("database error")
+	}
+	return nil
+}
+
+func variantOptions(options map[string]string) models.JSONB {
+	result := make(models.JSONB, len(options))
+	for name, value := range options {
+		result[name] = value
+	}
+	return result
+}
+
+// withVariants fills the variant selection of a product response with variants priced from the price list
+func withVariants(response *models.ProductResponse, product *models.Product, variants []models.ProductVariant, prices *priceList) {
+	if len(variants) == 0 {
+		return
+	}
+
+	values := make(map[string]map[string]bool)
+	response.VariantOptions = make(map[string][]string)
+	for i := range variants {
+		response.Variants = append(response.Variants, *toVariantResponse(&variants[i], prices.Price(product, &variants[i])))
+
+		for name, value := range variants[i].Options {
+			text := fmt.Sprint(value)
+			if values[name] == nil {
+				values[name] = make(map[string]bool)
+			}
+			if !values[name][text] {
+				values[name][text] = true
+				response.VariantOptions[name] = append(response.VariantOptions[name], text)
+			}
+		}
+	}
+}
+
+func toVariantResponse(variant *models.ProductVariant, price models.Money) *models.ProductVariantResponse {
+	return &models.ProductVariantResponse{
+		ID:        variant.ID,
+		ProductID: variant.ProductID,
+		SKU:       variant.SKU,
+		Options:   variant.Options,
+		Price:     price,
+		Stock:     variant.AvailableStock(),
+		Available: variant.AvailableStock() > 0,
+		CreatedAt: variant.CreatedAt,
+		UpdatedAt: variant.UpdatedAt,
+	}
+}
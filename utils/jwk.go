@@ -0,0 +1,211 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"go-shop/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTKey is a key tokens are signed or verified with. Keys loaded from a public key file
+// only verify tokens: they belong to retired signing keys or to another service.
+type JWTKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.PrivateKey // nil for verification-only keys
+	Public  crypto.PublicKey
+}
+
+// jwtKeys holds the keys loaded by LoadJWTKeys
+var jwtKeys struct {
+	signing *JWTKey
+	byID    map[string]*JWTKey
+}
+
+// LoadJWTKeys loads the keys listed in JWT_KEYS ("kid=path.pem,kid2=path2.pem") and selects
+// JWT_SIGNING_KEY_ID for signing. RSA keys sign with RS256, Ed25519 keys with EdDSA.
+// Without configured keys an ephemeral Ed25519 key is generated outside release mode: tokens do not
+// survive a restart. In release mode keys are required.
+func LoadJWTKeys(cfg *config.Config) error {
+	byID := make(map[string]*JWTKey)
+	for _, entry := range strings.Split(cfg.JWT.Keys, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, path, ok := strings.Cut(entry, "=")
+		if !ok || id == "" || path == "" {
+			return fmt.Errorf("invalid JWT key entry %q, expected kid=path", entry)
+		}
+		if _, exists := byID[id]; exists {
+			return fmt.Errorf("duplicate JWT key id %q", id)
+		}
+
+		key, err := loadJWTKey(id, path)
+		if err != nil {
+			return err
+		}
+		byID[id] = key
+	}
+
+	if len(byID) == 0 {
+		if cfg.Server.GinMode == gin.ReleaseMode {
+			return errors.New("JWT_KEYS must be set in release mode")
+		}
+		key, err := ephemeralJWTKey()
+		if err != nil {
+			return err
+		}
+		log.Printf("Warning: JWT_KEYS is not set, signing tokens with ephemeral key %s", key.ID)
+		jwtKeys.signing = key
+		jwtKeys.byID = map[string]*JWTKey{key.ID: key}
+		return nil
+	}
+
+	signing, ok := byID[cfg.JWT.SigningKeyID]
+	if !ok {
+		return fmt.Errorf("signing key %q is not in JWT_KEYS", cfg.JWT.SigningKeyID)
+	}
+	if signing.Private == nil {
+		return fmt.Errorf("signing key %q has no private key", signing.ID)
+	}
+
+	jwtKeys.signing = signing
+	jwtKeys.byID = byID
+	return nil
+}
+
+func loadJWTKey(id, path string) (*JWTKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT key %s: %v", id, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("JWT key %s is not PEM encoded", id)
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("JWT key %s has unsupported PEM type %s", id, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JWT key %s: %v", id, err)
+	}
+
+	key := &JWTKey{ID: id}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("JWT key %s must be an RSA or Ed25519 key", id)
+	}
+
+	if rsaKey, ok := key.Public.(*rsa.PublicKey); ok && rsaKey.N.BitLen() < 2048 {
+		return nil, fmt.Errorf("JWT key %s: RSA keys must be at least 2048 bits", id)
+	}
+	return key, nil
+}
+
+func ephemeralJWTKey() (*JWTKey, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, errors.New("failed to generate JWT key")
+	}
+	id, err := GenerateRandomToken(8)
+	if err != nil {
+		return nil, errors.New("failed to generate JWT key")
+	}
+	return &JWTKey{ID: "ephemeral-" + id, Method: jwt.SigningMethodEdDSA, Private: private, Public: public}, nil
+}
+
+// signingKey returns the key new tokens are signed with
+func signingKey() (*JWTKey, error) {
+	if jwtKeys.signing == nil {
+		return nil, errors.New("JWT keys are not loaded")
+	}
+	return jwtKeys.signing, nil
+}
+
+// verificationKey resolves the key named by a token's kid header and checks the token uses its algorithm
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	id, _ := token.Header["kid"].(string)
+	key, ok := jwtKeys.byID[id]
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.Public, nil
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicJWKS returns every verification key, so other services can check our tokens
+func PublicJWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range jwtKeys.byID {
+		jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Method.Alg()}
+		switch public := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"testing"
+
+	"go-shop/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLoadJWTKeysWithoutKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		ginMode string
+		wantErr bool
+	}{
+		{"debug mode uses an ephemeral key", gin.DebugMode, false},
+		{"release mode requires keys", gin.ReleaseMode, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Server: config.ServerConfig{GinMode: tt.ginMode}}
+			err := LoadJWTKeys(cfg)
+			if tt.wantErr && err == nil {
+				t.Fatal("expected an error without JWT_KEYS")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
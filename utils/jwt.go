@@ -10,41 +10,67 @@ import (
 )
 
 type Claims struct {
-	UserID uint   `json:"user_id"`
-	Email  string `json:"email"`
-	Role   string `json:"role"`
+	UserID    uint   `json:"user_id"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid"` // Refresh session the token was issued for
+	// Issue time in milliseconds: iat has whole seconds, too coarse to tell a token issued right
+	// after a revocation from one issued before it
+	IssuedAtMilli int64 `json:"iat_ms"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID uint, email, role string, cfg *config.Config) (string, error) {
-	claims := Claims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
+// GenerateToken issues a short-lived access token with a unique ID (jti) so it can be denylisted.
+// It is signed with the current signing key, named in the kid header.
+func GenerateToken(userID uint, email, role, sessionID string, cfg *config.Config) (string, *Claims, error) {
+	key, err := signingKey()
+	if err != nil {
+		return "", nil, err
+	}
+
+	tokenID, err := GenerateRandomToken(16)
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	claims := &Claims{
+		UserID:        userID,
+		Email:         email,
+		Role:          role,
+		SessionID:     sessionID,
+		IssuedAtMilli: now.UnixMilli(),
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(cfg.JWT.ExpireHours) * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			ID:        tokenID,
+			Issuer:    cfg.JWT.Issuer,
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Duration(cfg.JWT.AccessExpireMinutes) * time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(cfg.JWT.Secret))
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	signed, err := token.SignedString(key.Private)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
 }
 
+// ValidateToken verifies a token against the key named by its kid, including retired keys still configured
 func ValidateToken(tokenString string, cfg *config.Config) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(cfg.JWT.Secret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, verificationKey, jwt.WithIssuer(cfg.JWT.Issuer))
 
 	if err != nil {
 		return nil, err
 	}
 
 	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+		// Tokens without an ID or session cannot be revoked
+		if claims.ID == "" || claims.SessionID == "" || claims.IssuedAtMilli == 0 || claims.ExpiresAt == nil {
+			return nil, errors.New("invalid token")
+		}
 		return claims, nil
 	}
 